@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"log"
+	"os/exec"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("create", runCreate, `
+usage: weo create [-r <remote>] [-y] [<name>]
+
+Create an application in Weo.
+
+If a name is not provided, a random name will be generated.
+
+If run from a git repository, a 'weo' remote will be created or replaced that
+allows deploying the application via git.
+
+Options:
+	-r, --remote=<remote>  Name of git remote to create, empty string for none. [default: weo]
+	-y, --yes              Skip the confirmation prompt if the git remote already exists.
+
+Examples:
+
+	$ weo create
+	Created turkeys-stupefy-perry
+`)
+
+	register("delete", runDelete, `
+usage: weo delete [-y] [--keep-remotes]
+
+Delete an app.
+
+If run from a git repository, every git remote pointing at the app is
+removed.
+
+Options:
+	--keep-remotes  Don't remove the git remotes of the app.
+	-y, --yes       Skip the confirmation prompt.
+
+Examples:
+
+	$ weo -a turkeys-stupefy-perry delete
+	Are you sure you want to delete the app "turkeys-stupefy-perry"? (yes/no): yes
+	Deleted turkeys-stupefy-perry (removed 1 routes, deleted 2 releases, deprovisioned 0 resources)
+`)
+
+	register("apps", runApps, `
+usage: weo apps
+
+List all apps, or the apps matching the global --selector option.
+
+Examples:
+
+	$ weo apps
+	ID                                NAME                   CREATED
+	f1e85f5392454a329929e3f27f7a5644  turkeys-stupefy-perry  2 hours ago
+	9d5be7be873c41b9898032c08aa87597  controller             3 days ago
+`)
+}
+
+func runCreate(args *docopt.Args, client controller.Client) error {
+	app := &ct.App{}
+	app.Name = args.String["<name>"]
+	remote := args.String["--remote"]
+
+	if inGitRepo() && remote != "" && !args.Bool["--yes"] {
+		// Test if remote name exists and prompt user
+		update, err := promptReplaceRemote(remote)
+		if err != nil {
+			return err
+		}
+		if !update {
+			return nil
+		}
+	}
+
+	// Create the app
+	if err := client.CreateApp(app); err != nil {
+		return err
+	}
+
+	// Register git remote
+	if inGitRepo() && remote != "" {
+		exec.Command("git", "remote", "remove", remote).Run()
+		exec.Command("git", "remote", "add", "--", remote, gitURL(clusterConf, app.Name)).Run()
+	}
+	log.Printf("Created %s", app.Name)
+	return nil
+}
+
+func runDelete(args *docopt.Args, client controller.Client) error {
+	appName := mustApp()
+
+	if !args.Bool["--yes"] {
+		if !promptYesNo(fmt.Sprintf("Are you sure you want to delete the app %q?", appName)) {
+			return nil
+		}
+	}
+
+	res, err := client.DeleteApp(appName)
+	if err != nil {
+		return err
+	}
+
+	if !args.Bool["--keep-remotes"] {
+		removeAppRemotes(appName)
+	}
+
+	log.Printf("Deleted %s (removed %d routes, deleted %d releases, deprovisioned %d resources)",
+		appName, len(res.DeletedRoutes), len(res.DeletedReleases), len(res.DeletedResources))
+	return nil
+}
+
+// removeAppRemotes removes the git remotes which point at the app on the
+// current cluster
+func removeAppRemotes(appName string) {
+	remotes, err := gitRemotes()
+	if err != nil {
+		return
+	}
+	cluster, err := getCluster()
+	if err != nil {
+		return
+	}
+	for name, app := range remotes {
+		if app.Name == appName && app.Cluster.Name == cluster.Name {
+			exec.Command("git", "remote", "remove", name).Run()
+		}
+	}
+}
+
+func runApps(args *docopt.Args, client controller.Client) error {
+	apps, err := selectedApps(client)
+	if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	listRec(w, "ID", "NAME", "CREATED")
+	for _, a := range apps {
+		listRec(w, a.ID, a.Name, humanTime(a.CreatedAt))
+	}
+	return nil
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"log"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+	cfg "weo/cli/config"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("cluster", runCluster, `
+usage: weo cluster
+       weo cluster add [-f] [-d] [--git-url <giturl>] [--no-git] [--image-url <url>] [-p <tlspin>] <cluster-name> <domain> <key>
+       weo cluster remove <cluster-name>
+       weo cluster default [<cluster-name>]
+       weo cluster migrate-domain [-y] <domain>
+
+Manage Weo clusters.
+
+
+Commands:
+    With no arguments, shows a list of configured clusters.
+
+    add
+        Adds <cluster-name> to the ~/.weorc configuration file.
+
+        The cluster's CA certificate is fetched from the controller (verifying
+        the controller's TLS certificate against --tls-pin if given), written
+        to ~/.weo/ca-certs/<cluster-name>.pem and configured for git.
+
+        options:
+            -f, --force               force add cluster
+            -d, --default             set as default cluster
+            --git-url=<giturl>        git URL
+            --no-git                  skip git configuration
+            --image-url=<url>         image URL
+            -p, --tls-pin=<tlspin>    SHA256 of the cluster's TLS cert
+
+    remove
+        Removes <cluster-name> from the ~/.weorc configuration file.
+
+    default
+        With no arguments, prints the default cluster. With <cluster-name>, sets
+        the default cluster.
+
+    migrate-domain
+        Migrates the cluster's base domain from the current one to <domain>.
+
+        New routes will be added with the pattern <app-name>.<domain> for each
+        app, and the local configuration for the cluster is updated to use the
+        new domain.
+
+        options:
+            -y, --yes  Skip the confirmation prompt.
+
+Examples:
+
+	$ weo cluster add -p KGCENkp53YF5OvOKkZIry71+czFRkSw2ZdMszZ/0ljs= default dev.localweo.com e09dc5301d72be755a3d666f617c4600
+	Cluster "default" added and set as default.
+
+	$ weo cluster migrate-domain new.example.com
+	Migrate cluster domain from "example.com" to "new.example.com"? (yes/no): yes
+	Migrating cluster domain (this can take up to 2m0s)...
+	Changed cluster domain from "example.com" to "new.example.com"
+	Updated local CLI configuration
+`)
+}
+
+func runCluster(args *docopt.Args) error {
+	if err := readConfig(); err != nil {
+		return err
+	}
+
+	if args.Bool["add"] {
+		return runClusterAdd(args)
+	} else if args.Bool["remove"] {
+		return runClusterRemove(args)
+	} else if args.Bool["default"] {
+		return runClusterDefault(args)
+	} else if args.Bool["migrate-domain"] {
+		return runClusterMigrateDomain(args)
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	listRec(w, "NAME", "CONTROLLER URL", "GIT URL", "IMAGE URL")
+	for _, s := range config.Clusters {
+		gitURL := s.GitURL
+		if gitURL == "" {
+			gitURL = "(none)"
+		}
+		imageURL := s.ImageURL
+		if imageURL == "" {
+			imageURL = "(none)"
+		}
+		data := []interface{}{s.Name, s.ControllerURL, gitURL, imageURL}
+		if s.Name == config.Default {
+			data = append(data, "(default)")
+		}
+		listRec(w, data...)
+	}
+	return nil
+}
+
+func runClusterAdd(args *docopt.Args) error {
+	s := &cfg.Cluster{
+		Name:     args.String["<cluster-name>"],
+		Key:      args.String["<key>"],
+		GitURL:   args.String["--git-url"],
+		ImageURL: args.String["--image-url"],
+		TLSPin:   args.String["--tls-pin"],
+	}
+	domain := args.String["<domain>"]
+
+	// handle use where <domain> is the controller URL
+	domain = strings.TrimPrefix(domain, "https://controller.")
+
+	s.ControllerURL = "https://controller." + domain
+	if s.GitURL == "" && !args.Bool["--no-git"] {
+		s.GitURL = "https://git." + domain
+	}
+	if s.ImageURL == "" {
+		s.ImageURL = "https://images." + domain
+	}
+
+	if err := config.Add(s, args.Bool["--force"]); err != nil {
+		return err
+	}
+
+	setDefault := args.Bool["--default"] || len(config.Clusters) == 1
+
+	if setDefault && !config.SetDefault(s.Name) {
+		return fmt.Errorf("Cluster %q does not exist and cannot be set as default.", s.Name)
+	}
+
+	if s.GitURL != "" {
+		if _, err := exec.LookPath("git"); err != nil {
+			if serr, ok := err.(*exec.Error); ok && serr.Err == exec.ErrNotFound {
+				return errors.New("Executable 'git' was not found. Use --no-git to skip git configuration")
+			}
+			return err
+		}
+
+		client, err := s.Client()
+		if err != nil {
+			return err
+		}
+		caPath, err := writeCACert(client, s.Name)
+		if err != nil {
+			return fmt.Errorf("Error writing CA certificate: %s", err)
+		}
+		if err := cfg.WriteGlobalGitConfig(s.GitURL, caPath); err != nil {
+			return err
+		}
+	}
+
+	if err := config.SaveTo(configPath()); err != nil {
+		return err
+	}
+
+	if setDefault {
+		log.Printf("Cluster %q added and set as default.", s.Name)
+	} else {
+		log.Printf("Cluster %q added.", s.Name)
+	}
+	return nil
+}
+
+// writeCACert fetches the cluster CA certificate from the controller and
+// writes it to the CA cert file for the named cluster, returning the file's
+// path.
+//
+// The certificate is fetched using the cluster client, so if the cluster has a
+// TLS pin the controller's certificate is verified against it before the CA
+// certificate is trusted.
+func writeCACert(c controller.Client, name string) (string, error) {
+	data, err := c.GetCACert()
+	if err != nil {
+		return "", err
+	}
+	if err := verifyCACert(data); err != nil {
+		return "", err
+	}
+	dest, err := cfg.CACertFile(name)
+	if err != nil {
+		return "", err
+	}
+	defer dest.Close()
+	_, err = dest.Write(data)
+	return dest.Name(), err
+}
+
+func verifyCACert(data []byte) error {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return errors.New("invalid CA certificate, expected a PEM encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid CA certificate: %s", err)
+	}
+	if !cert.IsCA {
+		return errors.New("invalid CA certificate, certificate is not a CA")
+	}
+	return nil
+}
+
+func runClusterRemove(args *docopt.Args) error {
+	name := args.String["<cluster-name>"]
+
+	c := config.Remove(name)
+	if c == nil {
+		return fmt.Errorf("Cluster %q does not exist.", name)
+	}
+
+	msg := fmt.Sprintf("Cluster %q removed.", name)
+
+	// Select next available cluster as default
+	if config.Default == name {
+		config.Default = ""
+		if len(config.Clusters) > 0 {
+			config.SetDefault(config.Clusters[0].Name)
+			msg = fmt.Sprintf("Cluster %q removed and %q is now the default cluster.", name, config.Default)
+		}
+	}
+
+	if err := config.SaveTo(configPath()); err != nil {
+		return err
+	}
+
+	if c.GitURL != "" {
+		cfg.RemoveGlobalGitConfig(c.GitURL)
+	}
+
+	log.Print(msg)
+	return nil
+}
+
+func runClusterDefault(args *docopt.Args) error {
+	name := args.String["<cluster-name>"]
+
+	if name == "" {
+		w := tabWriter()
+		defer w.Flush()
+		listRec(w, "NAME", "URL")
+		for _, s := range config.Clusters {
+			if s.Name == config.Default {
+				listRec(w, s.Name, s.ControllerURL, "(default)")
+				break
+			}
+		}
+		return nil
+	}
+
+	if !config.SetDefault(name) {
+		return fmt.Errorf("Cluster %q does not exist and cannot be set as default.", name)
+	}
+	if err := config.SaveTo(configPath()); err != nil {
+		return err
+	}
+
+	log.Printf("%q is now the default cluster.", name)
+	return nil
+}
+
+func runClusterMigrateDomain(args *docopt.Args) error {
+	cluster, err := getCluster()
+	if err != nil {
+		return err
+	}
+	client, err := cluster.Client()
+	if err != nil {
+		return err
+	}
+
+	dm := &ct.DomainMigration{
+		Domain: args.String["<domain>"],
+	}
+	dm.OldDomain, err = clusterDomain(client, cluster)
+	if err != nil {
+		return err
+	}
+
+	if !args.Bool["--yes"] && !promptYesNo(fmt.Sprintf("Migrate cluster domain from %q to %q?", dm.OldDomain, dm.Domain)) {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	maxDuration := 2 * time.Minute
+	fmt.Printf("Migrating cluster domain (this can take up to %s)...\n", maxDuration)
+
+	events := make(chan *ct.Event)
+	stream, err := client.StreamEvents(ct.StreamEventsOptions{
+		ObjectTypes: []ct.EventType{ct.EventTypeDomainMigration},
+	}, events)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	if err := client.PutDomain(dm); err != nil {
+		return err
+	}
+
+	timeout := time.After(maxDuration)
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	start := time.Now()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return stream.Err()
+			}
+			var e *ct.DomainMigrationEvent
+			if err := json.Unmarshal(event.Data, &e); err != nil {
+				return err
+			}
+			if e.Error != "" {
+				fmt.Println(e.Error)
+			}
+			if e.DomainMigration == nil || e.DomainMigration.FinishedAt == nil {
+				continue
+			}
+			dm = e.DomainMigration
+			fmt.Printf("Changed cluster domain from %q to %q\n", dm.OldDomain, dm.Domain)
+			if err := updateClusterDomain(cluster, dm); err != nil {
+				return err
+			}
+			fmt.Println("Updated local CLI configuration")
+			return nil
+		case <-ticker.C:
+			fmt.Printf("Still migrating (%s elapsed)...\n", time.Since(start).Truncate(time.Second))
+		case <-timeout:
+			return errors.New("timed out waiting for domain migration to complete")
+		}
+	}
+}
+
+// clusterDomain returns the current base domain of the cluster, preferring the
+// default route domain of the controller app and falling back to the domain of
+// the configured controller URL.
+func clusterDomain(client controller.Client, cluster *cfg.Cluster) (string, error) {
+	release, err := client.GetAppRelease("controller")
+	if err == nil && release.Env["DEFAULT_ROUTE_DOMAIN"] != "" {
+		return release.Env["DEFAULT_ROUTE_DOMAIN"], nil
+	} else if err != nil && err != controller.ErrNotFound {
+		return "", err
+	}
+	u, err := url.Parse(cluster.ControllerURL)
+	if err != nil {
+		return "", fmt.Errorf("could not parse controller URL: %s", err)
+	}
+	if !strings.HasPrefix(u.Hostname(), "controller.") {
+		return "", fmt.Errorf("could not determine the current cluster domain from %q", cluster.ControllerURL)
+	}
+	return strings.TrimPrefix(u.Hostname(), "controller."), nil
+}
+
+// updateClusterDomain updates the local configuration of cluster to use the
+// new domain of the finished domain migration dm.
+func updateClusterDomain(cluster *cfg.Cluster, dm *ct.DomainMigration) error {
+	oldGitURL := cluster.GitURL
+	if dm.TLSCert != nil && dm.TLSCert.Pin != "" {
+		cluster.TLSPin = dm.TLSCert.Pin
+	}
+	cluster.ControllerURL = fmt.Sprintf("https://controller.%s", dm.Domain)
+	if cluster.GitURL != "" {
+		cluster.GitURL = fmt.Sprintf("https://git.%s", dm.Domain)
+	}
+	if cluster.ImageURL != "" {
+		cluster.ImageURL = fmt.Sprintf("https://images.%s", dm.Domain)
+	}
+	if err := config.SaveTo(configPath()); err != nil {
+		return fmt.Errorf("Error saving config: %s", err)
+	}
+
+	if cluster.GitURL == "" {
+		return nil
+	}
+	caPath := cfg.CACertPath(cluster.Name)
+	if dm.TLSCert != nil && dm.TLSCert.CACert != "" {
+		caFile, err := cfg.CACertFile(cluster.Name)
+		if err != nil {
+			return err
+		}
+		defer caFile.Close()
+		if _, err := caFile.Write([]byte(dm.TLSCert.CACert)); err != nil {
+			return err
+		}
+	}
+	if err := cfg.WriteGlobalGitConfig(cluster.GitURL, caPath); err != nil {
+		return err
+	}
+	cfg.RemoveGlobalGitConfig(oldGitURL)
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"github.com/docker/go-units"
+	"github.com/flynn/flynn/pkg/term"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+func promptYesNo(msg string) (result bool) {
+	fmt.Print(msg)
+	fmt.Print(" (yes/no): ")
+	for {
+		var answer string
+		fmt.Scanln(&answer)
+		switch answer {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		default:
+			fmt.Print("Please type 'yes' or 'no': ")
+		}
+	}
+}
+
+func promptReplaceRemote(remote string) (bool, error) {
+	remotes, err := gitRemoteNames()
+	if err != nil {
+		return false, err
+	}
+	for _, r := range remotes {
+		if r == remote {
+			fmt.Println("There is already a git remote called", remote)
+			if !promptYesNo("Are you sure you want to replace it?") {
+				log.Println("The remote was not created. Please declare the desired local git remote name with --remote flag.")
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// progressBar renders the progress of a transfer to stderr, doing nothing
+// unless stderr is a terminal
+type progressBar struct {
+	prefix  string
+	n       int64
+	total   int64
+	start   time.Time
+	last    time.Time
+	enabled bool
+}
+
+// newProgressBar returns a progress bar for a transfer of total bytes, or -1
+// if the size is unknown
+func newProgressBar(prefix string, total int64) *progressBar {
+	return &progressBar{
+		prefix:  prefix,
+		total:   total,
+		start:   time.Now(),
+		enabled: term.IsTerminal(os.Stderr.Fd()),
+	}
+}
+
+// Update sets the number of bytes transferred, it has the signature of a
+// tarreceive client ProgressFunc
+func (p *progressBar) Update(n, total int64) {
+	p.n = n
+	if total >= 0 {
+		p.total = total
+	}
+	if !p.enabled || time.Since(p.last) < 100*time.Millisecond {
+		return
+	}
+	p.last = time.Now()
+	p.render()
+}
+
+// Write counts the bytes written as transferred
+func (p *progressBar) Write(b []byte) (int, error) {
+	p.Update(p.n+int64(len(b)), -1)
+	return len(b), nil
+}
+
+// Finish renders the final state of the transfer
+func (p *progressBar) Finish() {
+	if !p.enabled {
+		return
+	}
+	p.render()
+	fmt.Fprintln(os.Stderr)
+}
+
+func (p *progressBar) render() {
+	const width = 30
+	var rate string
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		rate = units.HumanSize(float64(p.n)/elapsed) + "/s"
+	}
+	if p.total <= 0 {
+		fmt.Fprintf(os.Stderr, "\r%s %s %s\033[K", p.prefix, units.HumanSize(float64(p.n)), rate)
+		return
+	}
+	filled := int(width * p.n / p.total)
+	if filled > width {
+		filled = width
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	fmt.Fprintf(os.Stderr, "\r%s [%s] %3d%% %s / %s %s\033[K", p.prefix, bar, 100*p.n/p.total,
+		units.HumanSize(float64(p.n)), units.HumanSize(float64(p.total)), rate)
+}
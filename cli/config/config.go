@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"weo/controller/client"
+	tarclient "weo/tarreceive/client"
 )
 
 var ErrNoDockerPushURL = errors.New("ERROR: Docker push URL not configured, set it with 'weo docker set-push-url'")
@@ -39,18 +40,6 @@ func (c *Cluster) Client() (controller.Client, error) {
 	return controller.NewClientWithConfig(c.ControllerURL, c.Key, controller.Config{Pin: pin})
 }
 
-func (c *Cluster) Client() (controller.Client, error) {
-	var pin []byte
-	if c.TLSPin != "" {
-		var err error
-		pin, err = base64.StdEncoding.DecodeString(c.TLSPin)
-		if err != nil {
-			return nil, fmt.Errorf("error decoding tls pin: %s", err)
-		}
-	}
-	return controller.NewClientWithConfig(c.ControllerURL, c.Key, controller.Config{Pin: pin})
-}
-
 func (c *Cluster) TarClient() (*tarclient.Client, error) {
 	if c.ImageURL == "" {
 		return nil, errors.New("cluster: missing ImageURL .weorc config")
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+// database describes a database provided by a provider app. Its commands
+// are shell snippets run in the provider app's release, which contains the
+// database client tools, with the env of the app's resource. Dumps are
+// written to and restored from stdio.
+type database struct {
+	// Provider is the name of both the provider and its app
+	Provider string
+
+	// Console runs an interactive client, with any user arguments
+	// available as "$@"
+	Console    string
+	ConsoleEnv map[string]string
+
+	Dump    string
+	Restore string
+
+	// ExportFile is the name of the dump in export archives
+	ExportFile string
+
+	// InfoKeys are the resource env keys shown first by the info
+	// subcommand, in order
+	InfoKeys []string
+}
+
+var (
+	postgresDatabase = &database{
+		Provider: "postgres",
+		Console:  `exec psql "$@"`,
+		ConsoleEnv: map[string]string{
+			"PAGER": "less",
+			"LESS":  "--ignore-case --LONG-PROMPT --SILENT --tabs=4 --quit-if-one-screen --no-init --quit-at-eof",
+		},
+		Dump: `pg_dump --format=custom --no-owner --no-acl`,
+		// pg_restore exits 1 when it only encountered warnings
+		Restore:    `pg_restore -d "$PGDATABASE" -n public --clean --if-exists --no-owner --no-acl; [ $? -le 1 ]`,
+		ExportFile: "postgres.dump",
+		InfoKeys:   []string{"PGHOST", "PGUSER", "PGPASSWORD", "PGDATABASE", "DATABASE_URL"},
+	}
+
+	mysqlDatabase = &database{
+		Provider:   "mysql",
+		Console:    `exec mysql -h "$MYSQL_HOST" -u "$MYSQL_USER" -D "$MYSQL_DATABASE" "$@"`,
+		Dump:       `mysqldump -h "$MYSQL_HOST" -u "$MYSQL_USER" --single-transaction "$MYSQL_DATABASE"`,
+		Restore:    `mysql -h "$MYSQL_HOST" -u "$MYSQL_USER" "$MYSQL_DATABASE"`,
+		ExportFile: "mysql.dump",
+		InfoKeys:   []string{"MYSQL_HOST", "MYSQL_USER", "MYSQL_PWD", "MYSQL_DATABASE", "DATABASE_URL"},
+	}
+
+	mongodbDatabase = &database{
+		Provider:   "mongodb",
+		Console:    `exec mongo --host "$MONGO_HOST" -u "$MONGO_USER" -p "$MONGO_PWD" --authenticationDatabase admin "$MONGO_DATABASE" "$@"`,
+		Dump:       `mongodump --quiet --host "$MONGO_HOST" -u "$MONGO_USER" -p "$MONGO_PWD" --authenticationDatabase admin --db "$MONGO_DATABASE" --archive`,
+		Restore:    `mongorestore --quiet --host "$MONGO_HOST" -u "$MONGO_USER" -p "$MONGO_PWD" --authenticationDatabase admin --drop --archive`,
+		ExportFile: "mongodb.archive",
+		InfoKeys:   []string{"MONGO_HOST", "MONGO_USER", "MONGO_PWD", "MONGO_DATABASE", "DATABASE_URL"},
+	}
+
+	redisDatabase = &database{
+		Provider:   "redis",
+		Console:    `exec redis-cli -h "$REDIS_HOST" -p "$REDIS_PORT" -a "$REDIS_PASSWORD" "$@"`,
+		Dump:       `/bin/dump-weo-redis -h "$REDIS_HOST" -p "$REDIS_PORT" -a "$REDIS_PASSWORD"`,
+		Restore:    `/bin/restore-weo-redis -h "$REDIS_HOST" -p "$REDIS_PORT" -a "$REDIS_PASSWORD"`,
+		ExportFile: "redis.rdb",
+		InfoKeys:   []string{"REDIS_HOST", "REDIS_PORT", "REDIS_PASSWORD", "REDIS_URL"},
+	}
+)
+
+// databases are the databases included in app exports
+var databases = []*database{postgresDatabase, mysqlDatabase, mongodbDatabase, redisDatabase}
+
+// findAppDatabase returns the resource in resources provided by the
+// database's provider, or nil if there is none
+func findAppDatabase(client controller.Client, resources []*ct.Resource, db *database) (*ct.Resource, *ct.Provider, error) {
+	for _, res := range resources {
+		provider, err := client.GetProvider(res.ProviderID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting provider %s: %s", res.ProviderID, err)
+		}
+		if provider.Name == db.Provider {
+			return res, provider, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// appDatabase returns the resource provisioned for the app by the
+// database's provider
+func appDatabase(client controller.Client, appName string, db *database) (*ct.Resource, *ct.Provider, error) {
+	resources, err := client.AppResourceList(appName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting resources: %s", err)
+	}
+	res, provider, err := findAppDatabase(client, resources, db)
+	if err != nil {
+		return nil, nil, err
+	} else if res == nil {
+		return nil, nil, fmt.Errorf("No %s database found. Provision one with `weo resource add %s`", db.Provider, db.Provider)
+	}
+	return res, provider, nil
+}
+
+// databaseRunConfig returns the config of a job for the app which runs cmd
+// in the release of the provider app with the resource env
+func databaseRunConfig(client controller.Client, appName string, provider *ct.Provider, res *ct.Resource, cmd string, args ...string) (*runConfig, error) {
+	release, err := client.GetAppRelease(provider.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s release: %s", provider.Name, err)
+	}
+	env := make(map[string]string, len(res.Env))
+	for k, v := range res.Env {
+		env[k] = v
+	}
+	return &runConfig{
+		App:        appName,
+		Release:    release.ID,
+		Args:       append([]string{"bash", "-c", cmd, "--"}, args...),
+		Env:        env,
+		DisableLog: true,
+	}, nil
+}
+
+// runDatabaseCommand runs a shell command against the app's database,
+// reading stdin and writing stdout
+func runDatabaseCommand(client controller.Client, appName string, provider *ct.Provider, res *ct.Resource, cmd string, stdin io.Reader, stdout io.Writer) error {
+	config, err := databaseRunConfig(client, appName, provider, res, cmd)
+	if err != nil {
+		return err
+	}
+	if stdin == nil {
+		stdin = bytes.NewReader(nil)
+	}
+	config.Stdin = stdin
+	config.Stdout = stdout
+	return runJob(client, *config)
+}
+
+func runDatabaseConsole(client controller.Client, db *database, args []string) error {
+	appName := mustApp()
+	res, provider, err := appDatabase(client, appName, db)
+	if err != nil {
+		return err
+	}
+	config, err := databaseRunConfig(client, appName, provider, res, db.Console, args...)
+	if err != nil {
+		return err
+	}
+	for k, v := range db.ConsoleEnv {
+		config.Env[k] = v
+	}
+	config.Exit = true
+	return runJob(client, *config)
+}
+
+func runDatabaseDump(client controller.Client, db *database, filename string, quiet bool) error {
+	appName := mustApp()
+	res, provider, err := appDatabase(client, appName, db)
+	if err != nil {
+		return err
+	}
+	var dest io.Writer = os.Stdout
+	if filename != "" {
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dest = f
+	}
+	if !quiet {
+		bar := newProgressBar("dumping "+db.Provider, -1)
+		defer bar.Finish()
+		dest = io.MultiWriter(dest, bar)
+	}
+	return runDatabaseCommand(client, appName, provider, res, db.Dump, nil, dest)
+}
+
+func runDatabaseRestore(client controller.Client, db *database, filename string, quiet bool) error {
+	appName := mustApp()
+	res, provider, err := appDatabase(client, appName, db)
+	if err != nil {
+		return err
+	}
+	var src io.Reader = os.Stdin
+	size := int64(-1)
+	if filename != "" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stat, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		size = stat.Size()
+		src = f
+	}
+	if !quiet {
+		bar := newProgressBar("restoring "+db.Provider, size)
+		defer bar.Finish()
+		src = io.TeeReader(src, bar)
+	}
+	return runDatabaseCommand(client, appName, provider, res, db.Restore, src, os.Stderr)
+}
+
+func runDatabaseInfo(client controller.Client, db *database) error {
+	res, provider, err := appDatabase(client, mustApp(), db)
+	if err != nil {
+		return err
+	}
+	w := tabWriter()
+	defer w.Flush()
+	listRec(w, "Provider:", provider.Name)
+	listRec(w, "Resource:", res.ID)
+	listRec(w, "Created:", humanTime(res.CreatedAt))
+	shown := make(map[string]struct{}, len(db.InfoKeys))
+	for _, k := range db.InfoKeys {
+		shown[k] = struct{}{}
+		if v, ok := res.Env[k]; ok {
+			listRec(w, k+":", maskSecret(k, v))
+		}
+	}
+	keys := make([]string, 0, len(res.Env))
+	for k := range res.Env {
+		if _, ok := shown[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		listRec(w, k+":", maskSecret(k, res.Env[k]))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"strconv"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("deploy", runDeploy, `
+usage: weo deploy [-s <strategy>] [-t <timeout>] [--no-wait] [<release>]
+
+Deploy a release of an app, defaulting to the most recently created release.
+
+The jobs of the current release are replaced by jobs of the new release using
+the deployment strategy, which is one of:
+
+	all-at-once  start all the new jobs, then stop all the old jobs
+	one-by-one   start the new jobs one at a time, stopping an old job after
+	             each new job starts
+	blue-green   start all the new jobs, switch the app routes over to them,
+	             then stop all the old jobs
+
+The strategy and timeout default to those of the app, see 'weo help deployment'.
+
+Options:
+	-s, --strategy=<strategy>  deployment strategy to use
+	-t, --timeout=<timeout>    seconds to wait for jobs to start
+	--no-wait                  don't wait for the deployment to finish
+
+Examples:
+
+	$ weo deploy --strategy one-by-one
+	20:24:41.105 ==> deployment running
+	20:24:41.320 ==> web host0-52aedfbf-e613-40f2-941a-d832d10fc400 starting (new release)
+	20:24:41.322 ==> web host0-52aedfbf-e613-40f2-941a-d832d10fc400 up (new release)
+	20:24:41.522 ==> web host0-205595d8-206a-46a2-be30-2e98f53df272 stopping (old release)
+	20:24:41.524 ==> web host0-205595d8-206a-46a2-be30-2e98f53df272 down (old release)
+	20:24:41.530 ==> deployment complete
+
+	deployment completed in 0.4s
+`)
+}
+
+func runDeploy(args *docopt.Args, client controller.Client) error {
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+
+	var opts ct.DeployOptions
+	if strategy := args.String["--strategy"]; strategy != "" {
+		if err := ct.ValidateDeployStrategy(strategy); err != nil {
+			return err
+		}
+		opts.Strategy = strategy
+	}
+	if s := args.String["--timeout"]; s != "" {
+		timeout, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("error parsing timeout %q: %s", s, err)
+		} else if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		opts.DeployTimeout = int32(timeout)
+	}
+
+	releaseID := args.String["<release>"]
+	if releaseID == "" {
+		releases, err := client.AppReleaseList(app.ID)
+		if err != nil {
+			return err
+		}
+		if len(releases) == 0 {
+			return errors.New("app has no releases to deploy")
+		}
+		releaseID = releases[0].ID
+	}
+	if releaseID == app.ReleaseID {
+		return fmt.Errorf("release %s is already deployed", releaseID)
+	}
+
+	d, err := client.CreateDeployment(app.ID, releaseID, opts)
+	if err != nil {
+		return err
+	}
+	if args.Bool["--no-wait"] && d.FinishedAt == nil {
+		fmt.Printf("created deployment %s, use 'weo deployment watch %s' to follow it\n", d.ID, d.ID)
+		return nil
+	}
+	return watchDeployment(client, d)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"strconv"
+	"time"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("deployment", runDeployments, `
+usage: weo deployment
+       weo deployment watch <id>
+       weo deployment strategy [<strategy>]
+       weo deployment timeout [<timeout>]
+
+Manage app deployments.
+
+Commands:
+	With no arguments, shows a list of deployments
+
+	watch     streams the events of a deployment, showing each job transition
+	          until the deployment finishes. Exits non-zero if the deployment
+	          fails.
+
+	strategy  gets or sets the default strategy used to deploy the app, one of
+	          all-at-once, one-by-one or blue-green
+
+	timeout   gets or sets the number of seconds to wait for jobs to start
+	          when deploying the app
+
+The strategy and timeout can be overridden for a single deployment using the
+options of the deploy command.
+
+Examples:
+
+	$ weo deployment
+	ID                                    STATUS    STRATEGY     CREATED             DURATION
+	a6d470d6-9638-4d74-ae71-91c3d9887714  running   all-at-once  4 seconds ago
+	39f8b98b-2aed-40a5-9423-ae174b3fb7a9  complete  all-at-once  16 seconds ago      2.1s
+	f415ae79-0b41-4a49-bc42-d4f90c5a36c5  failed    one-by-one   About a minute ago  30.4s
+
+	$ weo deployment watch a6d470d6-9638-4d74-ae71-91c3d9887714
+	20:24:41.105 ==> deployment running
+	20:24:41.320 ==> web host0-52aedfbf-e613-40f2-941a-d832d10fc400 up (new release)
+	20:24:41.522 ==> web host0-205595d8-206a-46a2-be30-2e98f53df272 down (old release)
+	20:24:41.530 ==> deployment complete
+
+	deployment completed in 1.2s
+
+	$ weo deployment strategy blue-green
+
+	$ weo deployment strategy
+	blue-green
+
+	$ weo deployment timeout 150
+
+	$ weo deployment timeout
+	150
+`)
+}
+
+func runDeployments(args *docopt.Args, client controller.Client) error {
+	if args.Bool["watch"] {
+		return runDeploymentWatch(args, client)
+	} else if args.Bool["strategy"] {
+		if args.String["<strategy>"] != "" {
+			return runSetDeployStrategy(args, client)
+		}
+		return runGetDeployStrategy(args, client)
+	} else if args.Bool["timeout"] {
+		if args.String["<timeout>"] != "" {
+			return runSetDeployTimeout(args, client)
+		}
+		return runGetDeployTimeout(args, client)
+	}
+
+	deployments, err := client.DeploymentList(mustApp())
+	if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	listRec(w, "ID", "STATUS", "STRATEGY", "CREATED", "DURATION")
+	for _, d := range deployments {
+		listRec(w, d.ID, d.Status, deploymentStrategy(d.Strategy), humanTime(d.CreatedAt), deploymentDuration(d))
+	}
+	return nil
+}
+
+func runDeploymentWatch(args *docopt.Args, client controller.Client) error {
+	d, err := client.GetDeployment(args.String["<id>"])
+	if err != nil {
+		return err
+	}
+	return watchDeployment(client, d)
+}
+
+// watchDeployment prints the events of the deployment until it finishes,
+// returning an error if it fails
+func watchDeployment(client controller.Client, d *ct.Deployment) error {
+	// deployments which complete immediately (e.g. the initial deploy of
+	// an app) emit no events, so there is nothing to stream. Past events
+	// are always streamed, so failed deployments are replayed to show the
+	// error.
+	if d.FinishedAt != nil && d.Status != "failed" {
+		return deploymentResult(d.Status, nil, d)
+	}
+
+	events := make(chan *ct.DeploymentEvent)
+	stream, err := client.StreamDeployment(d, events)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	for e := range events {
+		printDeploymentEvent(d, e)
+		switch e.Status {
+		case "complete", "failed":
+			// the deployment record is updated before the final event
+			// is emitted, so refetch it to get the finish time
+			if updated, err := client.GetDeployment(d.ID); err == nil {
+				d = updated
+			}
+			return deploymentResult(e.Status, e.Err(), d)
+		}
+	}
+	return fmt.Errorf("unexpected close of deployment event stream: %s", stream.Err())
+}
+
+// deploymentResult prints the outcome of a finished deployment, returning an
+// error if it failed
+func deploymentResult(status string, err error, d *ct.Deployment) error {
+	if status == "failed" {
+		if err == nil {
+			err = errors.New("deployment failed")
+		}
+		return fmt.Errorf("deployment %s failed: %s", d.ID, err)
+	}
+	if duration := deploymentDuration(d); duration != "" {
+		fmt.Printf("\ndeployment completed in %s\n", duration)
+	} else {
+		fmt.Printf("\ndeployment %s\n", status)
+	}
+	return nil
+}
+
+// printDeploymentEvent prints either the status change or job transition
+// described by a deployment event
+func printDeploymentEvent(d *ct.Deployment, e *ct.DeploymentEvent) {
+	now := time.Now().Format("15:04:05.000")
+	if e.Status == "routes-switched" {
+		fmt.Printf("%s ==> routes switched to %s (new release)\n", now, shortID(e.ReleaseID))
+		return
+	}
+	if e.JobType == "" {
+		fmt.Printf("%s ==> deployment %s\n", now, e.Status)
+		return
+	}
+	release := "new release"
+	if e.ReleaseID == d.OldReleaseID {
+		release = "old release"
+	}
+	fmt.Printf("%s ==> %s %s %s (%s)\n", now, e.JobType, e.JobID, e.JobState, release)
+}
+
+func runGetDeployStrategy(args *docopt.Args, client controller.Client) error {
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+	fmt.Println(deploymentStrategy(app.Strategy))
+	return nil
+}
+
+func runSetDeployStrategy(args *docopt.Args, client controller.Client) error {
+	strategy := args.String["<strategy>"]
+	if err := ct.ValidateDeployStrategy(strategy); err != nil {
+		return err
+	}
+	return client.UpdateApp(&ct.App{
+		ID:       mustApp(),
+		Strategy: strategy,
+	})
+}
+
+func runGetDeployTimeout(args *docopt.Args, client controller.Client) error {
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+	fmt.Println(app.DeployTimeout)
+	return nil
+}
+
+func runSetDeployTimeout(args *docopt.Args, client controller.Client) error {
+	timeout, err := strconv.Atoi(args.String["<timeout>"])
+	if err != nil {
+		return fmt.Errorf("error parsing timeout %q: %s", args.String["<timeout>"], err)
+	} else if timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+	return client.UpdateApp(&ct.App{
+		ID:            mustApp(),
+		DeployTimeout: int32(timeout),
+	})
+}
+
+// deploymentStrategy returns the name of the given strategy, defaulting to
+// all-at-once
+func deploymentStrategy(strategy string) string {
+	if strategy == "" {
+		return "all-at-once"
+	}
+	return strategy
+}
+
+// deploymentDuration returns how long a finished deployment took
+func deploymentDuration(d *ct.Deployment) string {
+	if d.CreatedAt == nil || d.FinishedAt == nil {
+		return ""
+	}
+	return d.FinishedAt.Sub(*d.CreatedAt).Round(100 * time.Millisecond).String()
+}
+
+// shortID returns the first 8 characters of a UUID
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
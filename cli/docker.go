@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	host "github.com/flynn/flynn/host/types"
+	"github.com/flynn/go-docopt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	cfg "weo/cli/config"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("docker", runDocker, `
+usage: weo docker push <image>
+       weo docker set-push-url [<url>]
+       weo docker login
+
+Deploy Docker images to a Weo cluster.
+
+Commands:
+	push          push a Docker image to the cluster's registry and deploy it
+
+	set-push-url  set the Docker push URL (defaults to https://docker.$CLUSTER_DOMAIN)
+
+	login         run "docker login" against the cluster's registry using the
+	              cluster key
+
+The image is deployed as the "app" process type, keeping the processes, env
+and metadata of the current release. Image env variables are added to the
+release env unless already set with 'weo env set'.
+
+Example:
+
+	Assuming you have a Docker image tagged "my-custom-image:v2":
+
+	$ weo docker set-push-url https://docker.dev.localweo.com
+	$ weo docker login
+	$ weo docker push my-custom-image:v2
+	weo: getting image config with "docker inspect -f {{ json .Config }} my-custom-image:v2"
+	weo: tagging Docker image with "docker tag my-custom-image:v2 docker.dev.localweo.com/myapp:latest"
+	weo: pushing Docker image with "docker push docker.dev.localweo.com/myapp:latest"
+	The push refers to repository [docker.dev.localweo.com/myapp]
+	5f70bf18a086: Pushed
+	latest: digest: sha256:a3e1b0d8c0f1... size: 1151
+	weo: deploying release using artifact URI https://docker.dev.localweo.com?name=myapp&id=sha256:a3e1b0d8c0f1...
+	weo: image deployed, scale it with 'weo scale app=N'
+`)
+}
+
+func runDocker(args *docopt.Args, client controller.Client) error {
+	if args.Bool["set-push-url"] {
+		return runDockerSetPushURL(args)
+	} else if args.Bool["login"] {
+		return runDockerLogin()
+	} else if args.Bool["push"] {
+		return runDockerPush(args, client)
+	}
+	return errors.New("unknown docker subcommand")
+}
+
+func runDockerSetPushURL(args *docopt.Args) error {
+	cluster, err := getCluster()
+	if err != nil {
+		return err
+	}
+	pushURL := args.String["<url>"]
+	if pushURL == "" {
+		if cluster.DockerPushURL != "" {
+			return fmt.Errorf("ERROR: refusing to overwrite current Docker push URL %q with a default one. To overwrite the existing URL, set one explicitly with 'weo docker set-push-url URL'", cluster.DockerPushURL)
+		}
+		if !strings.Contains(cluster.ControllerURL, "controller") {
+			return errors.New("ERROR: unable to determine default Docker push URL, set one explicitly with 'weo docker set-push-url URL'")
+		}
+		pushURL = strings.Replace(cluster.ControllerURL, "controller", "docker", 1)
+	}
+	if !strings.Contains(pushURL, "://") {
+		pushURL = "https://" + pushURL
+	}
+	if u, err := url.Parse(pushURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("ERROR: invalid Docker push URL %q", pushURL)
+	}
+	cluster.DockerPushURL = pushURL
+	return config.SaveTo(configPath())
+}
+
+func runDockerLogin() error {
+	cluster, err := getCluster()
+	if err != nil {
+		return err
+	}
+	host, err := cluster.DockerPushHost()
+	if err != nil {
+		return err
+	}
+	err = dockerLogin(host, cluster.Key)
+	if e, ok := err.(*exec.Error); ok && e.Err == exec.ErrNotFound {
+		err = errors.New("Executable 'docker' was not found.")
+	} else if err == ErrDockerTLSError {
+		printDockerTLSWarning(host, cfg.CACertPath(cluster.Name))
+		err = errors.New("Error configuring docker, follow the above instructions and try again.")
+	}
+	return err
+}
+
+var ErrDockerTLSError = errors.New("docker TLS error")
+
+// dockerLogin runs "docker login" with the cluster key as the password
+func dockerLogin(host, key string) error {
+	cmd := exec.Command("docker", "login", "--username=user", "--password-stdin", host)
+	cmd.Stdin = strings.NewReader(key)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	switch {
+	case strings.Contains(out.String(), "certificate signed by unknown authority"):
+		return ErrDockerTLSError
+	case err != nil:
+		if _, ok := err.(*exec.Error); ok {
+			return err
+		}
+		return fmt.Errorf("error running `docker login`: %s - output: %q", err, out.String())
+	}
+	return nil
+}
+
+func printDockerTLSWarning(host, caPath string) {
+	fmt.Printf(`
+WARN: docker configuration failed with a TLS error.
+WARN:
+WARN: Copy the TLS CA certificate %s
+WARN: to /etc/docker/certs.d/%s/ca.crt
+WARN: on the docker daemon's host and restart docker.
+WARN:
+WARN: If using Docker for Mac, go to Docker -> Preferences
+WARN: -> Advanced, add %q as an
+WARN: Insecure Registry and hit "Apply & Restart".
+
+`[1:], caPath, host, host)
+}
+
+// dockerImageConfig is the subset of a Docker image config used to create
+// the release
+type dockerImageConfig struct {
+	Cmd        []string `json:"Cmd"`
+	Entrypoint []string `json:"Entrypoint"`
+	Env        []string `json:"Env"`
+}
+
+func runDockerPush(args *docopt.Args, client controller.Client) error {
+	cluster, err := getCluster()
+	if err != nil {
+		return err
+	}
+	dockerHost, err := cluster.DockerPushHost()
+	if err != nil {
+		return err
+	}
+
+	image := args.String["<image>"]
+
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+	prevRelease, err := client.GetAppRelease(app.ID)
+	if err == controller.ErrNotFound {
+		prevRelease = &ct.Release{}
+	} else if err != nil {
+		return fmt.Errorf("error getting current app release: %s", err)
+	}
+
+	// get the image config to determine Cmd, Entrypoint and Env
+	var imageConfig dockerImageConfig
+	if err := dockerInspect(image, "{{ json .Config }}", &imageConfig); err != nil {
+		return err
+	}
+
+	// tag the docker image ready to be pushed
+	repo := fmt.Sprintf("%s/%s", dockerHost, app.Name)
+	tag := repo + ":latest"
+	cmd := exec.Command("docker", "tag", image, tag)
+	log.Printf("weo: tagging Docker image with %q", strings.Join(cmd.Args, " "))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	cmd = exec.Command("docker", "push", tag)
+	log.Printf("weo: pushing Docker image with %q", strings.Join(cmd.Args, " "))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	// the registry records the digest of the pushed manifest against the
+	// tag, which identifies the image independently of later pushes
+	digest, err := dockerPushedDigest(tag, repo)
+	if err != nil {
+		return err
+	}
+	artifact := &ct.Artifact{
+		Type: ct.DeprecatedArtifactTypeDocker,
+		URI:  fmt.Sprintf("%s?name=%s&id=%s", strings.TrimSuffix(cluster.DockerPushURL, "/"), app.Name, digest),
+		Meta: map[string]string{
+			"docker-receive.repository": app.Name,
+			"docker-receive.digest":     digest,
+		},
+	}
+	if err := client.CreateArtifact(artifact); err != nil {
+		return err
+	}
+
+	// create and deploy a release with the image config and created artifact
+	log.Printf("weo: deploying release using artifact URI %s", artifact.URI)
+	release := &ct.Release{
+		ArtifactIDs: []string{artifact.ID},
+		Processes:   prevRelease.Processes,
+		Env:         prevRelease.Env,
+		Meta:        prevRelease.Meta,
+	}
+
+	proc, ok := release.Processes["app"]
+	if !ok {
+		proc = ct.ProcessType{}
+	}
+	proc.Args = append(imageConfig.Entrypoint, imageConfig.Cmd...)
+	if len(proc.Ports) == 0 {
+		proc.Service = app.Name + "-web"
+		proc.Ports = []ct.Port{{
+			Port:  8080,
+			Proto: "tcp",
+			Service: &host.Service{
+				Name:   app.Name + "-web",
+				Create: true,
+			},
+		}}
+	}
+	if release.Processes == nil {
+		release.Processes = make(map[string]ct.ProcessType, 1)
+	}
+	release.Processes["app"] = proc
+
+	if len(imageConfig.Env) > 0 && release.Env == nil {
+		release.Env = make(map[string]string, len(imageConfig.Env))
+	}
+	for _, v := range imageConfig.Env {
+		keyVal := strings.SplitN(v, "=", 2)
+		if len(keyVal) != 2 {
+			continue
+		}
+		// only set the key if it doesn't exist so variables set with
+		// `weo env set` are not overwritten
+		if _, ok := release.Env[keyVal[0]]; !ok {
+			release.Env[keyVal[0]] = keyVal[1]
+		}
+	}
+
+	if release.Meta == nil {
+		release.Meta = make(map[string]string, 1)
+	}
+	release.Meta["docker-receive"] = "true"
+
+	if err := client.CreateRelease(app.ID, release); err != nil {
+		return err
+	}
+	if err := client.DeployAppRelease(app.ID, release.ID, ct.DeployOptions{}, nil); err != nil {
+		return err
+	}
+	log.Printf("weo: image deployed, scale it with 'weo scale app=N'")
+	return nil
+}
+
+// dockerInspect decodes the JSON output of "docker inspect" for the given
+// image and format into v
+func dockerInspect(image, format string, v interface{}) error {
+	cmd := exec.Command("docker", "inspect", "-f", format, image)
+	log.Printf("weo: getting image config with %q", strings.Join(cmd.Args, " "))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(out, v); err != nil {
+		return fmt.Errorf("error decoding output of `docker inspect`: %s", err)
+	}
+	return nil
+}
+
+// dockerPushedDigest returns the manifest digest of a tag which has been
+// pushed to repo
+func dockerPushedDigest(tag, repo string) (string, error) {
+	out, err := exec.Command("docker", "inspect", "-f", "{{ json .RepoDigests }}", tag).Output()
+	if err != nil {
+		return "", fmt.Errorf("error getting pushed image digest: %s", err)
+	}
+	var digests []string
+	if err := json.Unmarshal(out, &digests); err != nil {
+		return "", fmt.Errorf("error decoding output of `docker inspect`: %s", err)
+	}
+	for _, d := range digests {
+		if strings.HasPrefix(d, repo+"@") {
+			return strings.TrimPrefix(d, repo+"@"), nil
+		}
+	}
+	return "", fmt.Errorf("no digest found for pushed image %s", tag)
+}
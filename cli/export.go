@@ -0,0 +1,800 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/docker/go-units"
+	router "github.com/flynn/flynn/router/types"
+	"github.com/flynn/go-docopt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+	tarclient "weo/tarreceive/client"
+)
+
+func init() {
+	register("export", runExport, `
+usage: weo export [-f <file>] [--no-databases] [-q]
+
+Export an app's configuration and data to a tar archive.
+
+The archive contains the app's metadata, current release, environment,
+formation, routes and image layers, along with a dump of each Postgres,
+MySQL, MongoDB and Redis database provisioned for the app. A manifest
+recording the archive version and the SHA-256 checksum of every entry is
+written last.
+
+Options:
+	-f, --file=<file>  file to export to (defaults to stdout)
+	--no-databases     don't include database dumps
+	-q, --quiet        don't print progress
+
+Examples:
+
+	$ weo -a myapp export -f myapp.tar
+	exporting app.json (243 B)
+	exporting routes.json (190 B)
+	exporting release.json (412 B)
+	exporting env.json (87 B)
+	exporting artifacts.json (1.12 kB)
+	exporting layers/4cf9d1a7...bd19.layer (52.4 MB)
+	exporting formation.json (64 B)
+	exporting postgres.dump (3.2 MB)
+	exported myapp to myapp.tar
+`)
+
+	register("import", runImport, `
+usage: weo import [-f <file>] [-n <name>] [-r] [-q]
+
+Create an app from an archive created by 'weo export'.
+
+The checksum of every entry in the archive is verified against its manifest
+before anything is created. The app is then created with the exported
+metadata, release, environment and formation, its image layers are uploaded
+and a fresh database is provisioned and restored for each included dump.
+
+The cluster being imported into need not be the one the app was exported
+from.
+
+Options:
+	-f, --file=<file>  file to import from (defaults to stdin)
+	-n, --name=<name>  name of the app to create (defaults to the exported name)
+	-r, --routes       also create the exported routes
+	-q, --quiet        don't print progress
+
+Examples:
+
+	$ weo -c staging import -f myapp.tar -n myapp-staging --routes
+	verified 9 archive entries
+	created app myapp-staging
+	uploading layers/4cf9d1a7...bd19.layer (52.4 MB)
+	provisioning postgres
+	restoring postgres.dump (3.2 MB)
+	Imported myapp-staging (added 1 routes, provisioned 1 resources)
+`)
+}
+
+// exportVersion is the version of the export archive format, incremented
+// whenever an incompatible change is made
+const exportVersion = 1
+
+const exportManifestName = "manifest.json"
+
+// exportManifest is the final entry of an export archive
+type exportManifest struct {
+	Version   int               `json:"version"`
+	App       string            `json:"app"`
+	CreatedAt time.Time         `json:"created_at"`
+	Checksums map[string]string `json:"checksums"`
+}
+
+// exportLayerName returns the archive entry name of an image layer
+func exportLayerName(id string) string {
+	return "layers/" + id + ".layer"
+}
+
+// exportWriter writes entries to an export archive, recording the checksum
+// of each one for the manifest
+type exportWriter struct {
+	tw       *tar.Writer
+	manifest exportManifest
+	quiet    bool
+}
+
+func newExportWriter(w io.Writer, app string, quiet bool) *exportWriter {
+	return &exportWriter{
+		tw: tar.NewWriter(w),
+		manifest: exportManifest{
+			Version:   exportVersion,
+			App:       app,
+			CreatedAt: time.Now().UTC(),
+			Checksums: make(map[string]string),
+		},
+		quiet: quiet,
+	}
+}
+
+func (w *exportWriter) WriteJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return w.Write(name, int64(len(data)), bytes.NewReader(data))
+}
+
+// Write writes an entry of the given size, failing if r does not contain
+// exactly that many bytes
+func (w *exportWriter) Write(name string, size int64, r io.Reader) error {
+	if !w.quiet {
+		log.Printf("exporting %s (%s)", name, units.HumanSize(float64(size)))
+	}
+	if err := w.tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  w.manifest.CreatedAt,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w.tw, h), r)
+	if err != nil {
+		return fmt.Errorf("error exporting %s: %s", name, err)
+	} else if n != size {
+		return fmt.Errorf("error exporting %s: expected %d bytes, got %d", name, size, n)
+	}
+	w.manifest.Checksums[name] = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// WriteSpooled writes an entry of unknown size by first spooling the output
+// of fn to a temporary file
+func (w *exportWriter) WriteSpooled(name string, fn func(io.Writer) error) error {
+	f, err := ioutil.TempFile("", "weo-export-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := fn(f); err != nil {
+		return fmt.Errorf("error exporting %s: %s", name, err)
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return w.Write(name, size, f)
+}
+
+// Close writes the manifest and closes the archive
+func (w *exportWriter) Close() error {
+	data, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := w.tw.WriteHeader(&tar.Header{
+		Name:     exportManifestName,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  w.manifest.CreatedAt,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	if _, err := w.tw.Write(data); err != nil {
+		return err
+	}
+	return w.tw.Close()
+}
+
+func runExport(args *docopt.Args, client controller.Client) error {
+	var dest io.Writer = os.Stdout
+	if filename := args.String["--file"]; filename != "" {
+		f, err := os.Create(filename)
+		if err != nil {
+			return fmt.Errorf("error creating export file: %s", err)
+		}
+		defer f.Close()
+		dest = f
+	}
+
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return fmt.Errorf("error getting app: %s", err)
+	}
+	w := newExportWriter(dest, app.Name, args.Bool["--quiet"])
+
+	if err := w.WriteJSON("app.json", app); err != nil {
+		return err
+	}
+
+	routes, err := client.AppRouteList(app.ID)
+	if err != nil {
+		return fmt.Errorf("error getting routes: %s", err)
+	}
+	if err := w.WriteJSON("routes.json", routes); err != nil {
+		return err
+	}
+
+	release, err := client.GetAppRelease(app.ID)
+	if err == controller.ErrNotFound {
+		// an app without a release has nothing more to export
+		return exportDone(w, app, args)
+	} else if err != nil {
+		return fmt.Errorf("error getting release: %s", err)
+	}
+	env := release.Env
+	release.Env = nil
+	if err := w.WriteJSON("release.json", release); err != nil {
+		return err
+	}
+	if err := w.WriteJSON("env.json", env); err != nil {
+		return err
+	}
+
+	artifacts := make([]*ct.Artifact, len(release.ArtifactIDs))
+	for i, id := range release.ArtifactIDs {
+		artifacts[i], err = client.GetArtifact(id)
+		if err != nil {
+			return fmt.Errorf("error getting artifact %s: %s", id, err)
+		}
+	}
+	if err := w.WriteJSON("artifacts.json", artifacts); err != nil {
+		return err
+	}
+	if err := exportLayers(w, artifacts); err != nil {
+		return err
+	}
+
+	formation, err := client.GetFormation(app.ID, release.ID)
+	if err != nil && err != controller.ErrNotFound {
+		return fmt.Errorf("error getting formation: %s", err)
+	} else if err == nil {
+		if err := w.WriteJSON("formation.json", formation); err != nil {
+			return err
+		}
+	}
+
+	if !args.Bool["--no-databases"] {
+		if err := exportDatabaseDumps(w, client, app); err != nil {
+			return err
+		}
+	}
+
+	return exportDone(w, app, args)
+}
+
+func exportDone(w *exportWriter, app *ct.App, args *docopt.Args) error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if !args.Bool["--quiet"] && args.String["--file"] != "" {
+		log.Printf("exported %s to %s", app.Name, args.String["--file"])
+	}
+	return nil
+}
+
+// exportLayers writes the layers of any Weo image artifacts, skipping layers
+// shared between artifacts
+func exportLayers(w *exportWriter, artifacts []*ct.Artifact) error {
+	var tc *tarclient.Client
+	seen := make(map[string]struct{})
+	for _, artifact := range artifacts {
+		if artifact.Type != ct.ArtifactTypeWeo {
+			continue
+		}
+		for _, rootfs := range artifact.Manifest().Rootfs {
+			for _, layer := range rootfs.Layers {
+				if _, ok := seen[layer.ID]; ok {
+					continue
+				}
+				seen[layer.ID] = struct{}{}
+				if tc == nil {
+					cluster, err := getCluster()
+					if err != nil {
+						return err
+					}
+					tc, err = cluster.TarClient()
+					if err != nil {
+						return err
+					}
+				}
+				if err := exportLayer(w, tc, layer); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func exportLayer(w *exportWriter, tc *tarclient.Client, layer *ct.ImageLayer) error {
+	name := exportLayerName(layer.ID)
+	data, err := tc.GetLayer(layer.ID, nil)
+	if err != nil {
+		return fmt.Errorf("error getting layer %s: %s", layer.ID, err)
+	}
+	defer data.Close()
+	if layer.Length > 0 {
+		return w.Write(name, layer.Length, data)
+	}
+	return w.WriteSpooled(name, func(dst io.Writer) error {
+		_, err := io.Copy(dst, data)
+		return err
+	})
+}
+
+// exportDatabaseDumps writes a dump of each database resource provisioned
+// for the app
+func exportDatabaseDumps(w *exportWriter, client controller.Client, app *ct.App) error {
+	resources, err := client.AppResourceList(app.ID)
+	if err != nil {
+		return fmt.Errorf("error getting resources: %s", err)
+	}
+	for _, db := range databases {
+		res, provider, err := findAppDatabase(client, resources, db)
+		if err != nil {
+			return err
+		} else if res == nil {
+			continue
+		}
+		err = w.WriteSpooled(db.ExportFile, func(dst io.Writer) error {
+			return runDatabaseCommand(client, app.Name, provider, res, db.Dump, nil, dst)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importEntry is an entry read from an export archive, either held in
+// memory or spooled to a temporary file
+type importEntry struct {
+	name string
+	size int64
+	data []byte
+	file *os.File
+}
+
+// Open returns a reader for the entry data
+func (e *importEntry) Open() (io.ReadSeeker, error) {
+	if e.file == nil {
+		return bytes.NewReader(e.data), nil
+	}
+	if _, err := e.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return e.file, nil
+}
+
+func (e *importEntry) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.data, v); err != nil {
+		return fmt.Errorf("error decoding %s: %s", e.name, err)
+	}
+	return nil
+}
+
+// importArchive is the verified contents of an export archive
+type importArchive struct {
+	manifest exportManifest
+	entries  map[string]*importEntry
+}
+
+// readImportArchive reads every entry of an export archive, verifying each
+// against the checksums in the manifest
+func readImportArchive(r io.Reader) (*importArchive, error) {
+	a := &importArchive{entries: make(map[string]*importEntry)}
+	checksums := make(map[string]string)
+	var manifest *importEntry
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("error reading export archive: %s", err)
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if _, ok := a.entries[name]; ok || name == exportManifestName && manifest != nil {
+			a.Close()
+			return nil, fmt.Errorf("export archive contains duplicate entry %s", name)
+		}
+		entry, sum, err := readImportEntry(name, header.Size, tr)
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		if name == exportManifestName {
+			manifest = entry
+			continue
+		}
+		a.entries[name] = entry
+		checksums[name] = sum
+	}
+
+	if manifest == nil {
+		a.Close()
+		return nil, errors.New("export archive is missing its manifest")
+	}
+	if err := manifest.Decode(&a.manifest); err != nil {
+		a.Close()
+		return nil, err
+	}
+	if err := a.verify(checksums); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func readImportEntry(name string, size int64, r io.Reader) (*importEntry, string, error) {
+	entry := &importEntry{name: name, size: size}
+	h := sha256.New()
+	if path.Ext(name) == ".json" {
+		var buf bytes.Buffer
+		if _, err := io.Copy(io.MultiWriter(&buf, h), r); err != nil {
+			return nil, "", fmt.Errorf("error reading %s: %s", name, err)
+		}
+		entry.data = buf.Bytes()
+		return entry, hex.EncodeToString(h.Sum(nil)), nil
+	}
+	f, err := ioutil.TempFile("", "weo-import-")
+	if err != nil {
+		return nil, "", err
+	}
+	os.Remove(f.Name())
+	entry.file = f
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		f.Close()
+		return nil, "", fmt.Errorf("error reading %s: %s", name, err)
+	}
+	return entry, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (a *importArchive) verify(checksums map[string]string) error {
+	if a.manifest.Version < 1 || a.manifest.Version > exportVersion {
+		return fmt.Errorf("unsupported export archive version %d (supported versions: 1-%d)", a.manifest.Version, exportVersion)
+	}
+	var errs []string
+	for _, name := range sortedKeys(a.manifest.Checksums) {
+		sum, ok := checksums[name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: missing from archive", name))
+		} else if sum != a.manifest.Checksums[name] {
+			errs = append(errs, fmt.Sprintf("%s: checksum mismatch (expected %s, got %s)", name, a.manifest.Checksums[name], sum))
+		}
+	}
+	for _, name := range sortedKeys(checksums) {
+		if _, ok := a.manifest.Checksums[name]; !ok {
+			errs = append(errs, fmt.Sprintf("%s: not in manifest", name))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("export archive failed verification:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+// Decode decodes the named JSON entry into v, returning false if the archive
+// has no such entry
+func (a *importArchive) Decode(name string, v interface{}) (bool, error) {
+	entry, ok := a.entries[name]
+	if !ok {
+		return false, nil
+	}
+	return true, entry.Decode(v)
+}
+
+func (a *importArchive) Close() {
+	for _, entry := range a.entries {
+		if entry.file != nil {
+			entry.file.Close()
+		}
+	}
+}
+
+func runImport(args *docopt.Args, client controller.Client) error {
+	var src io.Reader = os.Stdin
+	if filename := args.String["--file"]; filename != "" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return fmt.Errorf("error opening export file: %s", err)
+		}
+		defer f.Close()
+		src = f
+	}
+	quiet := args.Bool["--quiet"]
+
+	archive, err := readImportArchive(src)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	if !quiet {
+		log.Printf("verified %d archive entries", len(archive.entries)+1)
+	}
+
+	app := &ct.App{}
+	if ok, err := archive.Decode("app.json", app); err != nil {
+		return err
+	} else if !ok {
+		return errors.New("export archive is missing app.json")
+	}
+	oldName := app.Name
+	app.ID = ""
+	app.ReleaseID = ""
+	app.CreatedAt = nil
+	app.UpdatedAt = nil
+	if name := args.String["--name"]; name != "" {
+		app.Name = name
+	}
+	if err := client.CreateApp(app); err != nil {
+		return fmt.Errorf("error creating app: %s", err)
+	}
+	if !quiet {
+		log.Printf("created app %s", app.Name)
+	}
+
+	var numResources int
+	release := &ct.Release{}
+	hasRelease, err := archive.Decode("release.json", release)
+	if err != nil {
+		return err
+	}
+	if hasRelease {
+		var env map[string]string
+		if _, err := archive.Decode("env.json", &env); err != nil {
+			return err
+		}
+		var artifacts []*ct.Artifact
+		if _, err := archive.Decode("artifacts.json", &artifacts); err != nil {
+			return err
+		}
+		if err := importLayers(archive, artifacts, quiet); err != nil {
+			return err
+		}
+		cluster, err := getCluster()
+		if err != nil {
+			return err
+		}
+		imageURL := strings.TrimSuffix(cluster.ImageURL, "/")
+
+		// databases are provisioned before the release is created so that
+		// the release env refers to the new resources
+		for _, db := range databases {
+			entry, ok := archive.entries[db.ExportFile]
+			if !ok {
+				continue
+			}
+			resEnv, err := importDatabase(client, app, db, entry, quiet)
+			if err != nil {
+				return err
+			}
+			if env == nil {
+				env = make(map[string]string, len(resEnv))
+			}
+			for k, v := range resEnv {
+				env[k] = v
+			}
+			numResources++
+		}
+
+		release.ID = ""
+		release.AppID = ""
+		release.CreatedAt = nil
+		release.LegacyArtifactID = ""
+		release.Env = env
+		release.ArtifactIDs = make([]string, len(artifacts))
+		for i, artifact := range artifacts {
+			artifact.ID = ""
+			artifact.CreatedAt = nil
+			if artifact.Type == ct.ArtifactTypeWeo {
+				// the layers were uploaded to this cluster, so pull the
+				// image from here rather than from the exporting cluster
+				artifact.URI = fmt.Sprintf("%s/images/%s.json", imageURL, artifact.Manifest().ID())
+				artifact.LayerURLTemplate = imageURL + "/layer/{id}"
+			}
+			if err := client.CreateArtifact(artifact); err != nil {
+				return fmt.Errorf("error creating artifact: %s", err)
+			}
+			release.ArtifactIDs[i] = artifact.ID
+		}
+		for typ, proc := range release.Processes {
+			for i, port := range proc.Ports {
+				if port.Service != nil {
+					proc.Ports[i].Service.Name = importServiceName(port.Service.Name, oldName, app.Name)
+				}
+			}
+			if proc.Service != "" {
+				proc.Service = importServiceName(proc.Service, oldName, app.Name)
+			}
+			release.Processes[typ] = proc
+		}
+		if err := client.CreateRelease(app.ID, release); err != nil {
+			return fmt.Errorf("error creating release: %s", err)
+		}
+		if err := client.SetAppRelease(app.ID, release.ID); err != nil {
+			return fmt.Errorf("error setting app release: %s", err)
+		}
+
+		formation := &ct.Formation{}
+		if ok, err := archive.Decode("formation.json", formation); err != nil {
+			return err
+		} else if ok {
+			formation.AppID = app.ID
+			formation.ReleaseID = release.ID
+			formation.CreatedAt = nil
+			formation.UpdatedAt = nil
+			if err := client.PutFormation(formation); err != nil {
+				return fmt.Errorf("error creating formation: %s", err)
+			}
+		}
+	}
+
+	var numRoutes int
+	if args.Bool["--routes"] {
+		var routes []*router.Route
+		if _, err := archive.Decode("routes.json", &routes); err != nil {
+			return err
+		}
+		existing, err := client.RouteList()
+		if err != nil {
+			return fmt.Errorf("error getting routes: %s", err)
+		}
+		conflicts := make(map[string]struct{}, len(existing))
+		for _, route := range existing {
+			conflicts[importRouteKey(route)] = struct{}{}
+		}
+		for _, route := range routes {
+			// routes may already exist when importing into the cluster
+			// the app was exported from
+			if _, ok := conflicts[importRouteKey(route)]; ok {
+				if !quiet {
+					log.Printf("skipping route %s, a conflicting route already exists", importRouteKey(route))
+				}
+				continue
+			}
+			route.ID = ""
+			route.ParentRef = ""
+			route.CreatedAt = time.Time{}
+			route.UpdatedAt = time.Time{}
+			route.Service = importServiceName(route.Service, oldName, app.Name)
+			if route.Certificate != nil {
+				route.Certificate.ID = ""
+			}
+			if err := client.CreateRoute(app.ID, route); err != nil {
+				return fmt.Errorf("error creating route %s: %s", importRouteKey(route), err)
+			}
+			numRoutes++
+		}
+	}
+
+	log.Printf("Imported %s (added %d routes, provisioned %d resources)", app.Name, numRoutes, numResources)
+	return nil
+}
+
+// importRouteKey returns a string which is equal for conflicting routes
+func importRouteKey(route *router.Route) string {
+	if route.Type == "tcp" {
+		return fmt.Sprintf("tcp:%d", route.Port)
+	}
+	p := route.Path
+	if p == "" {
+		p = "/"
+	}
+	return route.Type + ":" + route.Domain + p
+}
+
+// importServiceName renames a service named after the exported app
+func importServiceName(name, oldName, newName string) string {
+	if name == oldName || strings.HasPrefix(name, oldName+"-") {
+		return newName + strings.TrimPrefix(name, oldName)
+	}
+	return name
+}
+
+// importLayers uploads the layers of any Weo image artifacts
+func importLayers(archive *importArchive, artifacts []*ct.Artifact, quiet bool) error {
+	var names []string
+	for _, artifact := range artifacts {
+		if artifact.Type != ct.ArtifactTypeWeo {
+			continue
+		}
+		for _, rootfs := range artifact.Manifest().Rootfs {
+			for _, layer := range rootfs.Layers {
+				names = append(names, layer.ID)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	cluster, err := getCluster()
+	if err != nil {
+		return err
+	}
+	tc, err := cluster.TarClient()
+	if err != nil {
+		return err
+	}
+	for i, id := range names {
+		if i > 0 && id == names[i-1] {
+			continue
+		}
+		entry, ok := archive.entries[exportLayerName(id)]
+		if !ok {
+			return fmt.Errorf("export archive is missing layer %s", id)
+		}
+		if !quiet {
+			log.Printf("uploading %s (%s)", entry.name, units.HumanSize(float64(entry.size)))
+		}
+		data, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		if err := tc.PutLayer(id, data, nil); err != nil {
+			return fmt.Errorf("error uploading layer %s: %s", id, err)
+		}
+	}
+	return nil
+}
+
+// importDatabase provisions a database for the app and restores the dump
+// into it, returning the env of the new resource
+func importDatabase(client controller.Client, app *ct.App, db *database, entry *importEntry, quiet bool) (map[string]string, error) {
+	if !quiet {
+		log.Printf("provisioning %s", db.Provider)
+	}
+	provider, err := client.GetProvider(db.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s provider: %s", db.Provider, err)
+	}
+	config := json.RawMessage(`{}`)
+	res, err := client.ProvisionResource(&ct.ResourceReq{
+		ProviderID: provider.ID,
+		Apps:       []string{app.ID},
+		Config:     &config,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error provisioning %s: %s", db.Provider, err)
+	}
+
+	if !quiet {
+		log.Printf("restoring %s (%s)", entry.name, units.HumanSize(float64(entry.size)))
+	}
+	data, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	var stdout io.Writer = os.Stderr
+	if quiet {
+		stdout = ioutil.Discard
+	}
+	if err := runDatabaseCommand(client, app.Name, provider, res, db.Restore, data, stdout); err != nil {
+		return nil, fmt.Errorf("error restoring %s: %s", db.Provider, err)
+	}
+	return res.Env, nil
+}
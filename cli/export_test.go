@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestImportServiceName(t *testing.T) {
+	for _, test := range []struct {
+		name, want string
+	}{
+		{name: "myapp", want: "newapp"},
+		{name: "myapp-web", want: "newapp-web"},
+		{name: "myapp-web-internal", want: "newapp-web-internal"},
+		{name: "myapp2-web", want: "myapp2-web"},
+		{name: "myappweb", want: "myappweb"},
+		{name: "other-web", want: "other-web"},
+		{name: "web-myapp", want: "web-myapp"},
+	} {
+		if got := importServiceName(test.name, "myapp", "newapp"); got != test.want {
+			t.Errorf("importServiceName(%q): expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	router "github.com/flynn/flynn/router/types"
+	"github.com/flynn/go-docopt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	cfg "weo/cli/config"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("info", runInfo, `
+usage: weo info [--json]
+
+Show a summary of the app: its URLs, current release and deployment, the
+scale of each process type against its running jobs, attached resources and
+metadata.
+
+If the global --selector option is given without -a, a summary of each app
+matching the selector is shown.
+
+Options:
+	--json  print the summary in JSON format
+
+Examples:
+
+	$ weo info
+	Name:        myapp
+	ID:          5a2c7a3e-9f4b-4d1c-8e6a-3b2f1c0d9e8a
+	Created:     3 days ago
+	Git URL:     https://git.dev.localweo.com/myapp.git
+	Web URL:     http://myapp.dev.localweo.com
+	Release:     cf39a906-38d1-4393-a6b1-8ad2befe8142 (2 hours ago)
+	Deployment:  0d5d1b8a-7c4e-4f3a-9b2e-6a1c8d7f5e4b complete (2 hours ago)
+
+	PROCESS  SCALE  RUNNING
+	web      2      2
+	worker   1      0
+
+	RESOURCE                              PROVIDER  CREATED
+	1c3f3f5a-1e2b-4f0d-b1a4-9d4c7c1d0a5e  postgres  3 days ago
+
+	META[team]:  payments
+`)
+}
+
+// appInfo is the summary of an app printed by 'weo info'
+type appInfo struct {
+	App        *ct.App            `json:"app"`
+	GitURL     string             `json:"git_url"`
+	WebURL     string             `json:"web_url,omitempty"`
+	Release    *appInfoRelease    `json:"release,omitempty"`
+	Deployment *ct.Deployment     `json:"deployment,omitempty"`
+	Processes  []*appInfoProcess  `json:"processes"`
+	Routes     []*router.Route    `json:"routes"`
+	Resources  []*appInfoResource `json:"resources"`
+}
+
+// appInfoRelease identifies the app's current release, leaving out its env
+// which may contain secrets
+type appInfoRelease struct {
+	ID        string     `json:"id"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type appInfoProcess struct {
+	Type    string `json:"type"`
+	Scale   int    `json:"scale"`
+	Running int    `json:"running"`
+}
+
+type appInfoResource struct {
+	ID        string     `json:"id"`
+	Provider  string     `json:"provider"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+func runInfo(args *docopt.Args, client controller.Client) error {
+	var apps []*ct.App
+	if flagSelector != nil && flagApp == "" {
+		var err error
+		apps, err = selectedApps(client)
+		if err != nil {
+			return err
+		}
+	} else {
+		app, err := client.GetApp(mustApp())
+		if err != nil {
+			return err
+		}
+		apps = []*ct.App{app}
+	}
+
+	cluster, err := getCluster()
+	if err != nil {
+		return err
+	}
+	infos := make([]*appInfo, len(apps))
+	for i, app := range apps {
+		infos[i], err = getAppInfo(client, cluster, app)
+		if err != nil {
+			return err
+		}
+	}
+
+	if args.Bool["--json"] {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if flagSelector != nil && flagApp == "" {
+			return enc.Encode(infos)
+		}
+		return enc.Encode(infos[0])
+	}
+	for i, info := range infos {
+		if i > 0 {
+			fmt.Println()
+		}
+		printAppInfo(info)
+	}
+	return nil
+}
+
+func getAppInfo(client controller.Client, cluster *cfg.Cluster, app *ct.App) (*appInfo, error) {
+	info := &appInfo{
+		App:       app,
+		GitURL:    gitURL(cluster, app.Name),
+		Processes: []*appInfoProcess{},
+		Resources: []*appInfoResource{},
+	}
+
+	// GetAppRelease returns an empty release along with ErrNotFound
+	release, err := client.GetAppRelease(app.ID)
+	if err == controller.ErrNotFound {
+		release = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting release of %s: %s", app.Name, err)
+	}
+	if release != nil {
+		info.Release = &appInfoRelease{ID: release.ID, CreatedAt: release.CreatedAt}
+
+		formation, err := client.GetFormation(app.ID, release.ID)
+		if err != nil && err != controller.ErrNotFound {
+			return nil, fmt.Errorf("error getting formation of %s: %s", app.Name, err)
+		}
+		jobs, err := client.JobList(app.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting jobs of %s: %s", app.Name, err)
+		}
+		info.Processes = appInfoProcesses(release, formation, jobs)
+	}
+
+	deployments, err := client.DeploymentList(app.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting deployments of %s: %s", app.Name, err)
+	}
+	for _, d := range deployments {
+		if info.Deployment == nil || d.CreatedAt != nil && info.Deployment.CreatedAt != nil && d.CreatedAt.After(*info.Deployment.CreatedAt) {
+			info.Deployment = d
+		}
+	}
+
+	routes, err := client.AppRouteList(app.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting routes of %s: %s", app.Name, err)
+	}
+	info.Routes = make([]*router.Route, len(routes))
+	for i, r := range routes {
+		// leave out TLS private keys
+		route := *r
+		route.LegacyTLSKey = ""
+		if r.Certificate != nil {
+			cert := *r.Certificate
+			cert.Key = ""
+			route.Certificate = &cert
+		}
+		info.Routes[i] = &route
+	}
+	info.WebURL = appWebURL(app, info.Routes)
+
+	resources, err := client.AppResourceList(app.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting resources of %s: %s", app.Name, err)
+	}
+	for _, res := range resources {
+		provider, err := client.GetProvider(res.ProviderID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting provider %s: %s", res.ProviderID, err)
+		}
+		info.Resources = append(info.Resources, &appInfoResource{
+			ID:        res.ID,
+			Provider:  provider.Name,
+			CreatedAt: res.CreatedAt,
+		})
+	}
+	return info, nil
+}
+
+// appInfoProcesses returns the scale of each process type of the release
+// along with the number of its jobs which are up
+func appInfoProcesses(release *ct.Release, formation *ct.Formation, jobs []*ct.Job) []*appInfoProcess {
+	procs := make(map[string]*appInfoProcess, len(release.Processes))
+	for typ := range release.Processes {
+		procs[typ] = &appInfoProcess{Type: typ}
+	}
+	if formation != nil {
+		for typ, n := range formation.Processes {
+			if p, ok := procs[typ]; ok {
+				p.Scale = n
+			}
+		}
+	}
+	for _, job := range jobs {
+		if job.ReleaseID != release.ID || job.State != ct.JobStateUp {
+			continue
+		}
+		if p, ok := procs[job.Type]; ok {
+			p.Running++
+		}
+	}
+	list := make([]*appInfoProcess, 0, len(procs))
+	for _, p := range procs {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type < list[j].Type })
+	return list
+}
+
+// appWebURL returns the URL of the app's HTTP route for its default web
+// service, preferring HTTPS
+func appWebURL(app *ct.App, routes []*router.Route) string {
+	var url string
+	for _, r := range routes {
+		if r.Type != "http" || r.Service != app.Name+"-web" {
+			continue
+		}
+		scheme := "http"
+		if r.Certificate != nil || r.LegacyTLSCert != "" {
+			scheme = "https"
+		}
+		u := fmt.Sprintf("%s://%s%s", scheme, r.Domain, strings.TrimSuffix(r.Path, "/"))
+		if url == "" || scheme == "https" && !strings.HasPrefix(url, "https") {
+			url = u
+		}
+	}
+	return url
+}
+
+func printAppInfo(info *appInfo) {
+	w := tabWriter()
+	listRec(w, "Name:", info.App.Name)
+	listRec(w, "ID:", info.App.ID)
+	listRec(w, "Created:", humanTime(info.App.CreatedAt))
+	listRec(w, "Git URL:", info.GitURL)
+	if info.WebURL != "" {
+		listRec(w, "Web URL:", info.WebURL)
+	}
+	if info.Release != nil {
+		listRec(w, "Release:", fmt.Sprintf("%s (%s)", info.Release.ID, humanTime(info.Release.CreatedAt)))
+	} else {
+		listRec(w, "Release:", "none")
+	}
+	if d := info.Deployment; d != nil {
+		listRec(w, "Deployment:", fmt.Sprintf("%s %s (%s)", d.ID, d.Status, humanTime(d.CreatedAt)))
+	}
+	w.Flush()
+
+	if len(info.Processes) > 0 {
+		fmt.Println()
+		w = tabWriter()
+		listRec(w, "PROCESS", "SCALE", "RUNNING")
+		for _, p := range info.Processes {
+			listRec(w, p.Type, p.Scale, p.Running)
+		}
+		w.Flush()
+	}
+
+	if len(info.Resources) > 0 {
+		fmt.Println()
+		w = tabWriter()
+		listRec(w, "RESOURCE", "PROVIDER", "CREATED")
+		for _, r := range info.Resources {
+			listRec(w, r.ID, r.Provider, humanTime(r.CreatedAt))
+		}
+		w.Flush()
+	}
+
+	if len(info.App.Meta) > 0 {
+		fmt.Println()
+		w = tabWriter()
+		for _, k := range sortedKeys(info.App.Meta) {
+			listRec(w, fmt.Sprintf("META[%s]:", k), info.App.Meta[k])
+		}
+		w.Flush()
+	}
+}
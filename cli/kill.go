@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"log"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("kill", runKill, `
+usage: weo kill <job>...
+       weo kill -t <type>
+
+Kill running jobs.
+
+Options:
+	-t, --type=<type>  Kill all running jobs of process type <type>
+
+Examples:
+
+	$ weo kill host0-52aedfbf-e613-40f2-941a-d832d10fc400
+	Job host0-52aedfbf-e613-40f2-941a-d832d10fc400 killed.
+
+	$ weo kill --type web
+	Job host0-52aedfbf-e613-40f2-941a-d832d10fc400 killed.
+	Job host0-205595d8-206a-46a2-be30-2e98f53df272 killed.
+`)
+}
+
+func runKill(args *docopt.Args, client controller.Client) error {
+	appName := mustApp()
+
+	ids := args.All["<job>"].([]string)
+	if typ := args.String["--type"]; typ != "" {
+		jobs, err := client.JobList(appName)
+		if err != nil {
+			return err
+		}
+		for _, j := range jobs {
+			if j.Type == typ && (j.State == ct.JobStateUp || j.State == ct.JobStateStarting || j.State == ct.JobStatePending) {
+				ids = append(ids, jobID(j))
+			}
+		}
+		if len(ids) == 0 {
+			return fmt.Errorf("No running jobs of type %q.", typ)
+		}
+	}
+
+	success := true
+	for _, job := range ids {
+		if err := client.DeleteJob(appName, job); err != nil {
+			success = false
+			log.Printf("ERROR: could not kill job %s: %s\n", job, err)
+			continue
+		}
+		log.Printf("Job %s killed.", job)
+	}
+	if !success {
+		return errors.New("Could not kill all jobs.")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"github.com/flynn/flynn/host/resource"
+	"github.com/flynn/go-docopt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("limit", runLimit, `
+usage: weo limit [-t <proc>]
+       weo limit set <proc> <var>=<val>...
+
+Manage app resource limits.
+
+Options:
+	-t, --process-type=<proc>  set or read limits for specified process type
+
+Commands:
+	With no arguments, shows a list of resource limits.
+
+	set    sets value of one or more resource limits, creating and deploying
+	       a new release
+
+Limits:
+	memory     memory limit with a unit, e.g. 512MB or 1GB
+	cpu        CPU shares in milli-CPUs, e.g. 500 for half a CPU
+	max_fd     maximum number of open file descriptors
+	temp_disk  size of the temporary disk with a unit, e.g. 200MB
+
+Examples:
+
+	$ weo limit
+	web:     cpu=1000  temp_disk=100MB  max_fd=10000  memory=1GB
+	worker:  cpu=1000  temp_disk=100MB  max_fd=10000  memory=1GB
+
+	$ weo limit set web memory=512MB max_fd=12000 cpu=500 temp_disk=200MB
+	Created release 5058ae79-64f7-4c39-9a24-0bdd6e7d1bcb
+
+	$ weo limit
+	web:     cpu=500   temp_disk=200MB  max_fd=12000  memory=512MB
+	worker:  cpu=1000  temp_disk=100MB  max_fd=10000  memory=1GB
+
+	$ weo limit set web memory=256MB
+	Created release b39fe25d-0ea3-44b6-b2af-5cf4d6542a80
+
+	$ weo limit
+	web:     cpu=500   temp_disk=200MB  max_fd=12000  memory=256MB
+	worker:  cpu=1000  temp_disk=100MB  max_fd=10000  memory=1GB
+`)
+}
+
+func runLimit(args *docopt.Args, client controller.Client) error {
+	if args.Bool["set"] {
+		return runLimitSet(args, client)
+	}
+
+	release, err := client.GetAppRelease(mustApp())
+	if err == controller.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	if procType := args.String["--process-type"]; procType != "" {
+		t, ok := release.Processes[procType]
+		if !ok {
+			return fmt.Errorf("unknown process type %q", procType)
+		}
+		formatLimits(w, procType, t.Resources)
+		return nil
+	}
+
+	types := make([]string, 0, len(release.Processes))
+	for typ := range release.Processes {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		formatLimits(w, typ, release.Processes[typ].Resources)
+	}
+	return nil
+}
+
+func formatLimits(w io.Writer, s string, r resource.Resources) {
+	// processes without explicit limits run with the defaults
+	if r == nil {
+		r = resource.Defaults()
+	}
+	limits := make([]string, 0, len(r))
+	for typ, spec := range r {
+		if limit := spec.Limit; limit != nil {
+			limits = append(limits, fmt.Sprintf("%s=%s", typ, resource.FormatLimit(typ, *limit)))
+		}
+	}
+	sort.Strings(limits)
+	fmt.Fprintf(w, "%s:\t%s\n", s, strings.Join(limits, "\t"))
+}
+
+func runLimitSet(args *docopt.Args, client controller.Client) error {
+	proc := args.String["<proc>"]
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+	release, err := client.GetAppRelease(app.ID)
+	if err == controller.ErrNotFound {
+		release = &ct.Release{}
+	} else if err != nil {
+		return err
+	}
+
+	if release.Processes == nil {
+		release.Processes = make(map[string]ct.ProcessType)
+	}
+	t, ok := release.Processes[proc]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Warning: %q is not an existing process type, setting anyway\n", proc)
+	}
+	if t.Resources == nil {
+		t.Resources = resource.Defaults()
+	}
+
+	resources, err := resource.Parse(args.All["<var>=<val>"].([]string))
+	if err != nil {
+		return err
+	}
+	for typ, limit := range resources {
+		t.Resources[typ] = limit
+	}
+	release.Processes[proc] = t
+
+	release.ID = ""
+	if err := client.CreateRelease(app.ID, release); err != nil {
+		return err
+	}
+	if err := client.DeployAppRelease(app.ID, release.ID, ct.DeployOptions{}, nil); err != nil {
+		return err
+	}
+	fmt.Printf("Created release %s\n", release.ID)
+	return nil
+}
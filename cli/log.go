@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	logaggc "github.com/flynn/flynn/logaggregator/client"
+	logagg "github.com/flynn/flynn/logaggregator/types"
+	"github.com/flynn/flynn/pkg/term"
+	"github.com/flynn/go-docopt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+	controller "weo/controller/client"
+	"weo/pkg/attempt"
+)
+
+func init() {
+	register("log", runLog, `
+usage: weo log [-f] [-j <id>] [-n <lines>] [-r] [-s] [-t <type>] [-i] [--json]
+
+Stream log for an app.
+
+Lines are prefixed with the time they were emitted and the source, process
+type and job which emitted them, colored by job when writing to a terminal.
+
+When following the log, the connection to the controller is re-established if
+it drops, so following survives controller restarts (lines emitted while
+disconnected are not replayed).
+
+Options:
+	-f, --follow               stream new lines
+	-j, --job=<id>             filter logs to a specific job ID
+	-n, --number=<lines>       return at most n lines from the log buffer
+	-r, --raw-output           output raw log messages with no prefix
+	-s, --split-stderr         send stderr lines to stderr
+	-t, --process-type=<type>  filter logs to a specific process type
+	-i, --init                 output containerinit logs to stderr
+	--json                     output log messages as JSON objects, one per line
+
+Examples:
+
+	$ weo log -n 2
+	2020-03-28T20:24:41.105113Z app[web.host0-1c2a2bdb-6c0e-4d5e-8a61-e6a4c1e7d3d5]: Listening on 8080
+	2020-03-28T20:24:44.319882Z app[web.host0-1c2a2bdb-6c0e-4d5e-8a61-e6a4c1e7d3d5]: GET / 200
+`)
+}
+
+// like time.RFC3339Nano except it only goes to 6 decimals and doesn't drop
+// trailing zeros
+const rfc3339micro = "2006-01-02T15:04:05.000000Z07:00"
+
+// logReconnectAttempts is the strategy used to reconnect to the log stream
+// when following and the connection drops
+var logReconnectAttempts = attempt.Strategy{
+	Total: 5 * time.Minute,
+	Delay: time.Second,
+}
+
+// logColors are the ANSI colors used for job prefixes
+var logColors = []string{"36", "33", "32", "35", "34", "96", "93", "92", "95", "94"}
+
+func runLog(args *docopt.Args, client controller.Client) error {
+	appName := mustApp()
+
+	opts := logagg.LogOpts{
+		Follow: args.Bool["--follow"],
+		JobID:  args.String["--job"],
+		StreamTypes: []logagg.StreamType{
+			logagg.StreamTypeStdout,
+			logagg.StreamTypeStderr,
+		},
+	}
+	if ptype := args.String["--process-type"]; ptype != "" {
+		opts.ProcessType = &ptype
+	}
+	if strlines := args.String["--number"]; strlines != "" {
+		lines, err := strconv.Atoi(strlines)
+		if err != nil {
+			return err
+		}
+		opts.Lines = &lines
+	}
+	if args.Bool["--init"] {
+		opts.StreamTypes = append(opts.StreamTypes, logagg.StreamTypeInit)
+	}
+
+	p := &logPrinter{
+		raw:    args.Bool["--raw-output"],
+		json:   args.Bool["--json"],
+		color:  term.IsTerminal(os.Stdout.Fd()),
+		stdout: os.Stdout,
+		stderr: os.Stdout,
+		init:   ioutil.Discard,
+		colors: make(map[string]string),
+	}
+	if args.Bool["--split-stderr"] {
+		p.stderr = os.Stderr
+	}
+	if args.Bool["--init"] {
+		p.init = os.Stderr
+	}
+
+	rc, err := client.GetAppLog(appName, &opts)
+	if err != nil {
+		return err
+	}
+	for {
+		err := printLog(rc, p, opts.Follow)
+		rc.Close()
+		if !opts.Follow {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "log stream disconnected (%s), reconnecting...\n", err)
+
+		// only stream new lines after reconnecting so the buffered lines
+		// are not printed again
+		lines := 0
+		opts.Lines = &lines
+		if err := logReconnectAttempts.Run(func() (err error) {
+			rc, err = client.GetAppLog(appName, &opts)
+			return
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// printLog prints the log messages read from r until it ends, returning
+// io.ErrUnexpectedEOF if a followed log ends.
+func printLog(r io.Reader, p *logPrinter, follow bool) error {
+	dec := json.NewDecoder(r)
+	for {
+		var data json.RawMessage
+		err := dec.Decode(&data)
+		if err == io.EOF {
+			if follow {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := p.print(data); err != nil {
+			return err
+		}
+	}
+}
+
+type logPrinter struct {
+	raw    bool
+	json   bool
+	color  bool
+	stdout io.Writer
+	stderr io.Writer
+	init   io.Writer
+
+	// colors maps job IDs to their prefix color
+	colors map[string]string
+}
+
+func (p *logPrinter) print(data json.RawMessage) error {
+	var msg logaggc.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+
+	var stream io.Writer
+	switch msg.Stream {
+	case logagg.StreamTypeStdout:
+		stream = p.stdout
+	case logagg.StreamTypeStderr:
+		stream = p.stderr
+	case logagg.StreamTypeInit:
+		stream = p.init
+	default:
+		return nil
+	}
+
+	switch {
+	case p.json:
+		fmt.Fprintln(stream, string(data))
+	case p.raw:
+		fmt.Fprintln(stream, msg.Msg)
+	default:
+		prefix := fmt.Sprintf("%s[%s.%s]:", msg.Source, msg.ProcessType, msg.JobID)
+		if p.color {
+			prefix = fmt.Sprintf("\033[%sm%s\033[0m", p.jobColor(msg.JobID), prefix)
+		}
+		fmt.Fprintf(stream, "%s %s %s\n", msg.Timestamp.Format(rfc3339micro), prefix, msg.Msg)
+	}
+	return nil
+}
+
+// jobColor returns the color for the given job, assigning the next color to
+// jobs which have not been seen before
+func (p *logPrinter) jobColor(jobID string) string {
+	c, ok := p.colors[jobID]
+	if !ok {
+		c = logColors[len(p.colors)%len(logColors)]
+		p.colors[jobID] = c
+	}
+	return c
+}
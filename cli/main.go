@@ -20,8 +20,9 @@ import (
 )
 
 var (
-	flagCluster = os.Getenv("WEO_CLUSTER")
-	flagApp     string
+	flagCluster  = os.Getenv("WEO_CLUSTER")
+	flagApp      string
+	flagSelector map[string]string
 )
 
 func main() {
@@ -30,11 +31,12 @@ func main() {
 	log.SetFlags(0)
 
 	usage := `
-usage: weo [-a <app>] [-c <cluster>] <command> [<args>...]
+usage: weo [-a <app>] [-c <cluster>] [--selector <selector>] <command> [<args>...]
 
 Options:
 	-a <app>
 	-c <cluster>
+	--selector <selector>  only include apps with matching meta, e.g. team=web,env=prod
 	-h, --help
 
 Commands:
@@ -110,16 +112,20 @@ See 'weo help <command>' for more information on a specific command.
 		flagCluster = args.String["-c"]
 	}
 
+	if selector := args.String["--selector"]; selector != "" {
+		var err error
+		flagSelector, err = parseSelector(selector)
+		if err != nil {
+			shutdown.Fatal(err)
+		}
+	}
+
 	flagApp = args.String["-a"]
 	if flagApp != "" {
 		if err := readConfig(); err != nil {
 			shutdown.Fatal(err)
 		}
-
-		if ra, err := appFromGitRemote(flagApp); err != nil {
-			clusterConf = ra.Cluster
-			flagApp = ra.Name
-		}
+		resolveAppFlag()
 	}
 
 	if err := runCommand(cmd, cmdArgs); err != nil {
@@ -129,6 +135,15 @@ See 'weo help <command>' for more information on a specific command.
 	}
 }
 
+// resolveAppFlag handles -a naming a git remote rather than an app, in which
+// case the app and cluster the remote points at are used
+func resolveAppFlag() {
+	if ra, err := appFromGitRemote(flagApp); err == nil && ra != nil {
+		clusterConf = ra.Cluster
+		flagApp = ra.Name
+	}
+}
+
 type command struct {
 	usage     string
 	f         interface{}
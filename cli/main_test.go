@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	cfg "weo/cli/config"
+)
+
+func TestResolveAppFlag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weo-main-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cluster := &cfg.Cluster{Name: "default", GitURL: "https://git.example.com"}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"remote", "add", "weo", gitURL(cluster, "myapp")},
+		{"remote", "add", "origin", "https://github.com/example/myapp.git"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+
+	defer func(c *cfg.Config, cc *cfg.Cluster, app string) {
+		config, clusterConf, flagApp = c, cc, app
+	}(config, clusterConf, flagApp)
+	config = &cfg.Config{Clusters: []*cfg.Cluster{cluster}}
+
+	for _, test := range []struct {
+		flag    string
+		app     string
+		cluster *cfg.Cluster
+	}{
+		// a remote pointing at an app is resolved to the app and cluster
+		{flag: "weo", app: "myapp", cluster: cluster},
+		// other values are used as the app name
+		{flag: "otherapp", app: "otherapp"},
+		{flag: "origin", app: "origin"},
+	} {
+		flagApp, clusterConf = test.flag, nil
+		resolveAppFlag()
+		if flagApp != test.app {
+			t.Errorf("-a %s: expected app %q, got %q", test.flag, test.app, flagApp)
+		}
+		if clusterConf != test.cluster {
+			t.Errorf("-a %s: expected cluster %v, got %v", test.flag, test.cluster, clusterConf)
+		}
+	}
+}
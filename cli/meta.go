@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"sort"
+	"strings"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("meta", runMeta, `
+usage: weo meta
+       weo meta set <var>=<val>...
+       weo meta unset <var>...
+
+Manage metadata for an application.
+
+Apps can be selected by their metadata using the global --selector option
+with the apps, ps and info commands.
+
+Examples:
+
+	$ weo meta
+	KEY  VALUE
+	foo  bar
+
+	$ weo meta set foo=baz team=payments
+
+	$ weo meta
+	KEY   VALUE
+	foo   baz
+	team  payments
+
+	$ weo meta unset foo
+
+	$ weo meta
+	KEY   VALUE
+	team  payments
+
+	$ weo --selector team=payments apps
+	ID                                    NAME     CREATED
+	f1e85f53-9245-4a32-9929-e3f27f7a5644  billing  2 hours ago
+`)
+}
+
+func runMeta(args *docopt.Args, client controller.Client) error {
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+
+	if args.Bool["set"] {
+		return runMetaSet(app, args, client)
+	} else if args.Bool["unset"] {
+		return runMetaUnset(app, args, client)
+	} else {
+		return runMetaGet(app, args, client)
+	}
+}
+
+func runMetaGet(app *ct.App, args *docopt.Args, client controller.Client) error {
+	keys := make([]string, 0, len(app.Meta))
+	for k := range app.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w := tabWriter()
+	defer w.Flush()
+	listRec(w, "KEY", "VALUE")
+	for _, k := range keys {
+		listRec(w, k, app.Meta[k])
+	}
+	return nil
+}
+
+func runMetaSet(app *ct.App, args *docopt.Args, client controller.Client) error {
+	pairs := args.All["<var>=<val>"].([]string)
+	if app.Meta == nil {
+		app.Meta = make(map[string]string, len(pairs))
+	}
+	for _, s := range pairs {
+		v := strings.SplitN(s, "=", 2)
+		if len(v) != 2 {
+			return fmt.Errorf("invalid var format: %q", s)
+		}
+		app.Meta[v[0]] = v[1]
+	}
+	return client.UpdateAppMeta(app)
+}
+
+func runMetaUnset(app *ct.App, args *docopt.Args, client controller.Client) error {
+	vars := args.All["<var>"].([]string)
+	for _, s := range vars {
+		delete(app.Meta, s)
+	}
+	return client.UpdateAppMeta(app)
+}
+
+// parseSelector parses a comma separated list of key=value meta selectors
+func parseSelector(s string) (map[string]string, error) {
+	selector := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid selector %q, must be of the form key=value[,key=value...]", pair)
+		}
+		selector[kv[0]] = kv[1]
+	}
+	return selector, nil
+}
+
+// selectorMatches returns whether the app has all the meta in flagSelector
+func selectorMatches(app *ct.App) bool {
+	for k, v := range flagSelector {
+		if val, ok := app.Meta[k]; !ok || val != v {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedApps returns the apps matching flagSelector
+func selectedApps(client controller.Client) ([]*ct.App, error) {
+	apps, err := client.AppList()
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]*ct.App, 0, len(apps))
+	for _, app := range apps {
+		if selectorMatches(app) {
+			selected = append(selected, app)
+		}
+	}
+	return selected, nil
+}
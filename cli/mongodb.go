@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"github.com/flynn/go-docopt"
+	controller "weo/controller/client"
+)
+
+func init() {
+	register("mongodb", runMongodb, `
+usage: weo mongodb mongo [--] [<argument>...]
+       weo mongodb dump [-q] [-f <file>]
+       weo mongodb restore [-q] [-f <file>]
+       weo mongodb info
+
+Options:
+	-f, --file=<file>  name of dump file
+	-q, --quiet        don't print progress
+
+Commands:
+	mongo    Open a console to a Weo mongodb database. Any valid arguments to mongo may be provided.
+	dump     Dump a mongodb database. If file is not specified, will dump to stdout.
+	restore  Restore a dump. If file is not specified, will restore from stdin.
+	info     Show the connection details of the mongodb database, with passwords masked.
+
+Examples:
+
+	$ weo mongodb mongo
+
+	$ weo mongodb mongo -- --eval "db.stats()"
+
+	$ weo mongodb dump -f db.dump
+
+	$ weo mongodb restore -f db.dump
+`)
+}
+
+func runMongodb(args *docopt.Args, client controller.Client) error {
+	switch {
+	case args.Bool["mongo"]:
+		return runDatabaseConsole(client, mongodbDatabase, args.All["<argument>"].([]string))
+	case args.Bool["dump"]:
+		return runDatabaseDump(client, mongodbDatabase, args.String["--file"], args.Bool["--quiet"])
+	case args.Bool["restore"]:
+		return runDatabaseRestore(client, mongodbDatabase, args.String["--file"], args.Bool["--quiet"])
+	case args.Bool["info"]:
+		return runDatabaseInfo(client, mongodbDatabase)
+	}
+	return errors.New("unknown mongodb subcommand")
+}
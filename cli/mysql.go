@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"github.com/flynn/go-docopt"
+	controller "weo/controller/client"
+)
+
+func init() {
+	register("mysql", runMysql, `
+usage: weo mysql console [--] [<argument>...]
+       weo mysql dump [-q] [-f <file>]
+       weo mysql restore [-q] [-f <file>]
+       weo mysql info
+
+Options:
+	-f, --file=<file>  name of dump file
+	-q, --quiet        don't print progress
+
+Commands:
+	console  Open a console to a Weo mysql database. Any valid arguments to mysql may be provided.
+	dump     Dump a mysql database. If file is not specified, will dump to stdout.
+	restore  Restore a dump. If file is not specified, will restore from stdin.
+	info     Show the connection details of the mysql database, with passwords masked.
+
+Examples:
+
+	$ weo mysql console
+
+	$ weo mysql console -- -e "SHOW TABLES"
+
+	$ weo mysql dump -f db.dump
+
+	$ weo mysql restore -f db.dump
+`)
+}
+
+func runMysql(args *docopt.Args, client controller.Client) error {
+	switch {
+	case args.Bool["console"]:
+		return runDatabaseConsole(client, mysqlDatabase, args.All["<argument>"].([]string))
+	case args.Bool["dump"]:
+		return runDatabaseDump(client, mysqlDatabase, args.String["--file"], args.Bool["--quiet"])
+	case args.Bool["restore"]:
+		return runDatabaseRestore(client, mysqlDatabase, args.String["--file"], args.Bool["--quiet"])
+	case args.Bool["info"]:
+		return runDatabaseInfo(client, mysqlDatabase)
+	}
+	return errors.New("unknown mysql subcommand")
+}
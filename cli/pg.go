@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"github.com/flynn/go-docopt"
+	controller "weo/controller/client"
+)
+
+func init() {
+	register("pg", runPg, `
+usage: weo pg psql [--] [<argument>...]
+       weo pg dump [-q] [-f <file>]
+       weo pg restore [-q] [-f <file>]
+       weo pg info
+
+Options:
+	-f, --file=<file>  name of dump file
+	-q, --quiet        don't print progress
+
+Commands:
+	psql     Open a console to a Weo postgres database. Any valid arguments to psql may be provided.
+	dump     Dump a postgres database. If file is not specified, will dump to stdout.
+	restore  Restore a dump. If file is not specified, will restore from stdin.
+	info     Show the connection details of the postgres database, with passwords masked.
+
+Examples:
+
+	$ weo pg psql
+
+	$ weo pg psql -- -c "CREATE EXTENSION hstore"
+
+	$ weo pg dump -f db.dump
+
+	$ weo pg restore -f db.dump
+`)
+}
+
+func runPg(args *docopt.Args, client controller.Client) error {
+	switch {
+	case args.Bool["psql"]:
+		return runDatabaseConsole(client, postgresDatabase, args.All["<argument>"].([]string))
+	case args.Bool["dump"]:
+		return runDatabaseDump(client, postgresDatabase, args.String["--file"], args.Bool["--quiet"])
+	case args.Bool["restore"]:
+		return runDatabaseRestore(client, postgresDatabase, args.String["--file"], args.Bool["--quiet"])
+	case args.Bool["info"]:
+		return runDatabaseInfo(client, postgresDatabase)
+	}
+	return errors.New("unknown pg subcommand")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/flynn/flynn/pkg/term"
+	"github.com/flynn/go-docopt"
+	"os"
+	"sort"
+	"strings"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("ps", runPs, `
+usage: weo ps [-a] [-c] [-q] [-t <type>] [-r <release>] [-w]
+
+List weo jobs.
+
+If the global --selector option is given without -a, the jobs of all apps
+matching the selector are listed.
+
+Options:
+  -a, --all                Show all jobs (default is running and pending)
+  -c, --command            Show command
+  -q, --quiet              Only display IDs
+  -t, --type=<type>        Show jobs of type <type>
+  -r, --release=<release>  Show jobs of release <release>
+  -w, --watch              Keep watching for job events and update the list
+
+Example:
+
+       $ weo ps
+       ID                                          TYPE  STATE  CREATED             RELEASE
+       host0-52aedfbf-e613-40f2-941a-d832d10fc400  web   up     About a minute ago  cf39a906-38d1-4393-a6b1-8ad2befe8142
+       host0-205595d8-206a-46a2-be30-2e98f53df272  web   up     25 seconds ago      cf39a906-38d1-4393-a6b1-8ad2befe8142
+
+       $ weo ps --all --quiet
+       host0-52aedfbf-e613-40f2-941a-d832d10fc400
+       host0-205595d8-206a-46a2-be30-2e98f53df272
+       host0-129b821f-3195-4b3b-b04b-669196cfbb03
+
+       $ weo ps --all --type=run
+       ID                                          TYPE  STATE  CREATED        RELEASE
+       host0-129b821f-3195-4b3b-b04b-669196cfbb03  run   down   5 seconds ago  cf39a906-38d1-4393-a6b1-8ad2befe8142
+`)
+}
+
+func runPs(args *docopt.Args, client controller.Client) error {
+	if flagSelector != nil && flagApp == "" {
+		return runPsSelector(args, client)
+	}
+
+	appName := mustApp()
+
+	if args.Bool["--watch"] {
+		return watchJobs(args, client, appName)
+	}
+
+	jobs, err := client.JobList(appName)
+	if err != nil {
+		return err
+	}
+	printJobs(args, jobs, nil)
+	return nil
+}
+
+// runPsSelector lists the jobs of all apps matching flagSelector
+func runPsSelector(args *docopt.Args, client controller.Client) error {
+	if args.Bool["--watch"] {
+		return errors.New("--watch cannot be used with --selector")
+	}
+	apps, err := selectedApps(client)
+	if err != nil {
+		return err
+	}
+	appNames := make(map[string]string, len(apps))
+	var jobs []*ct.Job
+	for _, app := range apps {
+		appNames[app.ID] = app.Name
+		list, err := client.JobList(app.ID)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, list...)
+	}
+	printJobs(args, jobs, appNames)
+	return nil
+}
+
+// watchJobs prints the app's jobs and then redraws the list each time a job
+// event is received, until the event stream is closed.
+func watchJobs(args *docopt.Args, client controller.Client, appName string) error {
+	// start streaming before listing jobs so no events are missed
+	events := make(chan *ct.Job)
+	stream, err := client.StreamJobEvents(appName, events)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	list, err := client.JobList(appName)
+	if err != nil {
+		return err
+	}
+	// key jobs by UUID as the job ID changes once a pending job is placed
+	// on a host
+	jobs := make(map[string]*ct.Job, len(list))
+	for _, j := range list {
+		jobs[j.UUID] = j
+	}
+
+	isTerm := term.IsTerminal(os.Stdout.Fd())
+	render := func() {
+		list := make([]*ct.Job, 0, len(jobs))
+		for _, j := range jobs {
+			list = append(list, j)
+		}
+		if isTerm {
+			// move the cursor home and clear the screen
+			fmt.Print("\033[H\033[2J")
+		}
+		printJobs(args, list, nil)
+		if !isTerm {
+			fmt.Println()
+		}
+	}
+	render()
+
+	for job := range events {
+		jobs[job.UUID] = job
+		render()
+	}
+	return stream.Err()
+}
+
+// printJobs prints a table of jobs, including the name of each job's app if
+// appNames (which maps app IDs to names) is set
+func printJobs(args *docopt.Args, jobs []*ct.Job, appNames map[string]string) {
+	sort.Sort(sortJobs(jobs))
+	w := tabWriter()
+	defer w.Flush()
+	if !args.Bool["--quiet"] {
+		headers := []interface{}{"ID", "TYPE", "STATE", "CREATED", "RELEASE"}
+		if appNames != nil {
+			headers = append([]interface{}{"APP"}, headers...)
+		}
+		if args.Bool["--command"] {
+			headers = append(headers, "COMMAND")
+		}
+		listRec(w, headers...)
+	}
+	for _, j := range jobs {
+		if !args.Bool["--all"] && j.State != ct.JobStateUp && j.State != ct.JobStatePending && j.State != ct.JobStateStarting {
+			continue
+		}
+		if j.Type == "" {
+			j.Type = "run"
+		}
+		if t := args.String["--type"]; t != "" && j.Type != t {
+			continue
+		}
+		if r := args.String["--release"]; r != "" && j.ReleaseID != r {
+			continue
+		}
+		if args.Bool["--quiet"] {
+			fmt.Fprintln(w, jobID(j))
+			continue
+		}
+		fields := []interface{}{jobID(j), j.Type, j.State, humanTime(j.CreatedAt), j.ReleaseID}
+		if appNames != nil {
+			fields = append([]interface{}{appNames[j.AppID]}, fields...)
+		}
+		if args.Bool["--command"] {
+			fields = append(fields, strings.Join(j.Args, " "))
+		}
+		listRec(w, fields...)
+	}
+}
+
+// jobID returns the cluster ID of the job, or its UUID if it has not yet been
+// placed on a host
+func jobID(j *ct.Job) string {
+	if j.ID != "" {
+		return j.ID
+	}
+	return j.UUID
+}
+
+// sortJobs sorts Jobs in chronological order based on their CreatedAt time
+type sortJobs []*ct.Job
+
+func (s sortJobs) Len() int { return len(s) }
+func (s sortJobs) Less(i, j int) bool {
+	return s[i].CreatedAt == nil || s[j].CreatedAt != nil && (*s[j].CreatedAt).Sub(*s[i].CreatedAt) > 0
+}
+func (s sortJobs) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/docker/go-units"
+	host "github.com/flynn/flynn/host/types"
+	"github.com/flynn/go-docopt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+	tarclient "weo/tarreceive/client"
+)
+
+func init() {
+	register("push", runPush, `
+usage: weo push [--ignore-file <file>] [-q] [<dir>]
+
+Deploy the contents of a local directory without using git.
+
+The directory (defaulting to the current directory) is packed into tar layers
+which are extracted to /app in the image: one layer for each top-level
+directory and one for the remaining top-level files. Layers are identified by
+the SHA-256 digest of their contents, so only layers which have changed since
+a previous push are uploaded.
+
+The .git directory and files matching a pattern in the ignore file are
+skipped. Each line of the ignore file is a glob pattern matched against file
+names, or against the path relative to <dir> if it contains a slash. A
+trailing slash only matches directories, a leading ! re-includes files
+matched by an earlier pattern and lines starting with # are comments.
+
+If the directory contains a Procfile, the commands it lists replace those of
+the matching process types. The other processes, env and metadata of the
+current release are kept.
+
+Options:
+	--ignore-file=<file>  ignore file, relative to <dir> [default: .weoignore]
+	-q, --quiet           don't print progress
+
+Examples:
+
+	$ cat .weoignore
+	*.log
+	tmp/
+	$ weo push
+	packed 3 layers from /home/user/myapp
+	uploading layer bin 4cf9d1a7e0b2 (3.1 MB)
+	skipping layer node_modules 9a3c56d2f1e0 (48.2 MB), already on the cluster
+	uploading layer files 0b81c2d3e4f5 (12.4 kB)
+	created release 5c1da0c1-8db4-4f1a-9a4b-6e9b8c0a4c3e
+	deployed release 5c1da0c1-8db4-4f1a-9a4b-6e9b8c0a4c3e
+`)
+}
+
+// pushAppDir is the directory in the image that the pushed files are
+// extracted to
+const pushAppDir = "app"
+
+// pushFilesLayer is the name of the layer containing top-level files
+const pushFilesLayer = "files"
+
+// pushLayer is a tar layer packed from part of the pushed directory
+type pushLayer struct {
+	name  string
+	paths []string
+	file  *os.File
+	id    string
+	size  int64
+}
+
+func runPush(args *docopt.Args, client controller.Client) error {
+	dir := args.String["<dir>"]
+	if dir == "" {
+		dir = "."
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	quiet := args.Bool["--quiet"]
+
+	ignoreFile := args.String["--ignore-file"]
+	ignore, err := readPushIgnore(filepath.Join(dir, ignoreFile))
+	if os.IsNotExist(err) && ignoreFile == ".weoignore" {
+		ignore = &pushIgnore{}
+	} else if err != nil {
+		return fmt.Errorf("error reading ignore file: %s", err)
+	}
+
+	procfile, err := readProcfile(filepath.Join(dir, "Procfile"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading Procfile: %s", err)
+	}
+
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+	prevRelease, err := client.GetAppRelease(app.ID)
+	if err == controller.ErrNotFound {
+		prevRelease = &ct.Release{}
+	} else if err != nil {
+		return fmt.Errorf("error getting current app release: %s", err)
+	}
+
+	cluster, err := getCluster()
+	if err != nil {
+		return err
+	}
+	tc, err := cluster.TarClient()
+	if err != nil {
+		return err
+	}
+
+	layers, err := packPushLayers(dir, ignore)
+	defer func() {
+		for _, l := range layers {
+			l.file.Close()
+			os.Remove(l.file.Name())
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	if len(layers) == 0 {
+		return fmt.Errorf("nothing to push, %s is empty", dir)
+	}
+	if !quiet {
+		log.Printf("packed %d layers from %s", len(layers), dir)
+	}
+
+	manifestLayers := make([]*ct.ImageLayer, len(layers))
+	var size int64
+	for i, l := range layers {
+		if err := uploadPushLayer(tc, l, quiet); err != nil {
+			return err
+		}
+		manifestLayers[i] = &ct.ImageLayer{
+			ID:     l.id,
+			Type:   ct.ImageLayerTypeTar,
+			Length: l.size,
+			Hashes: map[string]string{"sha256": l.id},
+			Meta:   map[string]string{"weo.push.layer": l.name},
+		}
+		size += l.size
+	}
+
+	imageURL := strings.TrimSuffix(cluster.ImageURL, "/")
+	manifest := &ct.ImageManifest{
+		Type: ct.ImageManifestTypeV1,
+		Entrypoints: map[string]*ct.ImageEntrypoint{
+			"_default": {WorkingDir: "/" + pushAppDir},
+		},
+		Rootfs: []*ct.ImageRootfs{{
+			Platform: ct.DefaultImagePlatform,
+			Layers:   manifestLayers,
+		}},
+	}
+	artifact := &ct.Artifact{
+		Type:             ct.ArtifactTypeWeo,
+		URI:              fmt.Sprintf("%s/images/%s.json", imageURL, manifest.ID()),
+		RawManifest:      manifest.RawManifest(),
+		Hashes:           manifest.Hashes(),
+		Size:             size,
+		LayerURLTemplate: imageURL + "/layer/{id}",
+		Meta:             map[string]string{"weo.push": "true"},
+	}
+	if err := client.CreateArtifact(artifact); err != nil {
+		return err
+	}
+
+	release := &ct.Release{
+		ArtifactIDs: []string{artifact.ID},
+		Processes:   prevRelease.Processes,
+		Env:         prevRelease.Env,
+		Meta:        prevRelease.Meta,
+	}
+	if len(procfile) > 0 && release.Processes == nil {
+		release.Processes = make(map[string]ct.ProcessType, len(procfile))
+	}
+	for typ, cmd := range procfile {
+		proc := release.Processes[typ]
+		proc.Args = []string{"/bin/sh", "-c", cmd}
+		if typ == "web" && len(proc.Ports) == 0 {
+			proc.Service = app.Name + "-web"
+			proc.Ports = []ct.Port{{
+				Port:  8080,
+				Proto: "tcp",
+				Service: &host.Service{
+					Name:   app.Name + "-web",
+					Create: true,
+				},
+			}}
+		}
+		release.Processes[typ] = proc
+	}
+	if err := client.CreateRelease(app.ID, release); err != nil {
+		return err
+	}
+	log.Printf("created release %s", release.ID)
+
+	if err := client.DeployAppRelease(app.ID, release.ID, ct.DeployOptions{}, nil); err != nil {
+		return err
+	}
+	log.Printf("deployed release %s", release.ID)
+	return nil
+}
+
+// packPushLayers packs the contents of dir into layers written to temporary
+// files, returning any layers which were created along with an error
+func packPushLayers(dir string, ignore *pushIgnore) ([]*pushLayer, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var layers []*pushLayer
+	files := &pushLayer{name: pushFilesLayer}
+	for _, e := range entries {
+		if e.Name() == ".git" || ignore.Match(e.Name(), e.IsDir()) {
+			continue
+		}
+		if e.IsDir() {
+			layers = append(layers, &pushLayer{name: e.Name(), paths: []string{e.Name()}})
+		} else {
+			files.paths = append(files.paths, e.Name())
+		}
+	}
+	if len(files.paths) > 0 {
+		layers = append(layers, files)
+	}
+
+	for i, l := range layers {
+		l.file, err = ioutil.TempFile("", "weo-push-")
+		if err != nil {
+			return layers[:i], err
+		}
+		if err := writePushLayer(l, dir, ignore); err != nil {
+			return layers[:i+1], fmt.Errorf("error packing layer %s: %s", l.name, err)
+		}
+	}
+	return layers, nil
+}
+
+// writePushLayer writes the paths of the layer to its file as a tar archive
+// and sets its ID and size. Headers are normalised so that the same files
+// always produce the same layer.
+func writePushLayer(l *pushLayer, dir string, ignore *pushIgnore) error {
+	tw := tar.NewWriter(l.file)
+	if err := tw.WriteHeader(pushHeader(&tar.Header{
+		Name:     pushAppDir + "/",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+	})); err != nil {
+		return err
+	}
+	for _, p := range l.paths {
+		err := filepath.Walk(filepath.Join(dir, p), func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, name)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if rel != p && ignore.Match(rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return writePushEntry(tw, name, rel, info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var err error
+	l.id, l.size, err = tarclient.LayerID(l.file)
+	return err
+}
+
+func writePushEntry(tw *tar.Writer, name, rel string, info os.FileInfo) error {
+	var link string
+	switch mode := info.Mode(); {
+	case mode&os.ModeSymlink != 0:
+		var err error
+		link, err = os.Readlink(name)
+		if err != nil {
+			return err
+		}
+	case !mode.IsRegular() && !mode.IsDir():
+		// devices, sockets and pipes have no place in an image
+		return nil
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = path.Join(pushAppDir, rel)
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	if err := tw.WriteHeader(pushHeader(header)); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// pushHeader clears the parts of a tar header which vary between machines
+// and checkouts
+func pushHeader(h *tar.Header) *tar.Header {
+	h.ModTime = time.Unix(0, 0)
+	h.AccessTime = time.Time{}
+	h.ChangeTime = time.Time{}
+	h.Uid, h.Gid = 0, 0
+	h.Uname, h.Gname = "", ""
+	h.Mode &= 0777
+	return h
+}
+
+func uploadPushLayer(tc *tarclient.Client, l *pushLayer, quiet bool) error {
+	desc := fmt.Sprintf("layer %s %s (%s)", l.name, l.id[:12], units.HumanSize(float64(l.size)))
+	exists, err := tc.HasLayer(l.id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if !quiet {
+			log.Printf("skipping %s, already on the cluster", desc)
+		}
+		return nil
+	}
+	if !quiet {
+		log.Printf("uploading %s", desc)
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var progress tarclient.ProgressFunc
+	if !quiet {
+		bar := newProgressBar(l.name, l.size)
+		defer bar.Finish()
+		progress = bar.Update
+	}
+	if err := tc.PutLayer(l.id, l.file, progress); err != nil {
+		return fmt.Errorf("error uploading layer %s: %s", l.name, err)
+	}
+	return nil
+}
+
+// pushIgnore is a list of patterns read from an ignore file
+type pushIgnore struct {
+	patterns []pushPattern
+}
+
+type pushPattern struct {
+	glob    string
+	negate  bool
+	dirOnly bool
+	// anchored patterns are matched against the full relative path rather
+	// than the file name
+	anchored bool
+}
+
+func readPushIgnore(name string) (*pushIgnore, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ignore := &pushIgnore{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p pushPattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", s.Text(), err)
+		}
+		p.glob = line
+		ignore.patterns = append(ignore.patterns, p)
+	}
+	return ignore, s.Err()
+}
+
+// Match returns whether the path relative to the pushed directory should be
+// ignored, the last matching pattern taking precedence
+func (i *pushIgnore) Match(rel string, dir bool) bool {
+	var ignored bool
+	for _, p := range i.patterns {
+		if p.dirOnly && !dir {
+			continue
+		}
+		name := rel
+		if !p.anchored {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(p.glob, name); ok {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+var procfileLine = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+
+// readProcfile returns the commands of the process types listed in a
+// Procfile
+func readProcfile(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	procs := make(map[string]string)
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := procfileLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("invalid line %d: %q", n, s.Text())
+		}
+		procs[m[1]] = m[2]
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(procs) == 0 {
+		return nil, errors.New("no process types listed")
+	}
+	return procs, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTempFile writes data to a file in a new temporary directory, returning
+// the file name and a function which removes the directory
+func writeTempFile(t *testing.T, name, data string) (string, func()) {
+	dir, err := ioutil.TempDir("", "weo-push-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestPushIgnore(t *testing.T) {
+	name, cleanup := writeTempFile(t, ".weoignore", `
+# comments and blank lines are skipped
+
+*.log
+!keep.log
+tmp/
+/build
+docs/*.md
+#notes.txt
+`)
+	defer cleanup()
+	ignore, err := readPushIgnore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path    string
+		dir     bool
+		ignored bool
+	}{
+		// unanchored patterns match the file name in any directory
+		{"app.log", false, true},
+		{"logs/app.log", false, true},
+		{"app.go", false, false},
+
+		// negated patterns override earlier matches
+		{"keep.log", false, false},
+		{"logs/keep.log", false, false},
+
+		// dir-only patterns only match directories
+		{"tmp", true, true},
+		{"src/tmp", true, true},
+		{"tmp", false, false},
+
+		// anchored patterns match the path from the root
+		{"build", true, true},
+		{"build", false, true},
+		{"src/build", true, false},
+		{"docs/index.md", false, true},
+		{"src/docs/index.md", false, false},
+		{"README.md", false, false},
+
+		// comment lines are not patterns
+		{"#notes.txt", false, false},
+	} {
+		if ignored := ignore.Match(test.path, test.dir); ignored != test.ignored {
+			t.Errorf("Match(%q, %t) = %t, want %t", test.path, test.dir, ignored, test.ignored)
+		}
+	}
+}
+
+func TestPushIgnoreInvalidPattern(t *testing.T) {
+	name, cleanup := writeTempFile(t, ".weoignore", "*.log\n[z-a\n")
+	defer cleanup()
+	if _, err := readPushIgnore(name); err == nil || !strings.Contains(err.Error(), `invalid pattern "[z-a"`) {
+		t.Fatalf("expected invalid pattern error, got %v", err)
+	}
+}
+
+func TestReadProcfile(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		in    string
+		procs map[string]string
+		err   string
+	}{
+		{
+			name: "process types",
+			in:   "web: bin/server -p $PORT\nworker:bin/worker\nclock-2: bin/clock: tick\n",
+			procs: map[string]string{
+				"web":     "bin/server -p $PORT",
+				"worker":  "bin/worker",
+				"clock-2": "bin/clock: tick",
+			},
+		},
+		{
+			name:  "comments and blank lines",
+			in:    "# processes\n\n  web: bin/server  \n",
+			procs: map[string]string{"web": "bin/server"},
+		},
+		{
+			name: "missing command",
+			in:   "web: bin/server\nworker:\n",
+			err:  `invalid line 2: "worker:"`,
+		},
+		{
+			name: "missing colon",
+			in:   "web bin/server\n",
+			err:  `invalid line 1: "web bin/server"`,
+		},
+		{
+			name: "invalid process type",
+			in:   "web server: bin/server\n",
+			err:  `invalid line 1: "web server: bin/server"`,
+		},
+		{
+			name: "no process types",
+			in:   "# nothing here\n",
+			err:  "no process types listed",
+		},
+	} {
+		name, cleanup := writeTempFile(t, "Procfile", test.in)
+		procs, err := readProcfile(name)
+		cleanup()
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(procs, test.procs) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.procs, procs)
+		}
+	}
+}
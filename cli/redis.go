@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"github.com/flynn/go-docopt"
+	controller "weo/controller/client"
+)
+
+func init() {
+	register("redis", runRedis, `
+usage: weo redis redis-cli [--] [<argument>...]
+       weo redis dump [-q] [-f <file>]
+       weo redis restore [-q] [-f <file>]
+       weo redis info
+
+Options:
+	-f, --file=<file>  name of dump file
+	-q, --quiet        don't print progress
+
+Commands:
+	redis-cli  Open a console to a Weo redis instance. Any valid arguments to redis-cli may be provided.
+	dump       Dump a redis instance. If file is not specified, will dump to stdout.
+	restore    Restore a dump. If file is not specified, will restore from stdin.
+	info       Show the connection details of the redis instance, with passwords masked.
+
+Examples:
+
+	$ weo redis redis-cli
+
+	$ weo redis redis-cli -- INFO
+
+	$ weo redis dump -f db.dump
+
+	$ weo redis restore -f db.dump
+`)
+}
+
+func runRedis(args *docopt.Args, client controller.Client) error {
+	switch {
+	case args.Bool["redis-cli"]:
+		return runDatabaseConsole(client, redisDatabase, args.All["<argument>"].([]string))
+	case args.Bool["dump"]:
+		return runDatabaseDump(client, redisDatabase, args.String["--file"], args.Bool["--quiet"])
+	case args.Bool["restore"]:
+		return runDatabaseRestore(client, redisDatabase, args.String["--file"], args.Bool["--quiet"])
+	case args.Bool["info"]:
+		return runDatabaseInfo(client, redisDatabase)
+	}
+	return errors.New("unknown redis subcommand")
+}
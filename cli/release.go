@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("release", runRelease, `
+usage: weo release [-q|--quiet]
+       weo release add [-t <type>] [-f <file>] <uri>
+       weo release show [--json] [<id>]
+       weo release delete [-y] <id>
+       weo release rollback [-y] [<id>]
+
+Manage app releases.
+
+Options:
+	-q, --quiet        only print release IDs
+	-t <type>          type of the release artifact, either docker or tar [default: docker]
+	-f, --file=<file>  release configuration file
+	--json             print release configuration in JSON format
+	-y, --yes          skip the confirmation prompt
+
+Commands:
+	With no arguments, shows a list of releases associated with the app.
+
+	add
+		Create and deploy a new release from a Docker image or tarball URI.
+
+		The optional file argument takes a path to a file containing release
+		configuration in a JSON format. It's primarily used for specifying the
+		release environment and processes (similar to a Procfile). It can take any
+		of the arguments the controller Release type can take.
+
+	show
+		Show information about a release.
+
+		Omit the ID to show information about the current release.
+
+	delete
+		Delete a release.
+
+		Any artifacts which are no longer referenced by another release are
+		also deleted. A release which still has running processes cannot be
+		deleted, scale it down first.
+
+	rollback
+		Rollback to a previous release. Deploys the release before the current
+		one, or the specified release ID.
+
+Examples:
+
+	$ cat config.json
+	{
+		"env": {"MY_VAR": "Hello World, this will be available in all process types."},
+		"processes": {
+			"echo": {
+				"args": ["sh", "-c", "socat -v tcp-l:$PORT,fork exec:/bin/cat"],
+				"env": {"ECHO": "This var is specific to the echo process type."},
+				"ports": [{"proto": "tcp"}]
+			}
+		}
+	}
+	$ weo release add -f config.json https://registry.hub.docker.com?name=weo/slugbuilder&id=15d72b7f573b
+	Created release 989ce4a8-0088-444c-8379-caddded4b957.
+
+	$ weo release
+	ID                                    CREATED
+	989ce4a8-0088-444c-8379-caddded4b957  11 seconds ago
+
+	$ weo release show
+	ID:             989ce4a8-0088-444c-8379-caddded4b957
+	Artifact[0]:    docker+https://registry.hub.docker.com?name=weo/slugbuilder&id=15d72b7f573b
+	Process Types:  echo
+	Created At:     2020-03-28 21:58:12.751741 +0000 UTC
+	ENV[MY_VAR]:    Hello World, this will be available in all process types.
+
+	$ weo release rollback -y
+	Rolling back to release 5058ae79-64f7-4c39-9a24-0bdd6e7d1bcb from 989ce4a8-0088-444c-8379-caddded4b957.
+	Successfully rolled back to release 5058ae79-64f7-4c39-9a24-0bdd6e7d1bcb.
+
+	$ weo release delete -y 989ce4a8-0088-444c-8379-caddded4b957
+	Deleted release 989ce4a8-0088-444c-8379-caddded4b957 (deleted 1 files)
+	Deleted https://registry.hub.docker.com?name=weo/slugbuilder&id=15d72b7f573b
+`)
+}
+
+// releaseArtifactTypes maps the release add types to the type of artifact
+// which is created
+var releaseArtifactTypes = map[string]ct.ArtifactType{
+	"docker": ct.DeprecatedArtifactTypeDocker,
+	"tar":    ct.DeprecatedArtifactTypeFile,
+}
+
+func runRelease(args *docopt.Args, client controller.Client) error {
+	if args.Bool["show"] {
+		return runReleaseShow(args, client)
+	}
+	if args.Bool["add"] {
+		return runReleaseAdd(args, client)
+	}
+	if args.Bool["delete"] {
+		return runReleaseDelete(args, client)
+	}
+	if args.Bool["rollback"] {
+		return runReleaseRollback(args, client)
+	}
+	return runReleaseList(args, client)
+}
+
+func runReleaseList(args *docopt.Args, client controller.Client) error {
+	list, err := client.AppReleaseList(mustApp())
+	if err != nil {
+		return err
+	}
+
+	if args.Bool["--quiet"] {
+		for _, r := range list {
+			fmt.Println(r.ID)
+		}
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+	listRec(w, "ID", "CREATED")
+	for _, r := range list {
+		listRec(w, r.ID, humanTime(r.CreatedAt))
+	}
+	return nil
+}
+
+func runReleaseShow(args *docopt.Args, client controller.Client) error {
+	var release *ct.Release
+	var err error
+	if args.String["<id>"] != "" {
+		release, err = client.GetRelease(args.String["<id>"])
+	} else {
+		release, err = client.GetAppRelease(mustApp())
+	}
+	if err != nil {
+		return err
+	}
+	if args.Bool["--json"] {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(release)
+	}
+	var artifacts []string
+	for _, id := range release.ArtifactIDs {
+		artifact, err := client.GetArtifact(id)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, fmt.Sprintf("%s+%s", artifact.Type, artifact.URI))
+	}
+	types := make([]string, 0, len(release.Processes))
+	for typ := range release.Processes {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	w := tabWriter()
+	defer w.Flush()
+	listRec(w, "ID:", release.ID)
+	for i, artifact := range artifacts {
+		listRec(w, fmt.Sprintf("Artifact[%d]:", i), artifact)
+	}
+	listRec(w, "Process Types:", strings.Join(types, ", "))
+	listRec(w, "Created At:", release.CreatedAt)
+	for _, k := range sortedKeys(release.Env) {
+		listRec(w, fmt.Sprintf("ENV[%s]:", k), release.Env[k])
+	}
+	for _, k := range sortedKeys(release.Meta) {
+		listRec(w, fmt.Sprintf("META[%s]:", k), release.Meta[k])
+	}
+	return nil
+}
+
+func runReleaseAdd(args *docopt.Args, client controller.Client) error {
+	artifactType, ok := releaseArtifactTypes[args.String["-t"]]
+	if !ok {
+		return fmt.Errorf("Release type %s not supported, must be either docker or tar.", args.String["-t"])
+	}
+
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+	release := &ct.Release{}
+	if args.String["--file"] != "" {
+		data, err := ioutil.ReadFile(args.String["--file"])
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, release); err != nil {
+			return fmt.Errorf("error decoding release configuration: %s", err)
+		}
+	}
+
+	artifact := &ct.Artifact{
+		Type: artifactType,
+		URI:  args.String["<uri>"],
+	}
+	if err := client.CreateArtifact(artifact); err != nil {
+		return err
+	}
+
+	release.ID = ""
+	release.ArtifactIDs = []string{artifact.ID}
+	if err := client.CreateRelease(app.ID, release); err != nil {
+		return err
+	}
+
+	if err := client.DeployAppRelease(app.ID, release.ID, ct.DeployOptions{}, nil); err != nil {
+		return err
+	}
+
+	log.Printf("Created release %s.", release.ID)
+
+	return nil
+}
+
+func runReleaseDelete(args *docopt.Args, client controller.Client) error {
+	releaseID := args.String["<id>"]
+	if !args.Bool["--yes"] {
+		if !promptYesNo(fmt.Sprintf("Are you sure you want to delete release %q?", releaseID)) {
+			return nil
+		}
+	}
+	res, err := client.DeleteRelease(mustApp(), releaseID)
+	if err != nil {
+		return err
+	}
+	if len(res.RemainingApps) > 0 {
+		log.Printf("Release scaled down for app but not fully deleted (still associated with %d other apps)", len(res.RemainingApps))
+		return nil
+	}
+	log.Printf("Deleted release %s (deleted %d files)", releaseID, len(res.DeletedFiles))
+	for _, file := range res.DeletedFiles {
+		log.Printf("Deleted %s", file)
+	}
+	return nil
+}
+
+func runReleaseRollback(args *docopt.Args, client controller.Client) error {
+	currentRelease, err := client.GetAppRelease(mustApp())
+	if err == controller.ErrNotFound {
+		return errors.New("App has no current release to roll back from.")
+	} else if err != nil {
+		return err
+	}
+	releaseID := args.String["<id>"]
+	if releaseID == "" {
+		releases, err := client.AppReleaseList(mustApp())
+		if err != nil {
+			return err
+		}
+		// releases are listed newest first, so the previous release is
+		// the one following the current release
+		for i, r := range releases {
+			if r.ID == currentRelease.ID && i+1 < len(releases) {
+				releaseID = releases[i+1].ID
+				break
+			}
+		}
+		if releaseID == "" {
+			return fmt.Errorf("Not enough releases to perform a rollback.")
+		}
+	} else if releaseID == currentRelease.ID {
+		return fmt.Errorf("Release id given is the current release.")
+	}
+
+	if !args.Bool["--yes"] {
+		if !promptYesNo(fmt.Sprintf("Are you sure you want to rollback to release %q?", releaseID)) {
+			return nil
+		}
+	}
+
+	log.Printf("Rolling back to release %s from %s.\n", releaseID, currentRelease.ID)
+
+	if err := client.DeployAppRelease(mustApp(), releaseID, ct.DeployOptions{}, nil); err != nil {
+		return err
+	}
+
+	log.Printf("Successfully rolled back to release %s.\n", releaseID)
+
+	return nil
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
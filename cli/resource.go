@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"log"
+	"strings"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("resource", runResource, `
+usage: weo resource [-a|--all]
+       weo resource add <provider> [-c <config>]
+       weo resource link <provider> <resource>
+       weo resource unlink <provider> [<resource>]
+       weo resource remove [-y] <provider> [<resource>]
+
+Manage resources for the app.
+
+Options:
+	-a, --all              show resources of all apps rather than just the current app
+	-c, --config=<config>  provider specific config as JSON [default: {}]
+	-y, --yes              skip the confirmation prompt
+
+Commands:
+	With no arguments, shows a list of the app's resources.
+
+	add     provisions a new resource for the app using <provider>.
+
+	link    attaches an existing <resource> provided by <provider> to the
+	        app, for example one provisioned for another app.
+
+	unlink  detaches <resource> from the app without deleting it.
+
+	remove  deletes the existing <resource> provided by <provider>, warning
+	        first if other apps still use it.
+
+<resource> is resolved automatically for unlink and remove if the app has only
+one resource provided by <provider>. The env of the resource is added to the
+app's release when it is added or linked, and unset when it is unlinked or
+removed unless it has since been changed.
+
+Examples:
+
+	$ weo resource add postgres
+	Created resource 1c3f3f5a-1e2b-4f0d-b1a4-9d4c7c1d0a5e and release 8e9f6a0c-5c2d-4b8e-9c3d-2f1a0b9c8d7e.
+
+	$ weo -a worker resource link postgres 1c3f3f5a-1e2b-4f0d-b1a4-9d4c7c1d0a5e
+	Linked resource 1c3f3f5a-1e2b-4f0d-b1a4-9d4c7c1d0a5e and created release 3a4b5c6d-7e8f-4a0b-8c1d-2e3f4a5b6c7d.
+
+	$ weo resource remove postgres
+	Resource 1c3f3f5a-1e2b-4f0d-b1a4-9d4c7c1d0a5e is also used by: worker
+	Are you sure you want to delete it? (yes/no): yes
+	Deleted resource 1c3f3f5a-1e2b-4f0d-b1a4-9d4c7c1d0a5e, created release 5d6e7f8a-9b0c-4d1e-8f2a-3b4c5d6e7f8a.
+`)
+}
+
+func runResource(args *docopt.Args, client controller.Client) error {
+	switch {
+	case args.Bool["add"]:
+		return runResourceAdd(args, client)
+	case args.Bool["link"]:
+		return runResourceLink(args, client)
+	case args.Bool["unlink"]:
+		return runResourceUnlink(args, client)
+	case args.Bool["remove"]:
+		return runResourceRemove(args, client)
+	}
+	return runResourceList(args, client)
+}
+
+func runResourceList(args *docopt.Args, client controller.Client) error {
+	var resources []*ct.Resource
+	var err error
+	if args.Bool["--all"] {
+		resources, err = client.ResourceListAll()
+	} else {
+		resources, err = client.AppResourceList(mustApp())
+	}
+	if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	listRec(w, "ID", "PROVIDER ID", "PROVIDER NAME", "APPS", "CREATED")
+	for _, r := range resources {
+		provider, err := client.GetProvider(r.ProviderID)
+		if err != nil {
+			return err
+		}
+		listRec(w, r.ID, r.ProviderID, provider.Name, len(r.Apps), humanTime(r.CreatedAt))
+	}
+	return nil
+}
+
+func runResourceAdd(args *docopt.Args, client controller.Client) error {
+	config := json.RawMessage(args.String["--config"])
+	if !json.Valid(config) {
+		return fmt.Errorf("invalid resource config %q, must be JSON", args.String["--config"])
+	}
+
+	res, err := client.ProvisionResource(&ct.ResourceReq{
+		ProviderID: args.String["<provider>"],
+		Apps:       []string{mustApp()},
+		Config:     &config,
+	})
+	if err != nil {
+		return err
+	}
+
+	releaseID, err := setEnv(client, "", resourceEnv(res))
+	if err != nil {
+		return err
+	}
+	log.Printf("Created resource %s and release %s.", res.ID, releaseID)
+	return nil
+}
+
+func runResourceLink(args *docopt.Args, client controller.Client) error {
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+	res, err := client.AddResourceApp(args.String["<provider>"], args.String["<resource>"], app.ID)
+	if err != nil {
+		return err
+	}
+
+	releaseID, err := setEnv(client, "", resourceEnv(res))
+	if err != nil {
+		return err
+	}
+	log.Printf("Linked resource %s and created release %s.", res.ID, releaseID)
+	return nil
+}
+
+func runResourceUnlink(args *docopt.Args, client controller.Client) error {
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+	provider := args.String["<provider>"]
+	resource := args.String["<resource>"]
+	if resource == "" {
+		resource, err = resolveResource(provider, client)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := client.DeleteResourceApp(provider, resource, app.ID)
+	if err != nil {
+		return err
+	}
+
+	releaseID, err := unsetResourceEnv(client, res)
+	if err != nil {
+		return err
+	}
+	log.Printf("Unlinked resource %s, created release %s.", res.ID, releaseID)
+	return nil
+}
+
+func runResourceRemove(args *docopt.Args, client controller.Client) error {
+	app, err := client.GetApp(mustApp())
+	if err != nil {
+		return err
+	}
+	provider := args.String["<provider>"]
+	resource := args.String["<resource>"]
+	if resource == "" {
+		resource, err = resolveResource(provider, client)
+		if err != nil {
+			return err
+		}
+	}
+
+	// deleting the resource breaks any other app still configured to use
+	// it, so make sure that is intended
+	res, err := client.GetResource(provider, resource)
+	if err != nil {
+		return err
+	}
+	others, err := resourceOtherApps(client, res, app.ID)
+	if err != nil {
+		return err
+	}
+	if len(others) > 0 {
+		fmt.Printf("Resource %s is also used by: %s\n", res.ID, strings.Join(others, ", "))
+		if !args.Bool["--yes"] && !promptYesNo("Are you sure you want to delete it?") {
+			return nil
+		}
+	}
+
+	res, err = client.DeleteResource(provider, resource)
+	if err != nil {
+		return err
+	}
+
+	releaseID, err := unsetResourceEnv(client, res)
+	if err != nil {
+		return err
+	}
+	log.Printf("Deleted resource %s, created release %s.", res.ID, releaseID)
+	return nil
+}
+
+// resourceEnv returns the env of a resource to be added to a release
+func resourceEnv(res *ct.Resource) map[string]*string {
+	env := make(map[string]*string, len(res.Env))
+	for k, v := range res.Env {
+		s := v
+		env[k] = &s
+	}
+	return env
+}
+
+// unsetResourceEnv removes the env of a resource from the app's release,
+// returning the ID of the new release
+func unsetResourceEnv(client controller.Client, res *ct.Resource) (string, error) {
+	release, err := client.GetAppRelease(mustApp())
+	if err != nil {
+		return "", err
+	}
+
+	env := make(map[string]*string)
+	for k := range res.Env {
+		// Only unset the key if it hasn't been modified
+		if release.Env[k] == res.Env[k] {
+			env[k] = nil
+		}
+	}
+	return setEnv(client, "", env)
+}
+
+// resourceOtherApps returns the names of the apps using a resource other
+// than the app with the given ID
+func resourceOtherApps(client controller.Client, res *ct.Resource, appID string) ([]string, error) {
+	var names []string
+	for _, id := range res.Apps {
+		if id == appID {
+			continue
+		}
+		app, err := client.GetApp(id)
+		if err == controller.ErrNotFound {
+			names = append(names, id)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		names = append(names, app.Name)
+	}
+	return names, nil
+}
+
+func resolveResource(provider string, client controller.Client) (string, error) {
+	resources, err := client.AppResourceList(mustApp())
+	if err != nil {
+		return "", err
+	}
+	var matched []*ct.Resource
+	for _, r := range resources {
+		p, err := client.GetProvider(r.ProviderID)
+		if err != nil {
+			return "", err
+		}
+		if r.ProviderID == provider || p.Name == provider {
+			matched = append(matched, r)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", fmt.Errorf("App has no resources provided by %s", provider)
+	case 1:
+		return matched[0].ID, nil
+	default:
+		return "", fmt.Errorf("App has more than one resource for %s, specify resource ID", provider)
+	}
+}
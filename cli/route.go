@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	router "github.com/flynn/flynn/router/types"
+	"github.com/flynn/go-docopt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	controller "weo/controller/client"
+)
+
+func init() {
+	register("route", runRoute, `
+usage: weo route
+       weo route add http [-s <service>] [-p <port>] [--path <path>] [-c <tls-cert> -k <tls-key>] [--sticky] [--leader] [--no-leader] [--no-drain-backends] [--disable-keep-alives] <domain>
+       weo route add tcp [-s <service>] [-p <port>] [--leader] [--no-drain-backends]
+       weo route update <id> [-s <service>] [-c <tls-cert> -k <tls-key>] [--sticky] [--no-sticky] [--leader] [--no-leader] [--disable-keep-alives] [--enable-keep-alives]
+       weo route remove <id>
+
+Manage routes for application.
+
+Options:
+	-s, --service=<service>    service name to route domain to (defaults to APPNAME-web)
+	-c, --tls-cert=<tls-cert>  path to PEM encoded certificate for TLS, - for stdin (http only)
+	-k, --tls-key=<tls-key>    path to PEM encoded private key for TLS, - for stdin (http only)
+	--sticky                   enable cookie-based sticky routing (http only)
+	--no-sticky                disable cookie-based sticky routing (update http only)
+	--leader                   enable leader-only routing mode
+	--no-leader                disable leader-only routing mode (update only)
+	-p, --port=<port>          port to accept traffic on
+	--path=<path>              path prefix to route (http only, defaults to the path of <domain> or /)
+	--no-drain-backends        don't wait for in-flight requests to complete before stopping backends
+	--disable-keep-alives      disable keep-alives between the router and backends for the given route
+	--enable-keep-alives       enable keep-alives between the router and backends for the given route (default for new routes)
+
+Commands:
+	With no arguments, shows a list of routes, including the expiry date of
+	the TLS certificate of HTTPS routes.
+
+	add     adds a route to an app
+	update  updates a route
+	remove  removes a route
+
+TLS certificates and keys are checked to be a valid pair before being sent to
+the controller.
+
+Examples:
+
+	$ weo route add http example.com
+
+	$ weo route add http example.com/path/
+
+	$ weo route add http --tls-cert cert.pem --tls-key key.pem example.com
+
+	$ weo route add tcp
+
+	$ weo route add tcp --leader
+`)
+}
+
+func runRoute(args *docopt.Args, client controller.Client) error {
+	if args.Bool["add"] {
+		switch {
+		case args.Bool["http"]:
+			return runRouteAddHTTP(args, client)
+		case args.Bool["tcp"]:
+			return runRouteAddTCP(args, client)
+		}
+	} else if args.Bool["update"] {
+		typ := strings.Split(args.String["<id>"], "/")[0]
+		switch typ {
+		case "http":
+			return runRouteUpdateHTTP(args, client)
+		case "tcp":
+			return runRouteUpdateTCP(args, client)
+		default:
+			return fmt.Errorf("Route type %s not supported.", typ)
+		}
+	} else if args.Bool["remove"] {
+		return runRouteRemove(args, client)
+	}
+
+	routes, err := client.AppRouteList(mustApp())
+	if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	listRec(w, "ROUTE", "SERVICE", "ID", "STICKY", "LEADER", "PATH", "TLS EXPIRES")
+	for _, k := range routes {
+		var route, protocol, service, sticky, path, expires string
+		port := strconv.Itoa(int(k.Port))
+		switch k.Type {
+		case "tcp":
+			route = port
+			protocol = "tcp"
+			service = k.TCPRoute().Service
+		case "http":
+			httpRoute := k.HTTPRoute()
+			route = httpRoute.Domain
+			if port != "0" {
+				route = httpRoute.Domain + ":" + port
+			}
+			service = httpRoute.Service
+			protocol = "http"
+			if cert := routeCert(httpRoute); cert != "" {
+				protocol = "https"
+				expires = certExpiry(cert)
+			}
+			sticky = fmt.Sprintf("%t", k.Sticky)
+			path = httpRoute.Path
+		}
+		listRec(w, protocol+":"+route, service, k.FormattedID(), sticky, k.Leader, path, expires)
+	}
+	return nil
+}
+
+// routeCert returns the PEM encoded TLS certificate of the route, if any
+func routeCert(r *router.HTTPRoute) string {
+	if r.Certificate != nil && r.Certificate.Cert != "" {
+		return r.Certificate.Cert
+	}
+	return r.LegacyTLSCert
+}
+
+// certExpiry returns the expiry date of the leaf certificate in the given PEM
+// encoded chain
+func certExpiry(chain string) string {
+	block, _ := pem.Decode([]byte(chain))
+	if block == nil {
+		return "(invalid cert)"
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "(invalid cert)"
+	}
+	expires := cert.NotAfter.UTC().Format("2006-01-02")
+	if time.Now().After(cert.NotAfter) {
+		expires += " (expired)"
+	}
+	return expires
+}
+
+func runRouteAddTCP(args *docopt.Args, client controller.Client) error {
+	service := args.String["--service"]
+	if service == "" {
+		service = mustApp() + "-web"
+	}
+
+	port := 0
+	if args.String["--port"] != "" {
+		p, err := strconv.Atoi(args.String["--port"])
+		if err != nil {
+			return err
+		}
+		port = p
+	}
+
+	hr := &router.TCPRoute{
+		Service:       service,
+		Port:          port,
+		Leader:        args.Bool["--leader"],
+		DrainBackends: !args.Bool["--no-drain-backends"],
+	}
+
+	r := hr.ToRoute()
+	if err := client.CreateRoute(mustApp(), r); err != nil {
+		return err
+	}
+	hr = r.TCPRoute()
+	fmt.Printf("%s listening on port %d\n", hr.FormattedID(), hr.Port)
+	return nil
+}
+
+func runRouteAddHTTP(args *docopt.Args, client controller.Client) error {
+	service := args.String["--service"]
+	if service == "" {
+		service = mustApp() + "-web"
+	}
+
+	tlsCert, tlsKey, err := parseTLSCert(args)
+	if err != nil {
+		return err
+	}
+
+	port := 0
+	if args.String["--port"] != "" {
+		p, err := strconv.Atoi(args.String["--port"])
+		if err != nil {
+			return err
+		}
+		port = p
+	}
+
+	u, err := url.Parse("http://" + args.String["<domain>"])
+	if err != nil {
+		return fmt.Errorf("Failed to parse %s as URL", args.String["<domain>"])
+	}
+	path := u.Path
+	if p := args.String["--path"]; p != "" {
+		path = p
+	}
+	if path == "" {
+		path = "/"
+	}
+	if tlsCert != "" {
+		if err := validateTLSCert(tlsCert, tlsKey, u.Hostname()); err != nil {
+			return err
+		}
+	}
+
+	hr := &router.HTTPRoute{
+		Service:           service,
+		Domain:            u.Host,
+		Port:              port,
+		LegacyTLSCert:     tlsCert,
+		LegacyTLSKey:      tlsKey,
+		Sticky:            args.Bool["--sticky"],
+		Leader:            args.Bool["--leader"],
+		Path:              path,
+		DrainBackends:     !args.Bool["--no-drain-backends"],
+		DisableKeepAlives: args.Bool["--disable-keep-alives"],
+	}
+	route := hr.ToRoute()
+	if err := client.CreateRoute(mustApp(), route); err != nil {
+		return err
+	}
+	fmt.Println(route.FormattedID())
+	return nil
+}
+
+func runRouteUpdateTCP(args *docopt.Args, client controller.Client) error {
+	id := args.String["<id>"]
+	appName := mustApp()
+
+	route, err := client.GetRoute(appName, id)
+	if err != nil {
+		return err
+	}
+
+	if service := args.String["--service"]; service != "" {
+		route.Service = service
+	}
+
+	if args.Bool["--leader"] {
+		route.Leader = true
+	} else if args.Bool["--no-leader"] {
+		route.Leader = false
+	}
+
+	if err := client.UpdateRoute(appName, id, route); err != nil {
+		return err
+	}
+	hr := route.TCPRoute()
+	fmt.Printf("%s listening on port %d\n", hr.FormattedID(), hr.Port)
+	return nil
+}
+
+func runRouteUpdateHTTP(args *docopt.Args, client controller.Client) error {
+	id := args.String["<id>"]
+	appName := mustApp()
+
+	route, err := client.GetRoute(appName, id)
+	if err != nil {
+		return err
+	}
+
+	if service := args.String["--service"]; service != "" {
+		route.Service = service
+	}
+
+	tlsCert, tlsKey, err := parseTLSCert(args)
+	if err != nil {
+		return err
+	}
+	if tlsCert != "" {
+		if err := validateTLSCert(tlsCert, tlsKey, route.Domain); err != nil {
+			return err
+		}
+		route.Certificate = nil
+		route.LegacyTLSCert, route.LegacyTLSKey = tlsCert, tlsKey
+	}
+
+	if args.Bool["--sticky"] {
+		route.Sticky = true
+	} else if args.Bool["--no-sticky"] {
+		route.Sticky = false
+	}
+
+	if args.Bool["--leader"] {
+		route.Leader = true
+	} else if args.Bool["--no-leader"] {
+		route.Leader = false
+	}
+
+	if args.Bool["--disable-keep-alives"] {
+		route.DisableKeepAlives = true
+	} else if args.Bool["--enable-keep-alives"] {
+		route.DisableKeepAlives = false
+	}
+
+	if err := client.UpdateRoute(appName, id, route); err != nil {
+		return err
+	}
+	fmt.Printf("updated %s\n", route.FormattedID())
+	return nil
+}
+
+func parseTLSCert(args *docopt.Args) (string, string, error) {
+	tlsCertPath := args.String["--tls-cert"]
+	tlsKeyPath := args.String["--tls-key"]
+	var tlsCert []byte
+	var tlsKey []byte
+	if tlsCertPath != "" && tlsKeyPath != "" {
+		var stdin []byte
+
+		if tlsCertPath == "-" || tlsKeyPath == "-" {
+			var err error
+			stdin, err = ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return "", "", fmt.Errorf("Failed to read from stdin: %s", err)
+			}
+		}
+
+		var err error
+		tlsCert, err = readPEM("CERTIFICATE", tlsCertPath, stdin)
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to read TLS cert: %s", err)
+		}
+		tlsKey, err = readPEM("PRIVATE KEY", tlsKeyPath, stdin)
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to read TLS key: %s", err)
+		}
+	} else if tlsCertPath != "" || tlsKeyPath != "" {
+		return "", "", errors.New("Both the TLS certificate AND private key need to be specified")
+	}
+	return string(tlsCert), string(tlsKey), nil
+}
+
+// validateTLSCert checks that the PEM encoded cert and key are a valid pair,
+// and warns if the certificate has expired or is not valid for the domain.
+func validateTLSCert(certPEM, keyPEM, domain string) error {
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return fmt.Errorf("Invalid TLS certificate and key: %s", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("Invalid TLS certificate: %s", err)
+	}
+	if time.Now().After(cert.NotAfter) {
+		fmt.Fprintf(os.Stderr, "WARNING: TLS certificate expired on %s\n", cert.NotAfter.UTC().Format("2006-01-02"))
+	}
+	if err := cert.VerifyHostname(domain); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", err)
+	}
+	return nil
+}
+
+func readPEM(typ string, path string, stdin []byte) ([]byte, error) {
+	if path == "-" {
+		var buf bytes.Buffer
+		var block *pem.Block
+		for {
+			block, stdin = pem.Decode(stdin)
+			if block == nil {
+				break
+			}
+			if strings.HasSuffix(block.Type, typ) {
+				pem.Encode(&buf, block)
+			}
+		}
+		if buf.Len() > 0 {
+			return buf.Bytes(), nil
+		}
+		return nil, errors.New("No PEM blocks found in stdin")
+	}
+	return ioutil.ReadFile(path)
+}
+
+func runRouteRemove(args *docopt.Args, client controller.Client) error {
+	routeID := args.String["<id>"]
+
+	if err := client.DeleteRoute(mustApp(), routeID); err != nil {
+		return err
+	}
+	fmt.Printf("Route %s removed.\n", routeID)
+	return nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("scale", runScale, `
+usage: weo scale [options] [<type>=<spec>...]
+
+Scale changes the number of jobs and tags for each process type in a release.
+
+Process type scale should be formatted like TYPE=COUNT[,KEY=VAL...], for example:
+
+web=1                  # 1 web process
+web=3                  # 3 web processes, distributed amongst all hosts
+web=3,active=true      # 3 web processes, distributed amongst hosts tagged active=true
+db=3,disk=ssd,mem=high # 3 db processes, distributed amongst hosts tagged with
+                       # both disk=ssd and mem=high
+
+Omitting the arguments will show the current scale.
+
+Options:
+	-n, --no-wait            don't wait for the scaling events to happen
+	-t, --timeout=<timeout>  how long to wait for the scaling events to happen [default: 30s]
+	-r, --release=<release>  id of release to scale (defaults to current app release)
+	-a, --all                show non-zero formations from all releases (only works when listing formations, can't be combined with --release)
+
+Example:
+
+	$ weo scale
+	web=4 worker=2
+
+	$ weo scale --all
+	496d6e74-9db9-4cff-bcce-a3b44015907a (current)
+	web=1 worker=2
+
+	632cd907-85ab-4e53-90d0-84635650ec9a
+	web=2
+
+	$ weo scale web=2 worker=5
+	scaling web: 4=>2, worker: 2=>5
+
+	02:28:34.333 ==> web host0-3f656af6-f1e4-4092-aa70-37046236b203 down
+	02:28:34.466 ==> web host0-ee83def0-b8e4-4557-93a4-3c8c70f5b34e down
+	02:28:35.479 ==> worker host0-84f70ca1-8c96-41ef-83a1-78a19db867a3 up
+	02:28:36.508 ==> worker host0-a3de8c32-6cc5-42aa-8923-5e53ba304260 up
+	02:28:37.601 ==> worker host0-e24760c5-11af-4733-b01e-d5b98aa54647 up
+
+	scale completed in 3.944629056s
+`)
+}
+
+// takes args of the form "web=1[,key=val...]", "worker=3[,key=val...]", etc
+func runScale(args *docopt.Args, client controller.Client) error {
+	app := mustApp()
+
+	typeSpecs := args.All["<type>=<spec>"].([]string)
+
+	showAll := args.Bool["--all"]
+
+	if len(typeSpecs) > 0 && showAll {
+		return fmt.Errorf("ERROR: Can't use --all when scaling")
+	}
+
+	releaseID := args.String["--release"]
+	if releaseID != "" && showAll {
+		return fmt.Errorf("ERROR: Can't use --all in combination with --release")
+	}
+
+	if len(typeSpecs) == 0 {
+		return showFormations(client, releaseID, showAll, app)
+	}
+
+	timeout, err := time.ParseDuration(args.String["--timeout"])
+	if err != nil {
+		return fmt.Errorf("ERROR: could not parse timeout %q: %s", args.String["--timeout"], err)
+	} else if timeout <= 0 {
+		return errors.New("ERROR: timeout must be positive")
+	}
+
+	release, err := determineRelease(client, releaseID, app)
+	if err != nil {
+		return err
+	}
+
+	processes := make(map[string]int, len(typeSpecs))
+	tags := make(map[string]map[string]string, len(typeSpecs))
+	invalid := make([]string, 0, len(release.Processes))
+	for _, arg := range typeSpecs {
+		i := strings.IndexRune(arg, '=')
+		if i < 0 {
+			return fmt.Errorf("ERROR: scale args must be of the form <typ>=<spec>")
+		}
+
+		countTags := strings.Split(arg[i+1:], ",")
+
+		count, err := strconv.Atoi(countTags[0])
+		if err != nil {
+			return fmt.Errorf("ERROR: could not parse quantity in %q", arg)
+		} else if count < 0 {
+			return fmt.Errorf("ERROR: process quantities cannot be negative in %q", arg)
+		}
+
+		processType := arg[:i]
+		if _, ok := release.Processes[processType]; ok {
+			processes[processType] = count
+		} else {
+			invalid = append(invalid, fmt.Sprintf("%q", processType))
+			continue
+		}
+
+		if len(countTags) > 1 {
+			processTags := make(map[string]string, len(countTags)-1)
+			for i := 1; i < len(countTags); i++ {
+				keyVal := strings.SplitN(countTags[i], "=", 2)
+				if len(keyVal) == 1 && keyVal[0] != "" {
+					processTags[keyVal[0]] = "true"
+				} else if len(keyVal) == 2 {
+					processTags[keyVal[0]] = keyVal[1]
+				}
+			}
+			tags[processType] = processTags
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("ERROR: unknown process types: %s", strings.Join(invalid, ", "))
+	}
+
+	formation, err := client.GetFormation(app, release.ID)
+	if err == controller.ErrNotFound {
+		formation = &ct.Formation{AppID: app, ReleaseID: release.ID}
+	} else if err != nil {
+		return err
+	}
+	currentProcs := formation.Processes
+	if currentProcs == nil {
+		currentProcs = make(map[string]int)
+	}
+
+	// only change the given process types, keeping the counts and tags of
+	// the others
+	for typ, count := range currentProcs {
+		if _, ok := processes[typ]; !ok {
+			processes[typ] = count
+		}
+	}
+	for typ, t := range formation.Tags {
+		if _, ok := tags[typ]; !ok {
+			tags[typ] = t
+		}
+	}
+
+	if scalingComplete(currentProcs, processes) {
+		if !formationTagsEqual(formation.Tags, tags) {
+			fmt.Println("persisting tag change")
+			return client.ScaleAppRelease(app, release.ID, ct.ScaleOptions{
+				Processes: processes,
+				Tags:      tags,
+				NoWait:    true,
+			})
+		}
+		fmt.Println("requested scale equals current scale, nothing to do!")
+		return nil
+	}
+
+	fmt.Printf("scaling %s\n\n", formatScaleDiff(currentProcs, processes))
+
+	opts := ct.ScaleOptions{
+		Processes: processes,
+		Tags:      tags,
+		NoWait:    true,
+	}
+	if args.Bool["--no-wait"] {
+		return client.ScaleAppRelease(app, release.ID, opts)
+	}
+
+	// start watching before the scale request is made so no events are
+	// missed
+	hosts, err := clusterSize(client, release, currentProcs, processes)
+	if err != nil {
+		return err
+	}
+	expected := client.ExpectedScalingEvents(currentProcs, processes, release.Processes, hosts)
+	watcher, err := client.WatchJobEvents(app, release.ID)
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	start := time.Now()
+	if err := client.ScaleAppRelease(app, release.ID, opts); err != nil {
+		return err
+	}
+	err = watcher.WaitFor(expected, timeout, func(job *ct.Job) error {
+		fmt.Printf("%s ==> %s %s %s\n", time.Now().Format("15:04:05.000"), job.Type, jobID(job), job.State)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nscale completed in %s\n", time.Since(start))
+	return nil
+}
+
+// clusterSize returns the number of hosts which omni process types being
+// scaled run on, determined from the hosts of the active jobs in the cluster.
+// The cluster is only queried if an omni process type is being scaled.
+func clusterSize(client controller.Client, release *ct.Release, before, after map[string]int) (int, error) {
+	scalingOmni := false
+	for typ, count := range after {
+		if release.Processes[typ].Omni && before[typ] != count {
+			scalingOmni = true
+			break
+		}
+	}
+	if !scalingOmni {
+		return 1, nil
+	}
+	jobs, err := client.JobListActive()
+	if err != nil {
+		return 0, err
+	}
+	return countHosts(jobs), nil
+}
+
+// countHosts returns the number of distinct hosts the given jobs run on, which
+// is at least 1
+func countHosts(jobs []*ct.Job) int {
+	hosts := make(map[string]struct{})
+	for _, j := range jobs {
+		if j.HostID != "" {
+			hosts[j.HostID] = struct{}{}
+		}
+	}
+	if len(hosts) == 0 {
+		return 1
+	}
+	return len(hosts)
+}
+
+// formatScaleDiff formats the process types whose counts differ between
+// before and after like "web: 1=>3, worker: 2=>0"
+func formatScaleDiff(before, after map[string]int) string {
+	types := make([]string, 0, len(after))
+	for typ := range after {
+		types = append(types, typ)
+	}
+	for typ := range before {
+		if _, ok := after[typ]; !ok {
+			types = append(types, typ)
+		}
+	}
+	sort.Strings(types)
+
+	scale := make([]string, 0, len(types))
+	for _, typ := range types {
+		if before[typ] != after[typ] {
+			scale = append(scale, fmt.Sprintf("%s: %d=>%d", typ, before[typ], after[typ]))
+		}
+	}
+	return strings.Join(scale, ", ")
+}
+
+func showFormations(client controller.Client, releaseID string, showAll bool, app string) error {
+	release, err := determineRelease(client, releaseID, app)
+	if err != nil {
+		return err
+	}
+	var releases []*ct.Release
+	if showAll {
+		var err error
+		releases, err = client.AppReleaseList(app)
+		if err != nil {
+			return err
+		}
+	} else {
+		releases = []*ct.Release{release}
+	}
+
+	formations := make(map[string]*ct.Formation, len(releases))
+	for _, r := range releases {
+		formation, err := client.GetFormation(app, r.ID)
+		if err != nil && err != controller.ErrNotFound {
+			return err
+		}
+		formations[r.ID] = formation
+	}
+
+	first := true
+	for _, r := range releases {
+		f := formations[r.ID]
+		if f == nil || len(f.Processes) == 0 {
+			continue
+		}
+		if showAll {
+			if !first {
+				fmt.Println()
+			}
+			var suffix string
+			if r.ID == release.ID {
+				suffix = " (current)"
+			}
+			fmt.Printf("%s%s\n", r.ID, suffix)
+		}
+		first = false
+		types := make([]string, 0, len(r.Processes))
+		for typ := range r.Processes {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+		scale := make([]string, 0, len(types))
+		for _, typ := range types {
+			n := f.Processes[typ]
+			if showAll && n == 0 {
+				continue
+			}
+			scale = append(scale, fmt.Sprintf("%s=%d", typ, n))
+		}
+		fmt.Println(strings.Join(scale, " "))
+	}
+	return nil
+}
+
+func determineRelease(client controller.Client, releaseID, app string) (*ct.Release, error) {
+	if releaseID == "" {
+		release, err := client.GetAppRelease(app)
+		if err == controller.ErrNotFound {
+			return nil, errors.New("No app release, specify a release with --release")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return release, nil
+	}
+	return client.GetRelease(releaseID)
+}
+
+func scalingComplete(actual, expected map[string]int) bool {
+	// check all the expected counts are the same in actual
+	for typ, count := range expected {
+		if actual[typ] != count {
+			return false
+		}
+	}
+	// check any counts in actual which aren't in expected are zero
+	for typ, count := range actual {
+		if _, ok := expected[typ]; !ok && count != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func formationTagsEqual(a, b map[string]map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for typ, tagsA := range a {
+		tagsB, ok := b[typ]
+		if !ok || len(tagsA) != len(tagsB) {
+			return false
+		}
+		for k, v := range tagsA {
+			if w, ok := tagsB[k]; !ok || v != w {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	ct "weo/controller/types"
+)
+
+func TestCountHosts(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		jobs  []*ct.Job
+		hosts int
+	}{
+		{
+			name:  "no jobs",
+			hosts: 1,
+		},
+		{
+			name:  "pending jobs",
+			jobs:  []*ct.Job{{UUID: "1"}, {UUID: "2"}},
+			hosts: 1,
+		},
+		{
+			name: "multiple hosts",
+			jobs: []*ct.Job{
+				{UUID: "1", HostID: "host0"},
+				{UUID: "2", HostID: "host1"},
+				{UUID: "3", HostID: "host0"},
+				{UUID: "4", HostID: "host2"},
+				{UUID: "5"},
+			},
+			hosts: 3,
+		},
+	} {
+		if hosts := countHosts(test.jobs); hosts != test.hosts {
+			t.Errorf("%s: expected %d hosts, got %d", test.name, test.hosts, hosts)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/flynn/go-docopt"
+	"os"
+	"sort"
+	"time"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func init() {
+	register("volume", runVolume, `
+usage: weo volume [-a|--all]
+       weo volume show [--json] <id>
+       weo volume decommission [-y] <id>
+       weo volume watch [-a|--all]
+
+Manage volumes.
+
+Options:
+	-a, --all  show volumes of all apps rather than just the current app
+	--json     print volume in JSON format
+	-y, --yes  skip the confirmation prompt
+
+Commands:
+	With no arguments, displays the volumes of the app.
+
+	show
+		Show information about a volume.
+
+	decommission
+		Decommission a volume.
+
+		A decommissioned volume will continue to exist but will no longer
+		be attached to new jobs by the scheduler.
+
+	watch
+		Stream volume state changes as they happen.
+
+Examples:
+
+	$ weo volume
+	ID                                    PATH   STATE    JOB TYPE  ATTACHED JOB                                 CREATED        DECOMMISSIONED
+	0ee3a5f2-7cc1-4b7b-b3dc-8c6a05e5a1d2  /data  created  db        local-3b5c2a5e-0f5f-4d1e-9a3a-7f7a8d2a1c63  2 minutes ago  false
+
+	$ weo volume decommission 0ee3a5f2-7cc1-4b7b-b3dc-8c6a05e5a1d2
+	Are you sure you want to decommission volume "0ee3a5f2-7cc1-4b7b-b3dc-8c6a05e5a1d2"? (yes/no): yes
+	volume 0ee3a5f2-7cc1-4b7b-b3dc-8c6a05e5a1d2 successfully decommissioned at 2020-03-28 20:24:41.105113 +0000 UTC
+
+	$ weo volume watch
+	20:24:41.105 ==> 5c2d1a8e-95a4-4f2b-a3c6-2c1e07c1b6a4 db created (job local-8c9e55d0-e6a2-4d2e-9a1b-4b05d3a1c7f2)
+	20:25:02.310 ==> 0ee3a5f2-7cc1-4b7b-b3dc-8c6a05e5a1d2 db destroyed
+`)
+}
+
+func runVolume(args *docopt.Args, client controller.Client) error {
+	if args.Bool["show"] {
+		return runVolumeShow(args, client)
+	} else if args.Bool["decommission"] {
+		return runVolumeDecommission(args, client)
+	} else if args.Bool["watch"] {
+		return runVolumeWatch(args, client)
+	}
+	return runVolumeList(args, client)
+}
+
+func runVolumeList(args *docopt.Args, client controller.Client) error {
+	var volumes []*ct.Volume
+	var appNames map[string]string
+	if args.Bool["--all"] {
+		var err error
+		volumes, err = client.VolumeList()
+		if err != nil {
+			return err
+		}
+		appNames, err = volumeAppNames(client)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		volumes, err = client.AppVolumeList(mustApp())
+		if err != nil {
+			return err
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		a, b := volumes[i].CreatedAt, volumes[j].CreatedAt
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.After(*b)
+	})
+
+	w := tabWriter()
+	defer w.Flush()
+
+	header := []interface{}{"ID", "PATH", "STATE", "JOB TYPE", "ATTACHED JOB", "CREATED", "DECOMMISSIONED"}
+	if appNames != nil {
+		header = append([]interface{}{"APP"}, header...)
+	}
+	listRec(w, header...)
+	for _, v := range volumes {
+		var jobID string
+		if v.JobID != nil {
+			jobID = *v.JobID
+		}
+		row := []interface{}{v.ID, v.Path, v.State, v.JobType, jobID, humanTime(v.CreatedAt), v.DecommissionedAt != nil}
+		if appNames != nil {
+			row = append([]interface{}{appNames[v.AppID]}, row...)
+		}
+		listRec(w, row...)
+	}
+
+	return nil
+}
+
+// volumeAppNames returns a map of app IDs to app names
+func volumeAppNames(client controller.Client) (map[string]string, error) {
+	apps, err := client.AppList()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(apps))
+	for _, app := range apps {
+		names[app.ID] = app.Name
+	}
+	return names, nil
+}
+
+func runVolumeShow(args *docopt.Args, client controller.Client) error {
+	vol, err := client.GetVolume(mustApp(), args.String["<id>"])
+	if err != nil {
+		return err
+	}
+	if args.Bool["--json"] {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(vol)
+	}
+	w := tabWriter()
+	defer w.Flush()
+	listRec(w, "ID:", vol.ID)
+	listRec(w, "Type:", vol.Type)
+	listRec(w, "State:", vol.State)
+	listRec(w, "Path:", vol.Path)
+	listRec(w, "DeleteOnStop:", vol.DeleteOnStop)
+	listRec(w, "HostID:", vol.HostID)
+	listRec(w, "AppID:", vol.AppID)
+	listRec(w, "ReleaseID:", vol.ReleaseID)
+	var jobID string
+	if vol.JobID != nil {
+		jobID = *vol.JobID
+	}
+	listRec(w, "JobID:", jobID)
+	listRec(w, "JobType:", vol.JobType)
+	listRec(w, "CreatedAt:", vol.CreatedAt)
+	listRec(w, "UpdatedAt:", vol.UpdatedAt)
+	listRec(w, "DecommissionedAt:", vol.DecommissionedAt)
+	for _, k := range sortedKeys(vol.Meta) {
+		listRec(w, fmt.Sprintf("META[%s]:", k), vol.Meta[k])
+	}
+	return nil
+}
+
+func runVolumeDecommission(args *docopt.Args, client controller.Client) error {
+	vol, err := client.GetVolume(mustApp(), args.String["<id>"])
+	if err != nil {
+		return err
+	}
+	if vol.DecommissionedAt != nil {
+		return fmt.Errorf("volume %s was already decommissioned at %s", vol.ID, vol.DecommissionedAt)
+	}
+	if !args.Bool["--yes"] {
+		if !promptYesNo(fmt.Sprintf("Are you sure you want to decommission volume %q?", vol.ID)) {
+			return nil
+		}
+	}
+	if err := client.DecommissionVolume(mustApp(), vol); err != nil {
+		return err
+	}
+	fmt.Printf("volume %s successfully decommissioned at %s\n", vol.ID, vol.DecommissionedAt)
+	return nil
+}
+
+func runVolumeWatch(args *docopt.Args, client controller.Client) error {
+	var appID string
+	var appNames map[string]string
+	if args.Bool["--all"] {
+		var err error
+		appNames, err = volumeAppNames(client)
+		if err != nil {
+			return err
+		}
+	} else {
+		app, err := client.GetApp(mustApp())
+		if err != nil {
+			return err
+		}
+		appID = app.ID
+	}
+
+	// only stream changes from now on rather than every existing volume
+	since := time.Now()
+	volumes := make(chan *ct.Volume)
+	stream, err := client.StreamVolumes(&since, volumes)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	for vol := range volumes {
+		// the end of the initial list is marked by an empty volume
+		if vol.ID == "" {
+			continue
+		}
+		if appID != "" && vol.AppID != appID {
+			continue
+		}
+		msg := fmt.Sprintf("%s ==> %s", time.Now().Format("15:04:05.000"), vol.ID)
+		if appNames != nil {
+			// apps created since the watch started are shown by ID
+			name, ok := appNames[vol.AppID]
+			if !ok {
+				name = vol.AppID
+			}
+			msg += " " + name
+		}
+		if vol.JobType != "" {
+			msg += " " + vol.JobType
+		}
+		state := string(vol.State)
+		if vol.DecommissionedAt != nil {
+			state += ", decommissioned"
+		}
+		msg += " " + state
+		if vol.JobID != nil && vol.State != ct.VolumeStateDestroyed {
+			msg += fmt.Sprintf(" (job %s)", *vol.JobID)
+		}
+		fmt.Println(msg)
+	}
+	return stream.Err()
+}
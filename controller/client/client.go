@@ -2,13 +2,16 @@ package controller
 
 import (
 	"crypto/tls"
-	"github.com/flynn/flynn/pkg/httphelper"
+	logagg "github.com/flynn/flynn/logaggregator/types"
 	router "github.com/flynn/flynn/router/types"
+	"io"
 	"net/http"
 	"net/url"
 	"time"
 	v1controller "weo/controller/client/v1"
+	ct "weo/controller/types"
 	"weo/pkg/httpclient"
+	"weo/pkg/httphelper"
 	"weo/pkg/pinned"
 	"weo/pkg/status"
 	"weo/pkg/stream"
@@ -60,10 +63,10 @@ type Client interface {
 	GetAppLog(appID string, options *logagg.LogOpts) (io.ReadCloser, error)
 	StreamAppLog(appID string, options *logagg.LogOpts, output chan<- *ct.SSELogChunk) (stream.Stream, error)
 	GetDeployment(deploymentID string) (*ct.Deployment, error)
-	CreateDeployment(appID, releaseID string) (*ct.Deployment, error)
+	CreateDeployment(appID, releaseID string, opts ct.DeployOptions) (*ct.Deployment, error)
 	DeploymentList(appID string) ([]*ct.Deployment, error)
 	StreamDeployment(d *ct.Deployment, output chan *ct.DeploymentEvent) (stream.Stream, error)
-	DeployAppRelease(appID, releaseID string, stopWait <-chan struct{}) error
+	DeployAppRelease(appID, releaseID string, opts ct.DeployOptions, stopWait <-chan struct{}) error
 	ScaleAppRelease(appID, releaseID string, opts ct.ScaleOptions) error
 	StreamJobEvents(appID string, output chan *ct.Job) (stream.Stream, error)
 	WatchJobEvents(appID, releaseID string) (ct.JobWatcher, error)
@@ -104,10 +107,10 @@ type Config struct {
 	Domain string
 }
 
-type ErrNotFound = ct.ErrNotFound
+var ErrNotFound = ct.ErrNotFound
 
 func newClient(key string, url string, http *http.Client) *v1controller.Client {
-	c := v1controller.Client{
+	c := &v1controller.Client{
 		Client: &httpclient.Client{
 			ErrNotFound: ErrNotFound,
 			Key:         key,
@@ -0,0 +1,1010 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	logaggc "github.com/flynn/flynn/logaggregator/client"
+	logagg "github.com/flynn/flynn/logaggregator/types"
+	"github.com/flynn/flynn/pkg/cluster"
+	router "github.com/flynn/flynn/router/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"weo/controller/server"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/status"
+)
+
+const testTimeout = 5 * time.Second
+
+var testCACert = []byte("-----BEGIN CERTIFICATE-----\ntest\n-----END CERTIFICATE-----\n")
+
+// newTestClient returns a client of a fake controller backed by the
+// in-memory store
+func newTestClient(t *testing.T) (Client, func()) {
+	srv := httptest.NewServer(server.NewHandler(server.Config{
+		Store:  server.NewMemoryStore(),
+		CACert: testCACert,
+	}))
+	client, err := NewClient(srv.URL, "")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return client, srv.Close
+}
+
+func createApp(t *testing.T, client Client, name string) *ct.App {
+	app := &ct.App{Name: name}
+	if err := client.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func createRelease(t *testing.T, client Client, appID string) *ct.Release {
+	artifact := &ct.Artifact{Type: ct.ArtifactTypeWeo, URI: "https://images.example.com/images/app.json"}
+	if err := client.CreateArtifact(artifact); err != nil {
+		t.Fatal(err)
+	}
+	release := &ct.Release{
+		ArtifactIDs: []string{artifact.ID},
+		Env:         map[string]string{"PORT": "8080"},
+		Processes: map[string]ct.ProcessType{
+			"web":    {Args: []string{"bin/web"}, Ports: []ct.Port{{Port: 8080, Proto: "tcp"}}},
+			"worker": {Args: []string{"bin/worker"}},
+		},
+	}
+	if err := client.CreateRelease(appID, release); err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+// createRunningApp creates an app whose release runs a single web job
+func createRunningApp(t *testing.T, client Client, name string) (*ct.App, *ct.Release) {
+	app := createApp(t, client, name)
+	release := createRelease(t, client, app.ID)
+	if err := client.SetAppRelease(app.ID, release.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ScaleAppRelease(app.ID, release.ID, ct.ScaleOptions{
+		Processes: map[string]int{"web": 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return app, release
+}
+
+func TestApps(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+
+	app := &ct.App{Name: "client-apps", Meta: map[string]string{"team": "payments"}}
+	if err := client.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	if app.ID == "" || app.CreatedAt == nil {
+		t.Fatalf("expected the created app to be returned, got %+v", app)
+	}
+	if err := client.CreateApp(&ct.App{Name: "Invalid Name"}); err == nil {
+		t.Fatal("expected an error creating an app with an invalid name")
+	}
+
+	for _, id := range []string{app.ID, app.Name} {
+		got, err := client.GetApp(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != app.ID || got.Meta["team"] != "payments" {
+			t.Fatalf("GetApp(%q): unexpected app %+v", id, got)
+		}
+	}
+	if _, err := client.GetApp("missing"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	apps, err := client.AppList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].ID != app.ID {
+		t.Fatalf("unexpected app list %+v", apps)
+	}
+
+	if err := client.UpdateApp(&ct.App{ID: app.ID, Strategy: ct.DeployStrategyOneByOne}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetApp(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Strategy != ct.DeployStrategyOneByOne || got.Meta["team"] != "payments" {
+		t.Fatalf("expected UpdateApp to only change the strategy, got %+v", got)
+	}
+
+	if err := client.UpdateAppMeta(&ct.App{ID: app.ID, Meta: map[string]string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = client.GetApp(app.ID); err != nil {
+		t.Fatal(err)
+	} else if len(got.Meta) != 0 {
+		t.Fatalf("expected UpdateAppMeta to clear the meta, got %v", got.Meta)
+	}
+
+	deletion, err := client.DeleteApp(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deletion.AppID != app.ID {
+		t.Fatalf("unexpected app deletion %+v", deletion)
+	}
+	if _, err := client.GetApp(app.ID); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after deleting the app, got %v", err)
+	}
+}
+
+func TestArtifactsAndReleases(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app := createApp(t, client, "client-releases")
+
+	release := createRelease(t, client, app.ID)
+	if release.ID == "" || release.AppID != app.ID {
+		t.Fatalf("expected the created release to be returned, got %+v", release)
+	}
+
+	artifacts, err := client.ArtifactList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 1 || artifacts[0].ID != release.ArtifactIDs[0] {
+		t.Fatalf("unexpected artifact list %+v", artifacts)
+	}
+	artifact, err := client.GetArtifact(release.ArtifactIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artifact.URI != artifacts[0].URI {
+		t.Fatalf("unexpected artifact %+v", artifact)
+	}
+
+	got, err := client.GetRelease(release.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Env["PORT"] != "8080" || len(got.Processes) != 2 {
+		t.Fatalf("unexpected release %+v", got)
+	}
+
+	if _, err := client.GetAppRelease(app.ID); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for an app without a release, got %v", err)
+	}
+	if err := client.SetAppRelease(app.ID, release.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.GetAppRelease(app.ID); err != nil {
+		t.Fatal(err)
+	} else if got.ID != release.ID {
+		t.Fatalf("expected app release %s, got %s", release.ID, got.ID)
+	}
+
+	old := createRelease(t, client, app.ID)
+	unused := createRelease(t, client, app.ID)
+	for _, list := range []func() ([]*ct.Release, error){
+		client.ReleaseList,
+		func() ([]*ct.Release, error) { return client.AppReleaseList(app.ID) },
+	} {
+		releases, err := list()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(releases) != 3 {
+			t.Fatalf("expected 3 releases, got %d", len(releases))
+		}
+	}
+
+	deletion, err := client.DeleteRelease(app.ID, old.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deletion.ReleaseID != old.ID {
+		t.Fatalf("unexpected release deletion %+v", deletion)
+	}
+	if _, err := client.GetRelease(old.ID); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after deleting the release, got %v", err)
+	}
+
+	// garbage collection deletes the unused release but keeps the
+	// current one
+	if err := client.ScheduleAppGarbageCollection(app.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetRelease(unused.ID); err != ErrNotFound {
+		t.Fatalf("expected the unused release to be garbage collected, got %v", err)
+	}
+	if _, err := client.GetRelease(release.ID); err != nil {
+		t.Fatalf("expected the current release to be kept, got %v", err)
+	}
+}
+
+func TestFormations(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app := createApp(t, client, "client-formations")
+	release := createRelease(t, client, app.ID)
+
+	formations := make(chan *ct.ExpandedFormation)
+	stream, err := client.StreamFormations(nil, formations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	// the initial list is empty, so the first formation is the marker
+	// of the end of it
+	select {
+	case f := <-formations:
+		if f.App != nil {
+			t.Fatalf("expected the end of the initial formations, got %+v", f)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for the initial formations")
+	}
+
+	formation := &ct.Formation{AppID: app.ID, ReleaseID: release.ID, Processes: map[string]int{"web": 2}}
+	if err := client.PutFormation(formation); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case f := <-formations:
+		if f.App == nil || f.App.ID != app.ID || f.Release.ID != release.ID || f.Processes["web"] != 2 {
+			t.Fatalf("unexpected streamed formation %+v", f)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for the formation to be streamed")
+	}
+
+	got, err := client.GetFormation(app.ID, release.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Processes["web"] != 2 {
+		t.Fatalf("unexpected formation %+v", got)
+	}
+	expanded, err := client.GetExpandedFormation(app.ID, release.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expanded.App.Name != app.Name || expanded.Release.ID != release.ID || len(expanded.Artifacts) != 1 {
+		t.Fatalf("unexpected expanded formation %+v", expanded)
+	}
+	list, err := client.FormationList(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ReleaseID != release.ID {
+		t.Fatalf("unexpected formation list %+v", list)
+	}
+	active, err := client.FormationListActive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].App.ID != app.ID {
+		t.Fatalf("unexpected active formations %+v", active)
+	}
+
+	if err := client.DeleteFormation(app.ID, release.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetFormation(app.ID, release.ID); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after deleting the formation, got %v", err)
+	}
+}
+
+func TestScale(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app := createApp(t, client, "client-scale")
+	release := createRelease(t, client, app.ID)
+
+	watcher, err := client.WatchJobEvents(app.ID, release.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	processes := map[string]int{"web": 2, "worker": 1}
+	var scaleReq *ct.ScaleRequest
+	if err := client.ScaleAppRelease(app.ID, release.ID, ct.ScaleOptions{
+		Processes:            processes,
+		ScaleRequestCallback: func(req *ct.ScaleRequest) { scaleReq = req },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if scaleReq == nil || scaleReq.ID == "" || (*scaleReq.NewProcesses)["web"] != 2 {
+		t.Fatalf("unexpected scale request %+v", scaleReq)
+	}
+
+	expected := client.ExpectedScalingEvents(nil, processes, release.Processes, 1)
+	if expected.Count() != 3 || expected["web"][ct.JobStateUp] != 2 {
+		t.Fatalf("unexpected expected scaling events %v", expected)
+	}
+	if err := watcher.WaitFor(expected, testTimeout, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// scaling down without waiting
+	req := &ct.ScaleRequest{
+		AppID:        app.ID,
+		ReleaseID:    release.ID,
+		NewProcesses: &map[string]int{"web": 1, "worker": 0},
+	}
+	if err := client.PutScaleRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.State != ct.ScaleRequestStatePending || req.OldProcesses["web"] != 2 {
+		t.Fatalf("unexpected scale request %+v", req)
+	}
+	expected = client.ExpectedScalingEvents(processes, *req.NewProcesses, release.Processes, 1)
+	if expected["web"][ct.JobStateDown] != 1 || expected["worker"][ct.JobStateDown] != 1 {
+		t.Fatalf("unexpected expected scaling events %v", expected)
+	}
+	if err := watcher.WaitFor(expected, testTimeout, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.ScaleAppRelease(app.ID, release.ID, ct.ScaleOptions{Processes: map[string]int{"clock": 1}})
+	if e, ok := err.(httphelper.JSONError); !ok || e.Code != httphelper.ValidationErrorCode {
+		t.Fatalf("expected a validation error scaling a missing process type, got %v", err)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app, release := createRunningApp(t, client, "client-jobs")
+
+	jobs, err := client.JobList(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Type != "web" || jobs[0].State != ct.JobStateUp {
+		t.Fatalf("unexpected job list %+v", jobs)
+	}
+	web := jobs[0]
+	active, err := client.JobListActive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].ID != web.ID {
+		t.Fatalf("unexpected active jobs %+v", active)
+	}
+	for _, id := range []string{web.ID, web.UUID} {
+		job, err := client.GetJob(app.ID, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.ID != web.ID {
+			t.Fatalf("GetJob(%q): unexpected job %+v", id, job)
+		}
+	}
+
+	events := make(chan *ct.Job)
+	stream, err := client.StreamJobEvents(app.ID, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		stream.Close()
+		// drain the events which were not waited for so that the
+		// stream is able to shut down
+		for range events {
+		}
+	}()
+
+	job, err := client.RunJobDetached(app.ID, &ct.NewJob{ReleaseID: release.ID, Args: []string{"sleep", "10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Type != "run" || job.State != ct.JobStateUp {
+		t.Fatalf("unexpected one-off job %+v", job)
+	}
+	if err := client.DeleteJob(app.ID, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitForJobState(t, events, job.ID, ct.JobStateDown)
+
+	job.Meta = map[string]string{"updated": "true"}
+	if err := client.PutJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.GetJob(app.ID, job.ID); err != nil {
+		t.Fatal(err)
+	} else if got.Meta["updated"] != "true" {
+		t.Fatalf("expected PutJob to update the job, got %+v", got)
+	}
+
+	rwc, err := client.RunJobAttached(app.ID, &ct.NewJob{ReleaseID: release.ID, Args: []string{"echo", "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+	var stdout, stderr bytes.Buffer
+	if exitStatus, err := cluster.NewAttachClient(rwc).Receive(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	} else if exitStatus != 0 {
+		t.Fatalf("expected exit status 0, got %d", exitStatus)
+	}
+}
+
+func waitForJobState(t *testing.T, events chan *ct.Job, jobID string, state ct.JobState) {
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case job, ok := <-events:
+			if !ok {
+				t.Fatal("job event stream closed unexpectedly")
+			}
+			if job.ID == jobID && job.State == state {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for job %s to be %s", jobID, state)
+		}
+	}
+}
+
+func TestDeployments(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app, _ := createRunningApp(t, client, "client-deployments")
+	release := createRelease(t, client, app.ID)
+
+	deployment, err := client.CreateDeployment(app.ID, release.ID, ct.DeployOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.ID == "" || deployment.NewReleaseID != release.ID || deployment.Processes["web"] != 1 {
+		t.Fatalf("unexpected deployment %+v", deployment)
+	}
+	events := make(chan *ct.DeploymentEvent)
+	stream, err := client.StreamDeployment(deployment, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	func() {
+		timeout := time.After(testTimeout)
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					t.Fatalf("deployment event stream closed unexpectedly: %v", stream.Err())
+				}
+				if e.Status == "failed" {
+					t.Fatalf("deployment failed: %s", e.Error)
+				}
+				if e.Status == "complete" {
+					return
+				}
+			case <-timeout:
+				t.Fatal("timed out waiting for the deployment to complete")
+			}
+		}
+	}()
+
+	got, err := client.GetDeployment(deployment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "complete" || got.FinishedAt == nil {
+		t.Fatalf("unexpected deployment %+v", got)
+	}
+
+	next := createRelease(t, client, app.ID)
+	if err := client.DeployAppRelease(app.ID, next.ID, ct.DeployOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if current, err := client.GetAppRelease(app.ID); err != nil {
+		t.Fatal(err)
+	} else if current.ID != next.ID {
+		t.Fatalf("expected app release %s after deploying, got %s", next.ID, current.ID)
+	}
+
+	list, err := client.DeploymentList(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 deployments, got %d", len(list))
+	}
+}
+
+// newTestProvider returns a fake resource provider which provisions
+// resources with an incrementing ID and records deprovisioned ones
+func newTestProvider() (*httptest.Server, *[]string) {
+	var n int
+	var deprovisioned []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "POST":
+			n++
+			id := "/databases/" + string('0'+rune(n))
+			httphelper.JSON(w, 200, map[string]interface{}{
+				"id":  id,
+				"env": map[string]string{"DATABASE_URL": "postgres://db" + id},
+			})
+		case "DELETE":
+			deprovisioned = append(deprovisioned, req.FormValue("id"))
+			w.WriteHeader(200)
+		}
+	}))
+	return srv, &deprovisioned
+}
+
+func TestProvidersAndResources(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	providerSrv, deprovisioned := newTestProvider()
+	defer providerSrv.Close()
+	app := createApp(t, client, "client-resources")
+
+	provider := &ct.Provider{Name: "postgres", URL: providerSrv.URL}
+	if err := client.CreateProvider(provider); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.GetProvider(provider.ID); err != nil {
+		t.Fatal(err)
+	} else if got.Name != "postgres" || got.URL != providerSrv.URL {
+		t.Fatalf("unexpected provider %+v", got)
+	}
+	if providers, err := client.ProviderList(); err != nil {
+		t.Fatal(err)
+	} else if len(providers) != 1 || providers[0].ID != provider.ID {
+		t.Fatalf("unexpected provider list %+v", providers)
+	}
+
+	res, err := client.ProvisionResource(&ct.ResourceReq{ProviderID: provider.ID, Apps: []string{app.Name}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExternalID != "/databases/1" || res.Env["DATABASE_URL"] != "postgres://db/databases/1" {
+		t.Fatalf("unexpected resource %+v", res)
+	}
+	if len(res.Apps) != 1 || res.Apps[0] != app.ID {
+		t.Fatalf("expected the resource to be linked to %s, got %v", app.ID, res.Apps)
+	}
+	unlinked, err := client.ProvisionResource(&ct.ResourceReq{ProviderID: provider.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := client.GetResource(provider.ID, res.ID); err != nil {
+		t.Fatal(err)
+	} else if got.ExternalID != res.ExternalID {
+		t.Fatalf("unexpected resource %+v", got)
+	}
+	for name, list := range map[string]func() ([]*ct.Resource, error){
+		"ResourceListAll": client.ResourceListAll,
+		"ResourceList":    func() ([]*ct.Resource, error) { return client.ResourceList(provider.ID) },
+	} {
+		resources, err := list()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resources) != 2 {
+			t.Fatalf("%s: expected 2 resources, got %d", name, len(resources))
+		}
+	}
+
+	linked, err := client.AddResourceApp(provider.ID, unlinked.ID, app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linked.Apps) != 1 || linked.Apps[0] != app.ID {
+		t.Fatalf("expected the resource to be linked to %s, got %v", app.ID, linked.Apps)
+	}
+	if resources, err := client.AppResourceList(app.ID); err != nil {
+		t.Fatal(err)
+	} else if len(resources) != 2 {
+		t.Fatalf("expected 2 app resources, got %d", len(resources))
+	}
+	if unlinked, err = client.DeleteResourceApp(provider.ID, unlinked.ID, app.ID); err != nil {
+		t.Fatal(err)
+	} else if len(unlinked.Apps) != 0 {
+		t.Fatalf("expected the resource to be unlinked, got %v", unlinked.Apps)
+	}
+	if resources, err := client.AppResourceList(app.ID); err != nil {
+		t.Fatal(err)
+	} else if len(resources) != 1 || resources[0].ID != res.ID {
+		t.Fatalf("unexpected app resources %+v", resources)
+	}
+
+	res.Env["DATABASE_USER"] = "app"
+	if err := client.PutResource(res); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.GetResource(provider.ID, res.ID); err != nil {
+		t.Fatal(err)
+	} else if got.Env["DATABASE_USER"] != "app" {
+		t.Fatalf("expected PutResource to update the env, got %v", got.Env)
+	}
+
+	deleted, err := client.DeleteResource(provider.ID, res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.ID != res.ID {
+		t.Fatalf("unexpected deleted resource %+v", deleted)
+	}
+	if len(*deprovisioned) != 1 || (*deprovisioned)[0] != res.ExternalID {
+		t.Fatalf("expected %s to be deprovisioned, got %v", res.ExternalID, *deprovisioned)
+	}
+	if _, err := client.GetResource(provider.ID, res.ID); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after deleting the resource, got %v", err)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app := createApp(t, client, "client-routes")
+	other := createApp(t, client, "client-routes-other")
+
+	route := (&router.HTTPRoute{Domain: "app.example.com", Service: "client-routes-web"}).ToRoute()
+	if err := client.CreateRoute(app.ID, route); err != nil {
+		t.Fatal(err)
+	}
+	if route.ID == "" || route.ParentRef != ct.RouteParentRefPrefix+app.ID {
+		t.Fatalf("unexpected route %+v", route)
+	}
+	tcp := (&router.TCPRoute{Service: "client-routes-other-web"}).ToRoute()
+	if err := client.CreateRoute(other.ID, tcp); err != nil {
+		t.Fatal(err)
+	}
+	if tcp.Port == 0 {
+		t.Fatal("expected a port to be allocated for the TCP route")
+	}
+
+	if got, err := client.GetRoute(app.ID, route.FormattedID()); err != nil {
+		t.Fatal(err)
+	} else if got.Domain != "app.example.com" {
+		t.Fatalf("unexpected route %+v", got)
+	}
+	if _, err := client.GetRoute(other.ID, route.FormattedID()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound getting a route of another app, got %v", err)
+	}
+	if routes, err := client.RouteList(); err != nil {
+		t.Fatal(err)
+	} else if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %d", len(routes))
+	}
+	if routes, err := client.AppRouteList(app.ID); err != nil {
+		t.Fatal(err)
+	} else if len(routes) != 1 || routes[0].ID != route.ID {
+		t.Fatalf("unexpected app routes %+v", routes)
+	}
+
+	route.Sticky = true
+	if err := client.UpdateRoute(app.ID, route.FormattedID(), route); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.GetRoute(app.ID, route.FormattedID()); err != nil {
+		t.Fatal(err)
+	} else if !got.Sticky {
+		t.Fatalf("expected UpdateRoute to make the route sticky, got %+v", got)
+	}
+
+	dm := &ct.DomainMigration{OldDomain: "example.com", Domain: "example.net"}
+	if err := client.PutDomain(dm); err != nil {
+		t.Fatal(err)
+	}
+	if dm.ID == "" || dm.FinishedAt == nil {
+		t.Fatalf("unexpected domain migration %+v", dm)
+	}
+	if got, err := client.GetRoute(app.ID, route.FormattedID()); err != nil {
+		t.Fatal(err)
+	} else if got.Domain != "app.example.net" {
+		t.Fatalf("expected the route domain to be migrated, got %s", got.Domain)
+	}
+
+	if err := client.DeleteRoute(app.ID, route.FormattedID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetRoute(app.ID, route.FormattedID()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after deleting the route, got %v", err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+
+	events := make(chan *ct.Event)
+	stream, err := client.StreamEvents(ct.StreamEventsOptions{ObjectTypes: []ct.EventType{ct.EventTypeApp}}, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	app := createApp(t, client, "client-events")
+	var streamed *ct.Event
+	select {
+	case streamed = <-events:
+		if streamed.AppID != app.ID || streamed.ObjectType != ct.EventTypeApp {
+			t.Fatalf("unexpected streamed event %+v", streamed)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for the app event")
+	}
+
+	list, err := client.ListEvents(ct.ListEventsOptions{AppID: app.ID, ObjectTypes: []ct.EventType{ct.EventTypeApp}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != streamed.ID {
+		t.Fatalf("unexpected event list %+v", list)
+	}
+	event, err := client.GetEvent(streamed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ct.App
+	if err := json.Unmarshal(event.Data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != app.ID {
+		t.Fatalf("unexpected event data %s", event.Data)
+	}
+	if _, err := client.GetEvent(streamed.ID + 1000); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for a missing event, got %v", err)
+	}
+}
+
+func TestVolumes(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app := createApp(t, client, "client-volumes")
+
+	volumes := make(chan *ct.Volume)
+	stream, err := client.StreamVolumes(nil, volumes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	select {
+	case v := <-volumes:
+		if v.ID != "" {
+			t.Fatalf("expected the end of the initial volumes, got %+v", v)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for the initial volumes")
+	}
+
+	vol := &ct.Volume{ID: "vol1", AppID: app.ID, State: ct.VolumeStateCreated}
+	if err := client.PutVolume(vol); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-volumes:
+		if v.ID != vol.ID {
+			t.Fatalf("unexpected streamed volume %+v", v)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for the volume to be streamed")
+	}
+
+	if got, err := client.GetVolume(app.ID, vol.ID); err != nil {
+		t.Fatal(err)
+	} else if got.State != ct.VolumeStateCreated {
+		t.Fatalf("unexpected volume %+v", got)
+	}
+	if list, err := client.VolumeList(); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 {
+		t.Fatalf("expected 1 volume, got %d", len(list))
+	}
+	if list, err := client.AppVolumeList(app.ID); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 || list[0].ID != vol.ID {
+		t.Fatalf("unexpected app volumes %+v", list)
+	}
+
+	if err := client.DecommissionVolume(app.ID, vol); err != nil {
+		t.Fatal(err)
+	}
+	if vol.DecommissionedAt == nil {
+		t.Fatalf("expected the volume to be decommissioned, got %+v", vol)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+
+	sinks := make(chan *ct.Sink)
+	stream, err := client.StreamSinks(nil, sinks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	select {
+	case s := <-sinks:
+		if s.ID != "" {
+			t.Fatalf("expected the end of the initial sinks, got %+v", s)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for the initial sinks")
+	}
+
+	config := json.RawMessage(`{"url":"syslog://logs.example.com:514"}`)
+	if err := client.CreateSink(&ct.Sink{Kind: "http", Config: &config}); err == nil {
+		t.Fatal("expected an error creating a sink of an unknown kind")
+	}
+	sink := &ct.Sink{Kind: ct.SinkKindSyslog, Config: &config}
+	if err := client.CreateSink(sink); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-sinks:
+		if s.ID != sink.ID {
+			t.Fatalf("unexpected streamed sink %+v", s)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for the sink to be streamed")
+	}
+
+	if got, err := client.GetSink(sink.ID); err != nil {
+		t.Fatal(err)
+	} else if got.Kind != ct.SinkKindSyslog {
+		t.Fatalf("unexpected sink %+v", got)
+	}
+	if list, err := client.ListSinks(); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 {
+		t.Fatalf("expected 1 sink, got %d", len(list))
+	}
+	if deleted, err := client.DeleteSink(sink.ID); err != nil {
+		t.Fatal(err)
+	} else if deleted.ID != sink.ID {
+		t.Fatalf("unexpected deleted sink %+v", deleted)
+	}
+	if _, err := client.GetSink(sink.ID); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound after deleting the sink, got %v", err)
+	}
+}
+
+func TestAppLog(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app, _ := createRunningApp(t, client, "client-log")
+
+	lines := 1
+	rc, err := client.GetAppLog(app.ID, &logagg.LogOpts{Lines: &lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var msgs []*logaggc.Message
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		var msg logaggc.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, &msg)
+	}
+	if len(msgs) != 1 || msgs[0].ProcessType != "web" {
+		t.Fatalf("expected one web log line, got %+v", msgs)
+	}
+
+	chunks := make(chan *ct.SSELogChunk)
+	stream, err := client.StreamAppLog(app.ID, nil, chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				t.Fatalf("log stream closed unexpectedly: %v", stream.Err())
+			}
+			if chunk.Event == "eof" {
+				return
+			}
+			var msg logaggc.Message
+			if err := json.Unmarshal(chunk.Data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.ProcessType != "web" {
+				t.Fatalf("unexpected log message %+v", msg)
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the end of the log")
+		}
+	}
+}
+
+func TestBackup(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app := createApp(t, client, "client-backup")
+
+	if _, err := client.GetBackupMeta(); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound before the first backup, got %v", err)
+	}
+	rc, err := client.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backup struct {
+		Apps []*ct.App `json:"apps"`
+	}
+	if err := json.Unmarshal(data, &backup); err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.Apps) != 1 || backup.Apps[0].ID != app.ID {
+		t.Fatalf("unexpected backup apps %+v", backup.Apps)
+	}
+
+	meta, err := client.GetBackupMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Status != ct.ClusterBackupStatusComplete || meta.Size != int64(len(data)) {
+		t.Fatalf("unexpected backup metadata %+v", meta)
+	}
+}
+
+func TestStatusAndCACert(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+
+	s, err := client.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Status != status.CodeHealthy {
+		t.Fatalf("expected a healthy status, got %+v", s)
+	}
+	cert, err := client.GetCACert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cert, testCACert) {
+		t.Fatalf("unexpected CA cert %q", cert)
+	}
+}
+
+func TestSetKey(t *testing.T) {
+	srv := httptest.NewServer(server.NewHandler(server.Config{
+		Store: server.NewMemoryStore(),
+		Keys:  []string{"secret"},
+	}))
+	defer srv.Close()
+	client, err := NewClient(srv.URL, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.AppList()
+	if err == nil || !strings.Contains(err.Error(), "unexpected status 401") {
+		t.Fatalf("expected an unauthorized error, got %v", err)
+	}
+	client.SetKey("secret")
+	if _, err := client.AppList(); err != nil {
+		t.Fatal(err)
+	}
+}
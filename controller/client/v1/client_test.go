@@ -0,0 +1,1052 @@
+package v1controller
+
+import (
+	"encoding/json"
+	"fmt"
+	logagg "github.com/flynn/flynn/logaggregator/types"
+	router "github.com/flynn/flynn/router/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httpclient"
+	"weo/pkg/httphelper"
+	"weo/pkg/status"
+)
+
+// fakeController is a fake controller which records the requests it receives
+// and responds to each with the response registered for its method and path.
+// Event stream requests are served the registered events and then kept open
+// until the client disconnects.
+type fakeController struct {
+	*httptest.Server
+
+	mtx       sync.Mutex
+	requests  []*fakeRequest
+	responses map[string]string
+	events    map[string][]*ct.Event
+}
+
+type fakeRequest struct {
+	Method string
+	Path   string
+	Key    string
+	Body   []byte
+}
+
+func newFakeController() *fakeController {
+	f := &fakeController{
+		responses: make(map[string]string),
+		events:    make(map[string][]*ct.Event),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeController) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	_, key, _ := req.BasicAuth()
+	path := req.URL.RequestURI()
+
+	f.mtx.Lock()
+	f.requests = append(f.requests, &fakeRequest{Method: req.Method, Path: path, Key: key, Body: body})
+	response, ok := f.responses[req.Method+" "+path]
+	events, isStream := f.events[path]
+	f.mtx.Unlock()
+
+	if isStream && req.Header.Get("Accept") == "text/event-stream" {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		for _, e := range events {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+		return
+	}
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(response))
+}
+
+// respond sets the response to requests with the given method and path
+func (f *fakeController) respond(method, path, response string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.responses[method+" "+path] = response
+}
+
+// stream sets the events sent to event stream requests of the given path
+func (f *fakeController) stream(path string, events ...*ct.Event) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.events[path] = events
+}
+
+// popRequests returns and forgets the requests received so far
+func (f *fakeController) popRequests() []*fakeRequest {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+func newTestClient(url string) *Client {
+	return &Client{
+		Client: &httpclient.Client{
+			ErrNotFound: ct.ErrNotFound,
+			URL:         url,
+			Key:         "test-key",
+			HTTP:        http.DefaultClient,
+		},
+	}
+}
+
+// newEvent returns an event of the given type with data encoded from v
+func newEvent(t *testing.T, id int64, typ ct.EventType, objectID string, v interface{}) *ct.Event {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ct.Event{ID: id, AppID: "app1", ObjectType: typ, ObjectID: objectID, Data: data}
+}
+
+// containsJSON returns whether the JSON object data contains the fields of
+// the JSON object fields
+func containsJSON(data []byte, fields string) (bool, error) {
+	var actual, expected map[string]interface{}
+	if err := json.Unmarshal(data, &actual); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(fields), &expected); err != nil {
+		return false, err
+	}
+	for k, v := range expected {
+		if !reflect.DeepEqual(actual[k], v) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func TestClientRequests(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	for _, test := range []struct {
+		name   string
+		method string
+		path   string
+		// in are fields expected in the request body
+		in string
+		// out is the response body which the result of call is expected
+		// to be decoded from
+		out  string
+		call func() (interface{}, error)
+	}{
+		{
+			name: "CreateArtifact", method: "POST", path: "/artifacts",
+			in:  `{"type":"weo","uri":"https://example.com/image.json"}`,
+			out: `{"id":"artifact1","type":"weo","uri":"https://example.com/image.json"}`,
+			call: func() (interface{}, error) {
+				a := &ct.Artifact{Type: ct.ArtifactTypeWeo, URI: "https://example.com/image.json"}
+				return a, c.CreateArtifact(a)
+			},
+		},
+		{
+			name: "CreateRelease", method: "POST", path: "/releases",
+			in:  `{"app_id":"app1","artifacts":["artifact1"]}`,
+			out: `{"id":"release1","app_id":"app1","artifacts":["artifact1"]}`,
+			call: func() (interface{}, error) {
+				r := &ct.Release{ArtifactIDs: []string{"artifact1"}}
+				return r, c.CreateRelease("app1", r)
+			},
+		},
+		{
+			name: "CreateApp", method: "POST", path: "/apps",
+			in:  `{"name":"myapp"}`,
+			out: `{"id":"app1","name":"myapp","meta":null}`,
+			call: func() (interface{}, error) {
+				a := &ct.App{Name: "myapp"}
+				return a, c.CreateApp(a)
+			},
+		},
+		{
+			name: "UpdateApp", method: "POST", path: "/apps/app1",
+			in:  `{"id":"app1","strategy":"one-by-one"}`,
+			out: `{"id":"app1","name":"myapp","meta":null,"strategy":"one-by-one"}`,
+			call: func() (interface{}, error) {
+				a := &ct.App{ID: "app1", Strategy: "one-by-one"}
+				return a, c.UpdateApp(a)
+			},
+		},
+		{
+			name: "UpdateAppMeta", method: "POST", path: "/apps/app1/meta",
+			in:  `{"id":"app1","meta":{}}`,
+			out: `{"id":"app1","name":"myapp","meta":{}}`,
+			call: func() (interface{}, error) {
+				a := &ct.App{ID: "app1", Meta: map[string]string{}}
+				return a, c.UpdateAppMeta(a)
+			},
+		},
+		{
+			name: "GetApp", method: "GET", path: "/apps/app1",
+			out:  `{"id":"app1","name":"myapp","meta":{"k":"v"},"release":"release1"}`,
+			call: func() (interface{}, error) { return c.GetApp("app1") },
+		},
+		{
+			name: "AppList", method: "GET", path: "/apps",
+			out:  `[{"id":"app1","name":"myapp","meta":null}]`,
+			call: func() (interface{}, error) { return c.AppList() },
+		},
+		{
+			name: "CreateProvider", method: "POST", path: "/providers",
+			in:  `{"name":"postgres","url":"http://pg-api.discoverd"}`,
+			out: `{"id":"provider1","name":"postgres","url":"http://pg-api.discoverd"}`,
+			call: func() (interface{}, error) {
+				p := &ct.Provider{Name: "postgres", URL: "http://pg-api.discoverd"}
+				return p, c.CreateProvider(p)
+			},
+		},
+		{
+			name: "GetProvider", method: "GET", path: "/providers/provider1",
+			out:  `{"id":"provider1","name":"postgres"}`,
+			call: func() (interface{}, error) { return c.GetProvider("provider1") },
+		},
+		{
+			name: "ProviderList", method: "GET", path: "/providers",
+			out:  `[{"id":"provider1","name":"postgres"}]`,
+			call: func() (interface{}, error) { return c.ProviderList() },
+		},
+		{
+			name: "ProvisionResource", method: "POST", path: "/providers/provider1/resources",
+			in:  `{"apps":["app1"]}`,
+			out: `{"id":"resource1","provider":"provider1","env":{"DATABASE_URL":"postgres://db"},"apps":["app1"]}`,
+			call: func() (interface{}, error) {
+				return c.ProvisionResource(&ct.ResourceReq{ProviderID: "provider1", Apps: []string{"app1"}})
+			},
+		},
+		{
+			name: "GetResource", method: "GET", path: "/providers/provider1/resources/resource1",
+			out:  `{"id":"resource1","provider":"provider1"}`,
+			call: func() (interface{}, error) { return c.GetResource("provider1", "resource1") },
+		},
+		{
+			name: "ResourceListAll", method: "GET", path: "/resources",
+			out:  `[{"id":"resource1","provider":"provider1"}]`,
+			call: func() (interface{}, error) { return c.ResourceListAll() },
+		},
+		{
+			name: "ResourceList", method: "GET", path: "/providers/provider1/resources",
+			out:  `[{"id":"resource1","provider":"provider1"}]`,
+			call: func() (interface{}, error) { return c.ResourceList("provider1") },
+		},
+		{
+			name: "AddResourceApp", method: "PUT", path: "/providers/provider1/resources/resource1/apps/app2",
+			out:  `{"id":"resource1","provider":"provider1","apps":["app1","app2"]}`,
+			call: func() (interface{}, error) { return c.AddResourceApp("provider1", "resource1", "app2") },
+		},
+		{
+			name: "DeleteResourceApp", method: "DELETE", path: "/providers/provider1/resources/resource1/apps/app2",
+			out:  `{"id":"resource1","provider":"provider1","apps":["app1"]}`,
+			call: func() (interface{}, error) { return c.DeleteResourceApp("provider1", "resource1", "app2") },
+		},
+		{
+			name: "AppResourceList", method: "GET", path: "/apps/app1/resources",
+			out:  `[{"id":"resource1","provider":"provider1","apps":["app1"]}]`,
+			call: func() (interface{}, error) { return c.AppResourceList("app1") },
+		},
+		{
+			name: "PutResource", method: "PUT", path: "/providers/provider1/resources/resource1",
+			in:  `{"id":"resource1","provider":"provider1","external_id":"db1"}`,
+			out: `{"id":"resource1","provider":"provider1","external_id":"db1"}`,
+			call: func() (interface{}, error) {
+				r := &ct.Resource{ID: "resource1", ProviderID: "provider1", ExternalID: "db1"}
+				return r, c.PutResource(r)
+			},
+		},
+		{
+			name: "DeleteResource", method: "DELETE", path: "/providers/provider1/resources/resource1",
+			out:  `{"id":"resource1","provider":"provider1"}`,
+			call: func() (interface{}, error) { return c.DeleteResource("provider1", "resource1") },
+		},
+		{
+			name: "PutScaleRequest", method: "PUT", path: "/apps/app1/scale/release1",
+			in:  `{"app":"app1","release":"release1","new_processes":{"web":2}}`,
+			out: `{"id":"scale1","app":"app1","release":"release1","state":"pending","new_processes":{"web":2},"created_at":null,"updated_at":null}`,
+			call: func() (interface{}, error) {
+				procs := map[string]int{"web": 2}
+				req := &ct.ScaleRequest{AppID: "app1", ReleaseID: "release1", NewProcesses: &procs}
+				return req, c.PutScaleRequest(req)
+			},
+		},
+		{
+			name: "PutFormation", method: "PUT", path: "/apps/app1/formations/release1",
+			in:  `{"app":"app1","release":"release1","processes":{"web":1}}`,
+			out: `{"app":"app1","release":"release1","processes":{"web":1}}`,
+			call: func() (interface{}, error) {
+				f := &ct.Formation{AppID: "app1", ReleaseID: "release1", Processes: map[string]int{"web": 1}}
+				return f, c.PutFormation(f)
+			},
+		},
+		{
+			name: "GetFormation", method: "GET", path: "/apps/app1/formations/release1",
+			out:  `{"app":"app1","release":"release1","processes":{"web":1}}`,
+			call: func() (interface{}, error) { return c.GetFormation("app1", "release1") },
+		},
+		{
+			name: "GetExpandedFormation", method: "GET", path: "/apps/app1/formations/release1?expand=true",
+			out:  `{"app":{"id":"app1","meta":null},"release":{"id":"release1"},"processes":{"web":1},"updated_at":"2020-01-01T00:00:00Z"}`,
+			call: func() (interface{}, error) { return c.GetExpandedFormation("app1", "release1") },
+		},
+		{
+			name: "FormationList", method: "GET", path: "/apps/app1/formations",
+			out:  `[{"app":"app1","release":"release1","processes":{"web":1}}]`,
+			call: func() (interface{}, error) { return c.FormationList("app1") },
+		},
+		{
+			name: "FormationListActive", method: "GET", path: "/formations?active=true",
+			out:  `[{"app":{"id":"app1","meta":null},"processes":{"web":1},"updated_at":"2020-01-01T00:00:00Z"}]`,
+			call: func() (interface{}, error) { return c.FormationListActive() },
+		},
+		{
+			name: "DeleteFormation", method: "DELETE", path: "/apps/app1/formations/release1",
+			call: func() (interface{}, error) { return nil, c.DeleteFormation("app1", "release1") },
+		},
+		{
+			name: "PutJob", method: "PUT", path: "/apps/app1/jobs/job1",
+			in:  `{"uuid":"job1","app":"app1","state":"up"}`,
+			out: `{"uuid":"job1","app":"app1","state":"up"}`,
+			call: func() (interface{}, error) {
+				j := &ct.Job{UUID: "job1", AppID: "app1", State: ct.JobStateUp}
+				return j, c.PutJob(j)
+			},
+		},
+		{
+			name: "DeleteJob", method: "DELETE", path: "/apps/app1/jobs/job1",
+			call: func() (interface{}, error) { return nil, c.DeleteJob("app1", "job1") },
+		},
+		{
+			name: "RunJobDetached", method: "POST", path: "/apps/app1/jobs",
+			in:  `{"release":"release1","args":["bash"]}`,
+			out: `{"uuid":"job1","app":"app1","release":"release1","state":"pending","args":["bash"]}`,
+			call: func() (interface{}, error) {
+				return c.RunJobDetached("app1", &ct.NewJob{ReleaseID: "release1", Args: []string{"bash"}})
+			},
+		},
+		{
+			name: "GetJob", method: "GET", path: "/apps/app1/jobs/job1",
+			out:  `{"uuid":"job1","app":"app1","state":"up"}`,
+			call: func() (interface{}, error) { return c.GetJob("app1", "job1") },
+		},
+		{
+			name: "JobList", method: "GET", path: "/apps/app1/jobs",
+			out:  `[{"uuid":"job1","app":"app1","state":"up"}]`,
+			call: func() (interface{}, error) { return c.JobList("app1") },
+		},
+		{
+			name: "JobListActive", method: "GET", path: "/active-jobs",
+			out:  `[{"uuid":"job1","app":"app1","state":"up","host_id":"host1"}]`,
+			call: func() (interface{}, error) { return c.JobListActive() },
+		},
+		{
+			name: "SetAppRelease", method: "PUT", path: "/apps/app1/release",
+			in:   `{"id":"release1"}`,
+			call: func() (interface{}, error) { return nil, c.SetAppRelease("app1", "release1") },
+		},
+		{
+			name: "GetAppRelease", method: "GET", path: "/apps/app1/release",
+			out:  `{"id":"release1","app_id":"app1"}`,
+			call: func() (interface{}, error) { return c.GetAppRelease("app1") },
+		},
+		{
+			name: "GetRelease", method: "GET", path: "/releases/release1",
+			out:  `{"id":"release1","app_id":"app1"}`,
+			call: func() (interface{}, error) { return c.GetRelease("release1") },
+		},
+		{
+			name: "ReleaseList", method: "GET", path: "/releases",
+			out:  `[{"id":"release1","app_id":"app1"}]`,
+			call: func() (interface{}, error) { return c.ReleaseList() },
+		},
+		{
+			name: "AppReleaseList", method: "GET", path: "/apps/app1/releases",
+			out:  `[{"id":"release1","app_id":"app1"}]`,
+			call: func() (interface{}, error) { return c.AppReleaseList("app1") },
+		},
+		{
+			name: "GetArtifact", method: "GET", path: "/artifacts/artifact1",
+			out:  `{"id":"artifact1","type":"weo"}`,
+			call: func() (interface{}, error) { return c.GetArtifact("artifact1") },
+		},
+		{
+			name: "ArtifactList", method: "GET", path: "/artifacts",
+			out:  `[{"id":"artifact1","type":"weo"}]`,
+			call: func() (interface{}, error) { return c.ArtifactList() },
+		},
+		{
+			name: "RouteList", method: "GET", path: "/routes",
+			out:  `[{"type":"http","id":"http/1","service":"myapp-web","leader":false,"domain":"example.com","created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:00Z"}]`,
+			call: func() (interface{}, error) { return c.RouteList() },
+		},
+		{
+			name: "AppRouteList", method: "GET", path: "/apps/app1/routes",
+			out:  `[{"type":"tcp","id":"tcp/1","service":"myapp-web","port":3000,"leader":true,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:00Z"}]`,
+			call: func() (interface{}, error) { return c.AppRouteList("app1") },
+		},
+		{
+			name: "GetRoute", method: "GET", path: "/apps/app1/routes/http/1",
+			out:  `{"type":"http","id":"http/1","service":"myapp-web","leader":false,"domain":"example.com","created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:00Z"}`,
+			call: func() (interface{}, error) { return c.GetRoute("app1", "http/1") },
+		},
+		{
+			name: "CreateRoute", method: "POST", path: "/apps/app1/routes",
+			in:  `{"type":"http","service":"myapp-web","domain":"example.com"}`,
+			out: `{"type":"http","id":"http/1","service":"myapp-web","leader":false,"domain":"example.com","created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:00Z"}`,
+			call: func() (interface{}, error) {
+				r := (&router.HTTPRoute{Service: "myapp-web", Domain: "example.com"}).ToRoute()
+				return r, c.CreateRoute("app1", r)
+			},
+		},
+		{
+			name: "UpdateRoute", method: "PUT", path: "/apps/app1/routes/http/1",
+			in:  `{"type":"http","id":"http/1","sticky":true}`,
+			out: `{"type":"http","id":"http/1","service":"myapp-web","leader":false,"sticky":true,"domain":"example.com","created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:00Z"}`,
+			call: func() (interface{}, error) {
+				r := (&router.HTTPRoute{ID: "http/1", Service: "myapp-web", Domain: "example.com", Sticky: true}).ToRoute()
+				return r, c.UpdateRoute("app1", "http/1", r)
+			},
+		},
+		{
+			name: "DeleteRoute", method: "DELETE", path: "/apps/app1/routes/http/1",
+			call: func() (interface{}, error) { return nil, c.DeleteRoute("app1", "http/1") },
+		},
+		{
+			name: "GetDeployment", method: "GET", path: "/deployments/deployment1",
+			out:  `{"id":"deployment1","app":"app1","new_release":"release1","status":"running"}`,
+			call: func() (interface{}, error) { return c.GetDeployment("deployment1") },
+		},
+		{
+			name: "CreateDeployment", method: "POST", path: "/apps/app1/deploy",
+			in:  `{"id":"release1","strategy":"one-by-one","deploy_timeout":60}`,
+			out: `{"id":"deployment1","app":"app1","new_release":"release1","strategy":"one-by-one","status":"pending","deploy_timeout":60}`,
+			call: func() (interface{}, error) {
+				return c.CreateDeployment("app1", "release1", ct.DeployOptions{Strategy: "one-by-one", DeployTimeout: 60})
+			},
+		},
+		{
+			name: "DeploymentList", method: "GET", path: "/apps/app1/deployments",
+			out:  `[{"id":"deployment1","app":"app1","status":"complete"}]`,
+			call: func() (interface{}, error) { return c.DeploymentList("app1") },
+		},
+		{
+			name: "GetEvent", method: "GET", path: "/events/5",
+			out:  `{"id":5,"app":"app1","object_type":"app","object_id":"app1","data":{"id":"app1"}}`,
+			call: func() (interface{}, error) { return c.GetEvent(5) },
+		},
+		{
+			name: "ListEvents", method: "GET", path: "/events?app_id=app1&before_id=10&count=2&object_types=job%2Cdeployment",
+			out: `[{"id":9,"app":"app1","object_type":"job","object_id":"job1","data":{"uuid":"job1"}}]`,
+			call: func() (interface{}, error) {
+				before := int64(10)
+				return c.ListEvents(ct.ListEventsOptions{
+					AppID:       "app1",
+					BeforeID:    &before,
+					ObjectTypes: []ct.EventType{ct.EventTypeJob, ct.EventTypeDeployment},
+					Count:       2,
+				})
+			},
+		},
+		{
+			name: "GetVolume", method: "GET", path: "/apps/app1/volumes/volume1",
+			out:  `{"id":"volume1","host_id":"host1","type":"data","state":"created","app":"app1"}`,
+			call: func() (interface{}, error) { return c.GetVolume("app1", "volume1") },
+		},
+		{
+			name: "PutVolume", method: "PUT", path: "/volumes/volume1",
+			in:  `{"id":"volume1","state":"created"}`,
+			out: `{"id":"volume1","host_id":"host1","type":"data","state":"created"}`,
+			call: func() (interface{}, error) {
+				v := &ct.Volume{ID: "volume1", HostID: "host1", Type: "data", State: ct.VolumeStateCreated}
+				return v, c.PutVolume(v)
+			},
+		},
+		{
+			name: "VolumeList", method: "GET", path: "/volumes",
+			out:  `[{"id":"volume1","host_id":"host1","type":"data","state":"created"}]`,
+			call: func() (interface{}, error) { return c.VolumeList() },
+		},
+		{
+			name: "AppVolumeList", method: "GET", path: "/apps/app1/volumes",
+			out:  `[{"id":"volume1","host_id":"host1","type":"data","state":"created","app":"app1"}]`,
+			call: func() (interface{}, error) { return c.AppVolumeList("app1") },
+		},
+		{
+			name: "DecommissionVolume", method: "PUT", path: "/apps/app1/volumes/volume1/decommission",
+			in:  `{"id":"volume1"}`,
+			out: `{"id":"volume1","host_id":"host1","type":"data","state":"created","app":"app1","decommissioned_at":"2020-01-01T00:00:00Z"}`,
+			call: func() (interface{}, error) {
+				v := &ct.Volume{ID: "volume1", HostID: "host1", Type: "data", State: ct.VolumeStateCreated, AppID: "app1"}
+				return v, c.DecommissionVolume("app1", v)
+			},
+		},
+		{
+			name: "GetBackupMeta", method: "GET", path: "/backup",
+			out:  `{"id":"backup1","status":"complete","size":1024}`,
+			call: func() (interface{}, error) { return c.GetBackupMeta() },
+		},
+		{
+			name: "ScheduleAppGarbageCollection", method: "POST", path: "/apps/app1/gc",
+			call: func() (interface{}, error) { return nil, c.ScheduleAppGarbageCollection("app1") },
+		},
+		{
+			name: "CreateSink", method: "POST", path: "/sinks",
+			in:  `{"kind":"syslog"}`,
+			out: `{"id":"sink1","kind":"syslog"}`,
+			call: func() (interface{}, error) {
+				s := &ct.Sink{Kind: ct.SinkKindSyslog}
+				return s, c.CreateSink(s)
+			},
+		},
+		{
+			name: "GetSink", method: "GET", path: "/sinks/sink1",
+			out:  `{"id":"sink1","kind":"syslog"}`,
+			call: func() (interface{}, error) { return c.GetSink("sink1") },
+		},
+		{
+			name: "DeleteSink", method: "DELETE", path: "/sinks/sink1",
+			out:  `{"id":"sink1","kind":"syslog"}`,
+			call: func() (interface{}, error) { return c.DeleteSink("sink1") },
+		},
+		{
+			name: "ListSinks", method: "GET", path: "/sinks",
+			out:  `[{"id":"sink1","kind":"syslog"}]`,
+			call: func() (interface{}, error) { return c.ListSinks() },
+		},
+		{
+			name: "PutDomain", method: "PUT", path: "/domain",
+			in:  `{"old_domain":"old.example.com","domain":"new.example.com"}`,
+			out: `{"id":"migration1","old_domain":"old.example.com","domain":"new.example.com"}`,
+			call: func() (interface{}, error) {
+				dm := &ct.DomainMigration{OldDomain: "old.example.com", Domain: "new.example.com"}
+				return dm, c.PutDomain(dm)
+			},
+		},
+	} {
+		srv.respond(test.method, test.path, test.out)
+		res, err := test.call()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+
+		requests := srv.popRequests()
+		if len(requests) != 1 {
+			t.Errorf("%s: expected 1 request, got %d", test.name, len(requests))
+			continue
+		}
+		req := requests[0]
+		if req.Method != test.method || req.Path != test.path {
+			t.Errorf("%s: expected request %s %s, got %s %s", test.name, test.method, test.path, req.Method, req.Path)
+		}
+		if req.Key != "test-key" {
+			t.Errorf("%s: expected the request to be authenticated with the client key, got %q", test.name, req.Key)
+		}
+		if test.in != "" {
+			if ok, err := containsJSON(req.Body, test.in); err != nil || !ok {
+				t.Errorf("%s: expected request body to contain %s, got %s (err: %v)", test.name, test.in, req.Body, err)
+			}
+		}
+
+		if res == nil {
+			continue
+		}
+		expected := reflect.New(reflect.TypeOf(res))
+		if err := json.Unmarshal([]byte(test.out), expected.Interface()); err != nil {
+			t.Fatalf("%s: error decoding response: %s", test.name, err)
+		}
+		if !reflect.DeepEqual(res, expected.Elem().Interface()) {
+			t.Errorf("%s: expected result %+v, got %+v", test.name, expected.Elem().Interface(), res)
+		}
+	}
+}
+
+func TestClientValidation(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	for _, test := range []struct {
+		name string
+		call func() error
+	}{
+		{"PutDomain missing domain", func() error { return c.PutDomain(&ct.DomainMigration{OldDomain: "a"}) }},
+		{"PutDomain missing old domain", func() error { return c.PutDomain(&ct.DomainMigration{Domain: "b"}) }},
+		{"UpdateApp", func() error { return c.UpdateApp(&ct.App{}) }},
+		{"UpdateAppMeta", func() error { return c.UpdateAppMeta(&ct.App{}) }},
+		{"ProvisionResource", func() error { _, err := c.ProvisionResource(&ct.ResourceReq{}); return err }},
+		{"PutResource", func() error { return c.PutResource(&ct.Resource{ID: "resource1"}) }},
+		{"PutScaleRequest", func() error { return c.PutScaleRequest(&ct.ScaleRequest{AppID: "app1"}) }},
+		{"ScaleAppRelease", func() error { return c.ScaleAppRelease("app1", "release1", ct.ScaleOptions{}) }},
+		{"PutFormation", func() error { return c.PutFormation(&ct.Formation{ReleaseID: "release1"}) }},
+		{"PutJob", func() error { return c.PutJob(&ct.Job{AppID: "app1"}) }},
+		{"GetVolume", func() error { _, err := c.GetVolume("app1", ""); return err }},
+		{"PutVolume", func() error { return c.PutVolume(&ct.Volume{}) }},
+		{"AppVolumeList", func() error { _, err := c.AppVolumeList(""); return err }},
+		{"DecommissionVolume", func() error { return c.DecommissionVolume("app1", &ct.Volume{}) }},
+	} {
+		if err := test.call(); err == nil || !strings.HasPrefix(err.Error(), "controller: missing") {
+			t.Errorf("%s: expected missing field error, got %v", test.name, err)
+		}
+	}
+	if requests := srv.popRequests(); len(requests) != 0 {
+		t.Fatalf("expected no requests to be sent, got %d", len(requests))
+	}
+}
+
+func TestClientNotFound(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	if _, err := c.GetApp("missing"); err != ct.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestClientRawRequests(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	srv.respond("GET", "/ca-cert", "-----BEGIN CERTIFICATE-----")
+	cert, err := c.GetCACert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cert) != "-----BEGIN CERTIFICATE-----" {
+		t.Fatalf("unexpected CA cert %q", cert)
+	}
+
+	srv.respond("GET", "/backup", "backup data")
+	backup, err := c.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(backup)
+	backup.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "backup data" {
+		t.Fatalf("unexpected backup %q", data)
+	}
+
+	lines := 10
+	srv.respond("GET", "/apps/app1/log?lines=10&stream_types=stdout%2Cstderr", "log line\n")
+	log, err := c.GetAppLog("app1", &logagg.LogOpts{Lines: &lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadAll(log)
+	log.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "log line\n" {
+		t.Fatalf("unexpected log %q", data)
+	}
+
+	srv.respond("GET", status.Path, `{"data":{"status":"healthy","version":"v1"}}`)
+	s, err := c.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Status != status.CodeHealthy || s.Version != "v1" {
+		t.Fatalf("unexpected status %+v", s)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var mtx sync.Mutex
+	attempts := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mtx.Lock()
+		attempts[req.Method]++
+		n := attempts[req.Method]
+		mtx.Unlock()
+		// fail the first attempt of each method with a retryable error
+		if n == 1 {
+			httphelper.ServiceUnavailableError(w, "starting up")
+			return
+		}
+		httphelper.JSON(w, 200, &ct.App{ID: "app1", Name: "myapp"})
+	}))
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	if _, err := c.GetApp("app1"); err != nil {
+		t.Fatalf("expected GET to be retried, got %s", err)
+	}
+	if attempts["GET"] != 2 {
+		t.Fatalf("expected 2 GET attempts, got %d", attempts["GET"])
+	}
+
+	err := c.CreateApp(&ct.App{Name: "myapp"})
+	if !httphelper.IsRetryableError(err) {
+		t.Fatalf("expected retryable error from POST, got %v", err)
+	}
+	if attempts["POST"] != 1 {
+		t.Fatalf("expected POST not to be retried, got %d attempts", attempts["POST"])
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	srv.stream("/events?app_id=app1&count=2&object_id=job1&object_types=job&past=true",
+		newEvent(t, 1, ct.EventTypeJob, "job1", &ct.Job{UUID: "job1", State: ct.JobStateStarting}),
+		newEvent(t, 2, ct.EventTypeJob, "job1", &ct.Job{UUID: "job1", State: ct.JobStateUp}),
+	)
+	events := make(chan *ct.Event)
+	stream, err := c.StreamEvents(ct.StreamEventsOptions{
+		AppID:       "app1",
+		ObjectID:    "job1",
+		ObjectTypes: []ct.EventType{ct.EventTypeJob},
+		Past:        true,
+		Count:       2,
+	}, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		stream.Close()
+		for range events {
+		}
+	}()
+	for _, id := range []int64{1, 2} {
+		select {
+		case e := <-events:
+			if e.ID != id || e.ObjectType != ct.EventTypeJob {
+				t.Fatalf("expected job event %d, got %+v", id, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for event %d", id)
+		}
+	}
+}
+
+func TestWatchJobEvents(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	srv.stream("/events?app_id=app1&object_types=job",
+		newEvent(t, 1, ct.EventTypeJob, "job1", &ct.Job{UUID: "job1", Type: "web", ReleaseID: "release1", State: ct.JobStateUp}),
+		newEvent(t, 2, ct.EventTypeJob, "job2", &ct.Job{UUID: "job2", Type: "web", ReleaseID: "release0", State: ct.JobStateUp}),
+		newEvent(t, 3, ct.EventTypeJob, "job3", &ct.Job{UUID: "job3", Type: "worker", ReleaseID: "release1", State: ct.JobStateUp}),
+		newEvent(t, 4, ct.EventTypeJob, "job4", &ct.Job{UUID: "job4", Type: "worker", ReleaseID: "release1", State: ct.JobStateCrashed}),
+	)
+	watcher, err := c.WatchJobEvents("app1", "release1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	var seen []string
+	expected := c.ExpectedScalingEvents(
+		map[string]int{"worker": 1},
+		map[string]int{"web": 1, "worker": 1},
+		nil, 1,
+	)
+	expected["worker"] = map[ct.JobState]int{ct.JobStateUp: 1, ct.JobStateDown: 1}
+	err = watcher.WaitFor(expected, 5*time.Second, func(job *ct.Job) error {
+		seen = append(seen, job.UUID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// jobs of other releases are skipped and crashed jobs are counted as down
+	if !reflect.DeepEqual(seen, []string{"job1", "job3", "job4"}) {
+		t.Fatalf("unexpected jobs %v", seen)
+	}
+}
+
+func TestExpectedScalingEvents(t *testing.T) {
+	c := &Client{}
+	events := c.ExpectedScalingEvents(
+		map[string]int{"web": 1, "worker": 3, "omni": 1},
+		map[string]int{"web": 3, "worker": 1, "omni": 2, "clock": 0},
+		map[string]ct.ProcessType{"omni": {Omni: true}},
+		4,
+	)
+	expected := ct.JobEvents{
+		"web":    ct.JobUpEvents(2),
+		"worker": ct.JobDownEvents(2),
+		"omni":   ct.JobUpEvents(4),
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("expected %v, got %v", expected, events)
+	}
+}
+
+func TestDeleteApp(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	deletion := &ct.AppDeletion{AppID: "app1", DeletedReleases: []*ct.Release{{ID: "release1"}}}
+	srv.stream("/events?app_id=app1&object_types=app_deletion",
+		newEvent(t, 1, ct.EventTypeAppDeletion, "app1", &ct.AppDeletionEvent{AppDeletion: deletion}),
+	)
+	srv.respond("DELETE", "/apps/app1", "")
+	res, err := c.DeleteApp("app1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AppID != "app1" || len(res.DeletedReleases) != 1 || res.DeletedReleases[0].ID != "release1" {
+		t.Fatalf("unexpected app deletion %+v", res)
+	}
+
+	srv.stream("/events?app_id=app2&object_types=app_deletion",
+		newEvent(t, 1, ct.EventTypeAppDeletion, "app2", &ct.AppDeletionEvent{Error: "boom"}),
+	)
+	srv.respond("DELETE", "/apps/app2", "")
+	if _, err := c.DeleteApp("app2"); err == nil || err.Error() != "boom" {
+		t.Fatalf("expected deletion error, got %v", err)
+	}
+}
+
+func TestDeleteRelease(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	deletion := &ct.ReleaseDeletion{AppID: "app1", ReleaseID: "release1", DeletedFiles: []string{"file1"}}
+	srv.stream("/events?app_id=app1&object_id=release1&object_types=release_deletion",
+		newEvent(t, 1, ct.EventTypeReleaseDeletion, "release1", &ct.ReleaseDeletionEvent{ReleaseDeletion: deletion}),
+	)
+	srv.respond("DELETE", "/apps/app1/releases/release1", "")
+	res, err := c.DeleteRelease("app1", "release1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, deletion) {
+		t.Fatalf("expected %+v, got %+v", deletion, res)
+	}
+}
+
+func TestScaleAppRelease(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	procs := map[string]int{"web": 2}
+	srv.respond("PUT", "/apps/app1/scale/release1", `{"id":"scale1","app":"app1","release":"release1","state":"pending","new_processes":{"web":2},"created_at":null,"updated_at":null}`)
+	srv.stream("/events?app_id=app1&object_types=job%2Cscale_request",
+		newEvent(t, 1, ct.EventTypeJob, "job1", &ct.Job{UUID: "job1", ReleaseID: "release1", Type: "web", State: ct.JobStateUp}),
+		newEvent(t, 2, ct.EventTypeJob, "job2", &ct.Job{UUID: "job2", ReleaseID: "release0", Type: "web", State: ct.JobStateDown}),
+		newEvent(t, 3, ct.EventTypeScaleRequest, "scale0", &ct.ScaleRequest{ID: "scale0", State: ct.ScaleRequestStateCancelled}),
+		newEvent(t, 4, ct.EventTypeScaleRequest, "scale1", &ct.ScaleRequest{ID: "scale1", State: ct.ScaleRequestStateComplete}),
+	)
+
+	var jobs []string
+	var scaleReq *ct.ScaleRequest
+	err := c.ScaleAppRelease("app1", "release1", ct.ScaleOptions{
+		Processes:            procs,
+		ScaleRequestCallback: func(req *ct.ScaleRequest) { scaleReq = req },
+		JobEventCallback: func(job *ct.Job) error {
+			jobs = append(jobs, job.UUID)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scaleReq == nil || scaleReq.ID != "scale1" {
+		t.Fatalf("expected the scale request callback to be called with the created request, got %+v", scaleReq)
+	}
+	// only job events of the release are passed to the callback, and other
+	// scale requests are ignored
+	if !reflect.DeepEqual(jobs, []string{"job1"}) {
+		t.Fatalf("unexpected job events %v", jobs)
+	}
+
+	// the request is not waited for with NoWait
+	srv.popRequests()
+	if err := c.ScaleAppRelease("app1", "release1", ct.ScaleOptions{Processes: procs, NoWait: true}); err != nil {
+		t.Fatal(err)
+	}
+	requests := srv.popRequests()
+	if len(requests) != 1 || requests[0].Method != "PUT" {
+		t.Fatalf("expected a single scale request, got %d requests", len(requests))
+	}
+}
+
+func TestDeployAppRelease(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	// an initial deploy finishes immediately
+	srv.respond("POST", "/apps/app1/deploy", `{"id":"deployment1","app":"app1","status":"complete","finished_at":"2020-01-01T00:00:00Z"}`)
+	if err := c.DeployAppRelease("app1", "release1", ct.DeployOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range srv.popRequests() {
+		if strings.HasPrefix(req.Path, "/events") {
+			t.Fatal("expected an initial deploy not to stream events")
+		}
+	}
+
+	srv.respond("POST", "/apps/app1/deploy", `{"id":"deployment2","app":"app1","status":"pending"}`)
+	srv.stream("/events?app_id=app1&object_id=deployment2&object_types=deployment&past=true",
+		newEvent(t, 1, ct.EventTypeDeployment, "deployment2", &ct.DeploymentEvent{Status: "pending"}),
+		newEvent(t, 2, ct.EventTypeDeployment, "deployment2", &ct.DeploymentEvent{Status: "running"}),
+		newEvent(t, 3, ct.EventTypeDeployment, "deployment2", &ct.DeploymentEvent{Status: "complete"}),
+	)
+	if err := c.DeployAppRelease("app1", "release1", ct.DeployOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.respond("POST", "/apps/app1/deploy", `{"id":"deployment3","app":"app1","status":"pending"}`)
+	srv.stream("/events?app_id=app1&object_id=deployment3&object_types=deployment&past=true",
+		newEvent(t, 1, ct.EventTypeDeployment, "deployment3", &ct.DeploymentEvent{Status: "failed", Error: "web job failed to start"}),
+	)
+	if err := c.DeployAppRelease("app1", "release1", ct.DeployOptions{}, nil); err == nil || !strings.Contains(err.Error(), "web job failed to start") {
+		t.Fatalf("expected deployment error, got %v", err)
+	}
+}
+
+func TestStreamSince(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	// the streams send objects updated since the given time, or since the
+	// epoch if nil
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.stream("/formations?since=2020-01-01T00:00:00Z", &ct.Event{ID: 1})
+	srv.stream("/volumes?since=1970-01-01T00:00:00Z", &ct.Event{ID: 1})
+	srv.stream("/sinks?since=2020-01-01T00:00:00Z", &ct.Event{ID: 1})
+
+	formations := make(chan *ct.ExpandedFormation)
+	formationStream, err := c.StreamFormations(&since, formations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer formationStream.Close()
+	volumes := make(chan *ct.Volume)
+	volumeStream, err := c.StreamVolumes(nil, volumes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer volumeStream.Close()
+	sinks := make(chan *ct.Sink)
+	sinkStream, err := c.StreamSinks(&since, sinks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sinkStream.Close()
+
+	timeout := time.After(5 * time.Second)
+	for _, ch := range []interface{}{formations, volumes, sinks} {
+		chosen, _, _ := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timeout)},
+		})
+		if chosen == 1 {
+			t.Fatalf("timed out waiting for %T", ch)
+		}
+	}
+}
+
+func TestStreamAppLog(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	srv.stream("/apps/app1/log?follow=true&stream_types=stdout%2Cstderr",
+		&ct.Event{ID: 1, Data: json.RawMessage(`{"msg":"hello"}`)},
+	)
+	chunks := make(chan *ct.SSELogChunk)
+	stream, err := c.StreamAppLog("app1", &logagg.LogOpts{Follow: true}, chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	select {
+	case chunk := <-chunks:
+		if string(chunk.Data) != `{"msg":"hello"}` {
+			t.Fatalf("unexpected log chunk data %s", chunk.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for log chunk")
+	}
+}
+
+func TestSetKey(t *testing.T) {
+	srv := newFakeController()
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	c.SetKey("new-key")
+	srv.respond("GET", "/apps", "[]")
+	if _, err := c.AppList(); err != nil {
+		t.Fatal(err)
+	}
+	if requests := srv.popRequests(); len(requests) != 1 || requests[0].Key != "new-key" {
+		t.Fatal("expected the request to be authenticated with the new key")
+	}
+}
+
+func TestRunJobAttached(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" || req.URL.Path != "/apps/app1/jobs" || req.Header.Get("Upgrade") != "weo-attach/0" {
+			w.WriteHeader(400)
+			return
+		}
+		var job ct.NewJob
+		if err := json.NewDecoder(req.Body).Decode(&job); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: upgrade\r\nUpgrade: weo-attach/0\r\n\r\n")
+		buf.WriteString(strings.Join(job.Args, " "))
+		buf.Flush()
+	}))
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	conn, err := c.RunJobAttached("app1", &ct.NewJob{ReleaseID: "release1", Args: []string{"echo", "hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	data, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "echo hello" {
+		t.Fatalf("unexpected attached output %q", data)
+	}
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"encoding/json"
+	ct "weo/controller/types"
+)
+
+func (s *Store) CreateApp(app *ct.App) error {
+	data, err := json.Marshal(app)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO apps (app_id, name, etag, data, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		app.ID, app.Name, app.ETag(), data, timestamp(app.CreatedAt),
+	)
+	if isUniqueViolation(err) {
+		return ct.ValidationError{Field: "name", Message: "is already taken"}
+	}
+	return err
+}
+
+func (s *Store) GetApp(id string) (*ct.App, error) {
+	app := &ct.App{}
+	return app, s.getData(app, `SELECT data FROM apps WHERE app_id = $1 OR name = $1`, id)
+}
+
+func (s *Store) ListApps() ([]*ct.App, error) {
+	list := []*ct.App{}
+	return list, s.queryData(func(data []byte) error {
+		app := &ct.App{}
+		list = append(list, app)
+		return json.Unmarshal(data, app)
+	}, `SELECT data FROM apps ORDER BY created_at DESC`)
+}
+
+func (s *Store) UpdateApp(app *ct.App, ifMatch string) error {
+	data, err := json.Marshal(app)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE apps SET name = $2, etag = $3, data = $4 WHERE app_id = $1`
+	args := []interface{}{app.ID, app.Name, app.ETag(), data}
+	if ifMatch == "" {
+		err = s.execOne(query, args...)
+	} else {
+		err = s.execIfMatch(
+			`SELECT COUNT(*) FROM apps WHERE app_id = $1`, []interface{}{app.ID},
+			query+` AND etag = $5`, append(args, ifMatch)...,
+		)
+	}
+	if isUniqueViolation(err) {
+		return ct.ValidationError{Field: "name", Message: "is already taken"}
+	}
+	return err
+}
+
+func (s *Store) DeleteApp(id string) error {
+	return s.execOne(`DELETE FROM apps WHERE app_id = $1`, id)
+}
@@ -0,0 +1,194 @@
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	ct "weo/controller/types"
+)
+
+func openTestDB(t *testing.T) *DB {
+	db, err := Open("sqlite3::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		dsn  string
+	}{
+		{path: "/var/lib/weo/controller.db", dsn: "/var/lib/weo/controller.db?_busy_timeout=5000"},
+		{path: ":memory:", dsn: ":memory:?_busy_timeout=5000"},
+		{path: "controller.db?cache=shared", dsn: "controller.db?cache=shared&_busy_timeout=5000"},
+		{path: "controller.db?_busy_timeout=100", dsn: "controller.db?_busy_timeout=100"},
+	} {
+		if dsn := sqliteDSN(test.path); dsn != test.dsn {
+			t.Errorf("sqliteDSN(%q): expected %q, got %q", test.path, test.dsn, dsn)
+		}
+	}
+}
+
+func TestOpenSQLiteWithQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weo-data-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := Open("sqlite3:" + filepath.Join(dir, "controller.db") + "?cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := os.Stat(filepath.Join(dir, "controller.db")); err != nil {
+		t.Fatalf("expected database file to be created: %s", err)
+	}
+}
+
+func TestOpenUnsupportedURL(t *testing.T) {
+	if _, err := Open("mysql://localhost/weo"); err != ErrUnsupportedURL {
+		t.Fatalf("expected ErrUnsupportedURL, got %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	countMigrations := func() int {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := countMigrations(); n != len(migrations) {
+		t.Fatalf("expected %d applied migrations, got %d", len(migrations), n)
+	}
+
+	// migrating again is a no-op
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countMigrations(); n != len(migrations) {
+		t.Fatalf("expected %d applied migrations after migrating again, got %d", len(migrations), n)
+	}
+
+	for _, table := range []string{"apps", "artifacts", "releases", "formations", "jobs", "deployments", "events"} {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+			t.Errorf("error querying table %s: %s", table, err)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `UPDATE apps SET name = $2 WHERE app_id = $1 AND etag = $10`
+	sqlite := &DB{Dialect: DialectSQLite}
+	if q := sqlite.rebind(query); q != `UPDATE apps SET name = ?2 WHERE app_id = ?1 AND etag = ?10` {
+		t.Errorf("unexpected SQLite query: %s", q)
+	}
+	postgres := &DB{Dialect: DialectPostgres}
+	if q := postgres.rebind(query); q != query {
+		t.Errorf("expected Postgres query to be unchanged, got %s", q)
+	}
+
+	// parameters are bound by number rather than by order of appearance
+	db := openTestDB(t)
+	defer db.Close()
+	var s string
+	if err := db.QueryRow(`SELECT $2 || $1`, "a", "b").Scan(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "ba" {
+		t.Fatalf(`expected "ba", got %q`, s)
+	}
+}
+
+func TestUpdateAppIfMatch(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	s := NewStore(db)
+
+	created := time.Now()
+	app := &ct.App{ID: "app1", Name: "ifmatch", CreatedAt: &created, UpdatedAt: &created}
+	if err := s.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	etag := app.ETag()
+
+	updated := created.Add(time.Second)
+	app.Meta = map[string]string{"team": "payments"}
+	app.UpdatedAt = &updated
+	if err := s.UpdateApp(app, `"stale"`); err != ct.ErrPreconditionFailed {
+		t.Fatalf("expected ErrPreconditionFailed for a stale etag, got %v", err)
+	}
+	if err := s.UpdateApp(app, etag); err != nil {
+		t.Fatalf("expected update with the current etag to succeed, got %v", err)
+	}
+	// the etag has changed, so the old one no longer matches
+	if err := s.UpdateApp(app, etag); err != ct.ErrPreconditionFailed {
+		t.Fatalf("expected ErrPreconditionFailed for the replaced etag, got %v", err)
+	}
+	if err := s.UpdateApp(app, ""); err != nil {
+		t.Fatalf("expected unconditional update to succeed, got %v", err)
+	}
+
+	got, err := s.GetApp(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ETag() != app.ETag() || got.Meta["team"] != "payments" {
+		t.Fatalf("unexpected app %+v", got)
+	}
+
+	missing := &ct.App{ID: "missing", Name: "missing"}
+	if err := s.UpdateApp(missing, etag); err != ct.ErrNotFound {
+		t.Fatalf("expected ErrNotFound for a missing app, got %v", err)
+	}
+}
+
+func TestPutFormationIfMatch(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	s := NewStore(db)
+
+	created := time.Now()
+	formation := &ct.Formation{
+		AppID:     "app1",
+		ReleaseID: "release1",
+		Processes: map[string]int{"web": 1},
+		CreatedAt: &created,
+		UpdatedAt: &created,
+	}
+	if err := s.PutFormation(formation, `"0"`); err != ct.ErrNotFound {
+		t.Fatalf("expected ErrNotFound for a conditional put of a new formation, got %v", err)
+	}
+	if err := s.PutFormation(formation, ""); err != nil {
+		t.Fatal(err)
+	}
+	etag := formation.ETag()
+
+	updated := created.Add(time.Second)
+	formation.Processes = map[string]int{"web": 2}
+	formation.UpdatedAt = &updated
+	if err := s.PutFormation(formation, `"stale"`); err != ct.ErrPreconditionFailed {
+		t.Fatalf("expected ErrPreconditionFailed for a stale etag, got %v", err)
+	}
+	if err := s.PutFormation(formation, etag); err != nil {
+		t.Fatalf("expected put with the current etag to succeed, got %v", err)
+	}
+
+	got, err := s.GetFormation("app1", "release1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Processes["web"] != 2 || got.ETag() != formation.ETag() {
+		t.Fatalf("unexpected formation %+v", got)
+	}
+}
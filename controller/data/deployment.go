@@ -0,0 +1,33 @@
+package data
+
+import (
+	"encoding/json"
+	ct "weo/controller/types"
+)
+
+func (s *Store) PutDeployment(deployment *ct.Deployment) error {
+	data, err := json.Marshal(deployment)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO deployments (deployment_id, app_id, data, created_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (deployment_id) DO UPDATE SET data = $3`,
+		deployment.ID, deployment.AppID, data, timestamp(deployment.CreatedAt),
+	)
+	return err
+}
+
+func (s *Store) GetDeployment(id string) (*ct.Deployment, error) {
+	deployment := &ct.Deployment{}
+	return deployment, s.getData(deployment, `SELECT data FROM deployments WHERE deployment_id = $1`, id)
+}
+
+func (s *Store) ListDeployments(appID string) ([]*ct.Deployment, error) {
+	list := []*ct.Deployment{}
+	return list, s.queryData(func(data []byte) error {
+		deployment := &ct.Deployment{}
+		list = append(list, deployment)
+		return json.Unmarshal(data, deployment)
+	}, `SELECT data FROM deployments WHERE app_id = $1 ORDER BY created_at DESC`, appID)
+}
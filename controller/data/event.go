@@ -0,0 +1,107 @@
+package data
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+	ct "weo/controller/types"
+)
+
+func (s *Store) AddEvent(event *ct.Event) error {
+	if event.CreatedAt == nil {
+		now := time.Now()
+		event.CreatedAt = &now
+	}
+	id, err := s.db.insertID(
+		`INSERT INTO events (app_id, object_type, object_id, data, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		"event_id",
+		event.AppID, string(event.ObjectType), event.ObjectID, string(event.Data), timestamp(event.CreatedAt),
+	)
+	if err != nil {
+		return err
+	}
+	event.ID = id
+	return nil
+}
+
+const eventColumns = `event_id, app_id, object_type, object_id, data, created_at`
+
+func (s *Store) GetEvent(id int64) (*ct.Event, error) {
+	event, err := scanEvent(s.db.QueryRow(`SELECT `+eventColumns+` FROM events WHERE event_id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ct.ErrNotFound
+	}
+	return event, err
+}
+
+func (s *Store) ListEvents(opts ct.ListEventsOptions) ([]*ct.Event, error) {
+	var conds []string
+	var args []interface{}
+	param := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if opts.AppID != "" {
+		conds = append(conds, "app_id = "+param(opts.AppID))
+	}
+	if opts.ObjectID != "" {
+		conds = append(conds, "object_id = "+param(opts.ObjectID))
+	}
+	if opts.BeforeID != nil {
+		conds = append(conds, "event_id < "+param(*opts.BeforeID))
+	}
+	if opts.SinceID != nil {
+		conds = append(conds, "event_id > "+param(*opts.SinceID))
+	}
+	if len(opts.ObjectTypes) > 0 {
+		types := make([]string, len(opts.ObjectTypes))
+		for i, typ := range opts.ObjectTypes {
+			types[i] = param(strings.ToLower(string(typ)))
+		}
+		conds = append(conds, "object_type IN ("+strings.Join(types, ", ")+")")
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM events`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY event_id DESC"
+	if opts.Count > 0 {
+		query += " LIMIT " + param(opts.Count)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*ct.Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, event)
+	}
+	return list, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEvent(s scanner) (*ct.Event, error) {
+	event := &ct.Event{}
+	var typ, data string
+	var createdAt time.Time
+	if err := s.Scan(&event.ID, &event.AppID, &typ, &event.ObjectID, &data, &createdAt); err != nil {
+		return nil, err
+	}
+	event.ObjectType = ct.EventType(typ)
+	if data != "" {
+		event.Data = []byte(data)
+	}
+	event.CreatedAt = &createdAt
+	return event, nil
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"encoding/json"
+	ct "weo/controller/types"
+)
+
+func (s *Store) PutFormation(formation *ct.Formation, ifMatch string) error {
+	data, err := json.Marshal(formation)
+	if err != nil {
+		return err
+	}
+	if ifMatch != "" {
+		return s.execIfMatch(
+			`SELECT COUNT(*) FROM formations WHERE app_id = $1 AND release_id = $2`,
+			[]interface{}{formation.AppID, formation.ReleaseID},
+			`UPDATE formations SET etag = $3, data = $4 WHERE app_id = $1 AND release_id = $2 AND etag = $5`,
+			formation.AppID, formation.ReleaseID, formation.ETag(), data, ifMatch,
+		)
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO formations (app_id, release_id, etag, data, created_at) VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (app_id, release_id) DO UPDATE SET etag = $3, data = $4`,
+		formation.AppID, formation.ReleaseID, formation.ETag(), data, timestamp(formation.CreatedAt),
+	)
+	return err
+}
+
+func (s *Store) GetFormation(appID, releaseID string) (*ct.Formation, error) {
+	formation := &ct.Formation{}
+	return formation, s.getData(formation, `SELECT data FROM formations WHERE app_id = $1 AND release_id = $2`, appID, releaseID)
+}
+
+func (s *Store) ListFormations(appID string) ([]*ct.Formation, error) {
+	list := []*ct.Formation{}
+	fn := func(data []byte) error {
+		formation := &ct.Formation{}
+		list = append(list, formation)
+		return json.Unmarshal(data, formation)
+	}
+	if appID == "" {
+		return list, s.queryData(fn, `SELECT data FROM formations ORDER BY created_at DESC`)
+	}
+	return list, s.queryData(fn, `SELECT data FROM formations WHERE app_id = $1 ORDER BY created_at DESC`, appID)
+}
+
+func (s *Store) DeleteFormation(appID, releaseID string) error {
+	return s.execOne(`DELETE FROM formations WHERE app_id = $1 AND release_id = $2`, appID, releaseID)
+}
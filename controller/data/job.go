@@ -0,0 +1,37 @@
+package data
+
+import (
+	"encoding/json"
+	ct "weo/controller/types"
+)
+
+func (s *Store) PutJob(job *ct.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO jobs (job_uuid, app_id, data, created_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (job_uuid) DO UPDATE SET app_id = $2, data = $3`,
+		job.UUID, job.AppID, data, timestamp(job.CreatedAt),
+	)
+	return err
+}
+
+func (s *Store) GetJob(uuid string) (*ct.Job, error) {
+	job := &ct.Job{}
+	return job, s.getData(job, `SELECT data FROM jobs WHERE job_uuid = $1`, uuid)
+}
+
+func (s *Store) ListJobs(appID string) ([]*ct.Job, error) {
+	list := []*ct.Job{}
+	fn := func(data []byte) error {
+		job := &ct.Job{}
+		list = append(list, job)
+		return json.Unmarshal(data, job)
+	}
+	if appID == "" {
+		return list, s.queryData(fn, `SELECT data FROM jobs ORDER BY created_at DESC`)
+	}
+	return list, s.queryData(fn, `SELECT data FROM jobs WHERE app_id = $1 ORDER BY created_at DESC`, appID)
+}
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"encoding/json"
+	ct "weo/controller/types"
+)
+
+func (s *Store) CreateProvider(provider *ct.Provider) error {
+	data, err := json.Marshal(provider)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO providers (provider_id, name, data, created_at) VALUES ($1, $2, $3, $4)`,
+		provider.ID, provider.Name, data, timestamp(provider.CreatedAt),
+	)
+	if isUniqueViolation(err) {
+		return ct.ValidationError{Field: "name", Message: "is already taken"}
+	}
+	return err
+}
+
+func (s *Store) GetProvider(id string) (*ct.Provider, error) {
+	provider := &ct.Provider{}
+	return provider, s.getData(provider, `SELECT data FROM providers WHERE provider_id = $1 OR name = $1`, id)
+}
+
+func (s *Store) ListProviders() ([]*ct.Provider, error) {
+	list := []*ct.Provider{}
+	return list, s.queryData(func(data []byte) error {
+		provider := &ct.Provider{}
+		list = append(list, provider)
+		return json.Unmarshal(data, provider)
+	}, `SELECT data FROM providers ORDER BY created_at DESC`)
+}
+
+func (s *Store) PutResource(resource *ct.Resource) error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO resources (resource_id, data, created_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (resource_id) DO UPDATE SET data = $2`,
+		resource.ID, data, timestamp(resource.CreatedAt),
+	)
+	return err
+}
+
+func (s *Store) GetResource(id string) (*ct.Resource, error) {
+	resource := &ct.Resource{}
+	return resource, s.getData(resource, `SELECT data FROM resources WHERE resource_id = $1`, id)
+}
+
+func (s *Store) ListResources() ([]*ct.Resource, error) {
+	list := []*ct.Resource{}
+	return list, s.queryData(func(data []byte) error {
+		resource := &ct.Resource{}
+		list = append(list, resource)
+		return json.Unmarshal(data, resource)
+	}, `SELECT data FROM resources ORDER BY created_at DESC`)
+}
+
+func (s *Store) DeleteResource(id string) error {
+	return s.execOne(`DELETE FROM resources WHERE resource_id = $1`, id)
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"encoding/json"
+	ct "weo/controller/types"
+)
+
+func (s *Store) CreateArtifact(artifact *ct.Artifact) error {
+	data, err := json.Marshal(artifact)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO artifacts (artifact_id, data, created_at) VALUES ($1, $2, $3)`,
+		artifact.ID, data, timestamp(artifact.CreatedAt),
+	)
+	if isUniqueViolation(err) {
+		return ct.ValidationError{Field: "id", Message: "is already taken"}
+	}
+	return err
+}
+
+func (s *Store) GetArtifact(id string) (*ct.Artifact, error) {
+	artifact := &ct.Artifact{}
+	return artifact, s.getData(artifact, `SELECT data FROM artifacts WHERE artifact_id = $1`, id)
+}
+
+func (s *Store) ListArtifacts() ([]*ct.Artifact, error) {
+	list := []*ct.Artifact{}
+	return list, s.queryData(func(data []byte) error {
+		artifact := &ct.Artifact{}
+		list = append(list, artifact)
+		return json.Unmarshal(data, artifact)
+	}, `SELECT data FROM artifacts ORDER BY created_at DESC`)
+}
+
+func (s *Store) CreateRelease(release *ct.Release) error {
+	data, err := json.Marshal(release)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO releases (release_id, app_id, data, created_at) VALUES ($1, $2, $3, $4)`,
+		release.ID, release.AppID, data, timestamp(release.CreatedAt),
+	)
+	if isUniqueViolation(err) {
+		return ct.ValidationError{Field: "id", Message: "is already taken"}
+	}
+	return err
+}
+
+func (s *Store) GetRelease(id string) (*ct.Release, error) {
+	release := &ct.Release{}
+	return release, s.getData(release, `SELECT data FROM releases WHERE release_id = $1`, id)
+}
+
+func (s *Store) ListReleases(appID string) ([]*ct.Release, error) {
+	list := []*ct.Release{}
+	fn := func(data []byte) error {
+		release := &ct.Release{}
+		list = append(list, release)
+		return json.Unmarshal(data, release)
+	}
+	if appID == "" {
+		return list, s.queryData(fn, `SELECT data FROM releases ORDER BY created_at DESC`)
+	}
+	return list, s.queryData(fn, `SELECT data FROM releases WHERE app_id = $1 ORDER BY created_at DESC`, appID)
+}
+
+func (s *Store) DeleteRelease(id string) error {
+	return s.execOne(`DELETE FROM releases WHERE release_id = $1`, id)
+}
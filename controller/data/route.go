@@ -0,0 +1,41 @@
+package data
+
+import (
+	"encoding/json"
+	router "github.com/flynn/flynn/router/types"
+	ct "weo/controller/types"
+)
+
+func (s *Store) PutRoute(route *router.Route) error {
+	data, err := json.Marshal(route)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO routes (route_type, route_id, domain, path, port, data, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (route_type, route_id) DO UPDATE SET domain = $3, path = $4, port = $5, data = $6`,
+		route.Type, route.ID, route.Domain, route.Path, route.Port, data, timestamp(&route.CreatedAt),
+	)
+	if isUniqueViolation(err) {
+		return ct.ValidationError{Message: "conflicting route already exists"}
+	}
+	return err
+}
+
+func (s *Store) GetRoute(typ, id string) (*router.Route, error) {
+	route := &router.Route{}
+	return route, s.getData(route, `SELECT data FROM routes WHERE route_type = $1 AND route_id = $2`, typ, id)
+}
+
+func (s *Store) ListRoutes() ([]*router.Route, error) {
+	list := []*router.Route{}
+	return list, s.queryData(func(data []byte) error {
+		route := &router.Route{}
+		list = append(list, route)
+		return json.Unmarshal(data, route)
+	}, `SELECT data FROM routes ORDER BY created_at DESC`)
+}
+
+func (s *Store) DeleteRoute(typ, id string) error {
+	return s.execOne(`DELETE FROM routes WHERE route_type = $1 AND route_id = $2`, typ, id)
+}
@@ -0,0 +1,171 @@
+package data
+
+import (
+	"strings"
+)
+
+// migration is a set of schema changes applied in a single transaction.
+// Statements may use {{serial}} for an auto incrementing primary key column
+// and {{timestamp}} for a timestamp column, which are replaced with the
+// equivalent types of the database dialect.
+type migration struct {
+	ID    int
+	Stmts []string
+}
+
+var migrations = []migration{
+	{1, []string{
+		`CREATE TABLE apps (
+			app_id text PRIMARY KEY,
+			name text NOT NULL,
+			etag text NOT NULL,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+		`CREATE UNIQUE INDEX apps_name_key ON apps (name)`,
+
+		`CREATE TABLE artifacts (
+			artifact_id text PRIMARY KEY,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+
+		`CREATE TABLE releases (
+			release_id text PRIMARY KEY,
+			app_id text NOT NULL,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+		`CREATE INDEX releases_app_id_idx ON releases (app_id)`,
+
+		`CREATE TABLE formations (
+			app_id text NOT NULL,
+			release_id text NOT NULL,
+			etag text NOT NULL,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL,
+			PRIMARY KEY (app_id, release_id)
+		)`,
+
+		`CREATE TABLE jobs (
+			job_uuid text PRIMARY KEY,
+			app_id text NOT NULL,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+		`CREATE INDEX jobs_app_id_idx ON jobs (app_id)`,
+
+		`CREATE TABLE deployments (
+			deployment_id text PRIMARY KEY,
+			app_id text NOT NULL,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+		`CREATE INDEX deployments_app_id_idx ON deployments (app_id)`,
+
+		`CREATE TABLE providers (
+			provider_id text PRIMARY KEY,
+			name text NOT NULL,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+		`CREATE UNIQUE INDEX providers_name_key ON providers (name)`,
+
+		`CREATE TABLE resources (
+			resource_id text PRIMARY KEY,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+
+		`CREATE TABLE routes (
+			route_type text NOT NULL,
+			route_id text NOT NULL,
+			domain text NOT NULL,
+			path text NOT NULL,
+			port integer NOT NULL,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL,
+			PRIMARY KEY (route_type, route_id)
+		)`,
+		`CREATE UNIQUE INDEX routes_http_key ON routes (domain, path) WHERE route_type = 'http'`,
+		`CREATE UNIQUE INDEX routes_tcp_key ON routes (port) WHERE route_type = 'tcp'`,
+
+		`CREATE TABLE volumes (
+			volume_id text PRIMARY KEY,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+
+		`CREATE TABLE sinks (
+			sink_id text PRIMARY KEY,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+
+		`CREATE TABLE events (
+			event_id {{serial}},
+			app_id text NOT NULL,
+			object_type text NOT NULL,
+			object_id text NOT NULL,
+			data text NOT NULL,
+			created_at {{timestamp}} NOT NULL
+		)`,
+		`CREATE INDEX events_app_id_idx ON events (app_id)`,
+	}},
+}
+
+var dialectTypes = map[Dialect]*strings.Replacer{
+	DialectPostgres: strings.NewReplacer(
+		"{{serial}}", "bigserial PRIMARY KEY",
+		"{{timestamp}}", "timestamptz",
+	),
+	DialectSQLite: strings.NewReplacer(
+		"{{serial}}", "integer PRIMARY KEY AUTOINCREMENT",
+		"{{timestamp}}", "datetime",
+	),
+}
+
+// Migrate applies any migrations which have not yet been applied to the
+// database.
+func (db *DB) Migrate() error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (id integer PRIMARY KEY)`); err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if err := db.migrate(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) migrate(m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// stop concurrent controllers from running the same migration
+	if db.Dialect == DialectPostgres {
+		if _, err := tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+	}
+	var n int
+	if err := tx.QueryRow(db.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE id = $1`), m.ID).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	for _, stmt := range m.Stmts {
+		if _, err := tx.Exec(dialectTypes[db.Dialect].Replace(stmt)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(db.rebind(`INSERT INTO schema_migrations (id) VALUES ($1)`), m.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,39 @@
+package data
+
+import (
+	"encoding/json"
+	ct "weo/controller/types"
+)
+
+func (s *Store) CreateSink(sink *ct.Sink) error {
+	data, err := json.Marshal(sink)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO sinks (sink_id, data, created_at) VALUES ($1, $2, $3)`,
+		sink.ID, data, timestamp(sink.CreatedAt),
+	)
+	if isUniqueViolation(err) {
+		return ct.ValidationError{Field: "id", Message: "is already taken"}
+	}
+	return err
+}
+
+func (s *Store) GetSink(id string) (*ct.Sink, error) {
+	sink := &ct.Sink{}
+	return sink, s.getData(sink, `SELECT data FROM sinks WHERE sink_id = $1`, id)
+}
+
+func (s *Store) ListSinks() ([]*ct.Sink, error) {
+	list := []*ct.Sink{}
+	return list, s.queryData(func(data []byte) error {
+		sink := &ct.Sink{}
+		list = append(list, sink)
+		return json.Unmarshal(data, sink)
+	}, `SELECT data FROM sinks ORDER BY created_at DESC`)
+}
+
+func (s *Store) DeleteSink(id string) error {
+	return s.execOne(`DELETE FROM sinks WHERE sink_id = $1`, id)
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+	ct "weo/controller/types"
+)
+
+// Store is a controller store backed by a SQL database.
+//
+// Each object is stored as JSON in the data column of its table, alongside
+// the columns it is looked up, filtered or ordered by.
+type Store struct {
+	db *DB
+}
+
+func NewStore(db *DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Ping() error {
+	return s.db.Ping()
+}
+
+// getData scans the single data column selected by query into v, returning
+// ct.ErrNotFound if there are no rows.
+func (s *Store) getData(v interface{}, query string, args ...interface{}) error {
+	var data []byte
+	if err := s.db.QueryRow(query, args...).Scan(&data); err == sql.ErrNoRows {
+		return ct.ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// queryData calls fn with the data column of each row selected by query.
+func (s *Store) queryData(fn func(data []byte) error, query string, args ...interface{}) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// execOne runs a statement which is expected to affect a single row,
+// returning ct.ErrNotFound if no rows were affected.
+func (s *Store) execOne(query string, args ...interface{}) error {
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ct.ErrNotFound
+	}
+	return nil
+}
+
+// execIfMatch runs a conditional update which matches no rows if either the
+// object does not exist or its etag is not ifMatch, using existsQuery to
+// tell the two apart.
+func (s *Store) execIfMatch(existsQuery string, existsArgs []interface{}, query string, args ...interface{}) error {
+	err := s.execOne(query, args...)
+	if err != ct.ErrNotFound {
+		return err
+	}
+	var n int
+	if err := s.db.QueryRow(existsQuery, existsArgs...).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ct.ErrPreconditionFailed
+	}
+	return ct.ErrNotFound
+}
+
+// timestamp returns the UTC time used to order an object created at t.
+func timestamp(t *time.Time) time.Time {
+	if t == nil {
+		return time.Now().UTC()
+	}
+	return t.UTC()
+}
@@ -0,0 +1,33 @@
+package data
+
+import (
+	"encoding/json"
+	ct "weo/controller/types"
+)
+
+func (s *Store) PutVolume(volume *ct.Volume) error {
+	data, err := json.Marshal(volume)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO volumes (volume_id, data, created_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (volume_id) DO UPDATE SET data = $2`,
+		volume.ID, data, timestamp(volume.CreatedAt),
+	)
+	return err
+}
+
+func (s *Store) GetVolume(id string) (*ct.Volume, error) {
+	volume := &ct.Volume{}
+	return volume, s.getData(volume, `SELECT data FROM volumes WHERE volume_id = $1`, id)
+}
+
+func (s *Store) ListVolumes() ([]*ct.Volume, error) {
+	list := []*ct.Volume{}
+	return list, s.queryData(func(data []byte) error {
+		volume := &ct.Volume{}
+		list = append(list, volume)
+		return json.Unmarshal(data, volume)
+	}, `SELECT data FROM volumes ORDER BY created_at DESC`)
+}
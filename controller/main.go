@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"weo/controller/data"
+	"weo/controller/server"
+	"weo/pkg/shutdown"
+)
+
+func main() {
+	defer shutdown.Exit()
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3000"
+	}
+	addr := ":" + port
+
+	config := server.Config{
+		Store:              server.NewMemoryStore(),
+		DefaultRouteDomain: os.Getenv("DEFAULT_ROUTE_DOMAIN"),
+	}
+	if url := os.Getenv("DATABASE_URL"); url != "" {
+		db, err := data.Open(url)
+		if err != nil {
+			shutdown.Fatal(err)
+		}
+		shutdown.BeforeExit(func() { db.Close() })
+		config.Store = data.NewStore(db)
+	}
+	if keys := os.Getenv("AUTH_KEY"); keys != "" {
+		config.Keys = strings.Split(keys, ",")
+	}
+	if path := os.Getenv("CA_CERT"); path != "" {
+		cert, err := ioutil.ReadFile(path)
+		if err != nil {
+			shutdown.Fatalf("error reading CA_CERT: %s", err)
+		}
+		config.CACert = cert
+	}
+
+	log.Println("controller listening on", addr)
+	shutdown.Fatal(http.ListenAndServe(addr, server.NewHandler(config)))
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	router "github.com/flynn/flynn/router/types"
+	"net/http"
+	"strings"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/random"
+)
+
+const defaultStrategy = ct.DeployStrategyAllAtOnce
+
+func (c *controllerAPI) CreateApp(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var app ct.App
+	if err := httphelper.DecodeJSON(req, &app); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if app.Name == "" {
+		app.Name = "app-" + random.String(8)
+	}
+	if err := app.Validate(); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if app.ID == "" {
+		app.ID = random.UUID()
+	}
+	if app.Meta == nil {
+		app.Meta = make(map[string]string)
+	}
+	if app.Strategy == "" {
+		app.Strategy = defaultStrategy
+	}
+	if app.DeployTimeout == 0 {
+		app.DeployTimeout = ct.DefaultDeployTimeout
+	}
+	now := time.Now()
+	app.CreatedAt = &now
+	app.UpdatedAt = &now
+
+	if err := c.store.CreateApp(&app); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if c.config.DefaultRouteDomain != "" {
+		route := &router.Route{
+			Type:    "http",
+			Domain:  fmt.Sprintf("%s.%s", app.Name, c.config.DefaultRouteDomain),
+			Service: app.Name + "-web",
+		}
+		if err := c.createRoute(&app, route); err != nil {
+			respondWithError(w, err)
+			return
+		}
+	}
+	c.emit(app.ID, ct.EventTypeApp, app.ID, &app)
+	w.Header().Set("ETag", app.ETag())
+	httphelper.JSON(w, 200, &app)
+}
+
+func (c *controllerAPI) ListApps(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListApps()
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) GetApp(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	w.Header().Set("ETag", app.ETag())
+	httphelper.JSON(w, 200, app)
+}
+
+// UpdateApp updates the strategy, deploy timeout and any non-empty meta of
+// the app. The update is conditional on the If-Match header when present.
+func (c *controllerAPI) UpdateApp(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	c.updateApp(ctx, w, req, false)
+}
+
+// UpdateAppMeta is like UpdateApp but replaces the meta even when empty.
+func (c *controllerAPI) UpdateAppMeta(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	c.updateApp(ctx, w, req, true)
+}
+
+func (c *controllerAPI) updateApp(ctx context.Context, w http.ResponseWriter, req *http.Request, setMeta bool) {
+	app := c.getApp(ctx)
+
+	var data ct.App
+	if err := httphelper.DecodeJSON(req, &data); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if data.Meta != nil || setMeta {
+		app.Meta = data.Meta
+		if app.Meta == nil {
+			app.Meta = make(map[string]string)
+		}
+	}
+	if data.Strategy != "" {
+		if err := ct.ValidateDeployStrategy(data.Strategy); err != nil {
+			respondWithError(w, err)
+			return
+		}
+		app.Strategy = data.Strategy
+	}
+	if data.DeployTimeout < 0 {
+		respondWithError(w, ct.ValidationError{Field: "deploy_timeout", Message: "must be positive"})
+		return
+	} else if data.DeployTimeout > 0 {
+		app.DeployTimeout = data.DeployTimeout
+	}
+	now := time.Now()
+	app.UpdatedAt = &now
+
+	if err := c.store.UpdateApp(app, ifMatch(req)); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit(app.ID, ct.EventTypeApp, app.ID, app)
+	w.Header().Set("ETag", app.ETag())
+	httphelper.JSON(w, 200, app)
+}
+
+func (c *controllerAPI) DeleteApp(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	go func() {
+		deletion, err := c.deleteApp(app)
+		event := &ct.AppDeletionEvent{AppDeletion: deletion}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		c.emit(app.ID, ct.EventTypeAppDeletion, app.ID, event)
+	}()
+	w.WriteHeader(200)
+}
+
+func (c *controllerAPI) deleteApp(app *ct.App) (*ct.AppDeletion, error) {
+	deletion := &ct.AppDeletion{AppID: app.ID}
+
+	// stop all jobs by scaling every formation down
+	formations, err := c.store.ListFormations(app.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range formations {
+		c.scheduler.Reconcile(context.Background(), app, f.ReleaseID, nil, nil)
+		if err := c.store.DeleteFormation(app.ID, f.ReleaseID); err != nil {
+			return nil, err
+		}
+	}
+
+	routes, err := c.appRoutes(app.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		if err := c.store.DeleteRoute(route.Type, route.ID); err != nil {
+			return nil, err
+		}
+		deletion.DeletedRoutes = append(deletion.DeletedRoutes, route)
+	}
+
+	resources, err := c.appResources(app.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range resources {
+		apps := removeString(resource.Apps, app.ID)
+		if len(apps) > 0 {
+			resource.Apps = apps
+			if err := c.store.PutResource(resource); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := c.deprovision(resource); err != nil {
+			return nil, err
+		}
+		deletion.DeletedResources = append(deletion.DeletedResources, resource)
+	}
+
+	releases, err := c.store.ListReleases(app.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, release := range releases {
+		if err := c.store.DeleteRelease(release.ID); err != nil {
+			return nil, err
+		}
+		deletion.DeletedReleases = append(deletion.DeletedReleases, release)
+	}
+
+	return deletion, c.store.DeleteApp(app.ID)
+}
+
+// ScheduleAppGarbageCollection deletes releases of the app which are neither
+// current nor referenced by a scaled formation.
+func (c *controllerAPI) ScheduleAppGarbageCollection(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	gc := &ct.AppGarbageCollection{AppID: app.ID}
+
+	releases, err := c.store.ListReleases(app.ID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	for _, release := range releases {
+		if release.ID == app.ReleaseID {
+			continue
+		}
+		if f, err := c.store.GetFormation(app.ID, release.ID); err == nil && processCount(f.Processes) > 0 {
+			continue
+		}
+		if err := c.store.DeleteRelease(release.ID); err != nil {
+			respondWithError(w, err)
+			return
+		}
+		c.store.DeleteFormation(app.ID, release.ID)
+		gc.DeletedReleases = append(gc.DeletedReleases, release.ID)
+	}
+	c.emit(app.ID, ct.EventTypeAppGarbageCollection, app.ID, &ct.AppGarbageCollectionEvent{AppGarbageCollection: gc})
+	w.WriteHeader(200)
+}
+
+func (c *controllerAPI) MigrateDomain(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var dm ct.DomainMigration
+	if err := httphelper.DecodeJSON(req, &dm); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if dm.Domain == "" {
+		respondWithError(w, ct.ValidationError{Field: "domain", Message: "must not be blank"})
+		return
+	}
+	if dm.OldDomain == "" {
+		respondWithError(w, ct.ValidationError{Field: "old_domain", Message: "must not be blank"})
+		return
+	}
+	dm.ID = random.UUID()
+	now := time.Now()
+	dm.CreatedAt = &now
+
+	routes, err := c.store.ListRoutes()
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	suffix := "." + dm.OldDomain
+	for _, route := range routes {
+		if route.Type != "http" || !strings.HasSuffix(route.Domain, suffix) {
+			continue
+		}
+		route.Domain = strings.TrimSuffix(route.Domain, suffix) + "." + dm.Domain
+		route.UpdatedAt = now
+		if err := c.store.PutRoute(route); err != nil {
+			respondWithError(w, err)
+			return
+		}
+	}
+	if c.config.DefaultRouteDomain == dm.OldDomain {
+		c.config.DefaultRouteDomain = dm.Domain
+	}
+
+	finished := time.Now()
+	dm.FinishedAt = &finished
+	c.emit("", ct.EventTypeDomainMigration, dm.ID, &ct.DomainMigrationEvent{DomainMigration: &dm})
+	httphelper.JSON(w, 200, &dm)
+}
+
+func removeString(list []string, s string) []string {
+	res := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func processCount(processes map[string]int) int {
+	var n int
+	for _, count := range processes {
+		n += count
+	}
+	return n
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	router "github.com/flynn/flynn/router/types"
+	"net/http"
+	"strings"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/random"
+)
+
+// backupData is the content of a controller backup
+type backupData struct {
+	Apps        []*ct.App        `json:"apps"`
+	Artifacts   []*ct.Artifact   `json:"artifacts"`
+	Releases    []*ct.Release    `json:"releases"`
+	Formations  []*ct.Formation  `json:"formations"`
+	Providers   []*ct.Provider   `json:"providers"`
+	Resources   []*ct.Resource   `json:"resources"`
+	Routes      []*router.Route  `json:"routes"`
+	Volumes     []*ct.Volume     `json:"volumes"`
+	Sinks       []*ct.Sink       `json:"sinks"`
+	Deployments []*ct.Deployment `json:"deployments"`
+}
+
+// GetBackup returns the metadata of the last backup when requested as JSON,
+// and otherwise returns a new backup of the controller data.
+func (c *controllerAPI) GetBackup(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		c.backupMtx.Lock()
+		b := c.lastBackup
+		c.backupMtx.Unlock()
+		if b == nil {
+			respondWithError(w, ErrNotFound)
+			return
+		}
+		httphelper.JSON(w, 200, b)
+		return
+	}
+
+	now := time.Now()
+	b := &ct.ClusterBackup{
+		ID:        random.UUID(),
+		Status:    ct.ClusterBackupStatusRunning,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	c.emit("", ct.EventTypeClusterBackup, b.ID, b)
+
+	data, err := c.backup()
+	if err == nil {
+		var raw []byte
+		if raw, err = json.Marshal(data); err == nil {
+			sum := sha512.Sum512(raw)
+			b.SHA512 = hex.EncodeToString(sum[:])
+			b.Size = int64(len(raw))
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", "attachment; filename=backup.json")
+			w.WriteHeader(200)
+			w.Write(raw)
+		}
+	}
+
+	completed := time.Now()
+	b.UpdatedAt = &completed
+	if err != nil {
+		b.Status = ct.ClusterBackupStatusError
+		b.Error = err.Error()
+		respondWithError(w, err)
+	} else {
+		b.Status = ct.ClusterBackupStatusComplete
+		b.CompletedAt = &completed
+	}
+	c.backupMtx.Lock()
+	c.lastBackup = b
+	c.backupMtx.Unlock()
+	c.emit("", ct.EventTypeClusterBackup, b.ID, b)
+}
+
+func (c *controllerAPI) backup() (*backupData, error) {
+	var data backupData
+	var err error
+	if data.Apps, err = c.store.ListApps(); err != nil {
+		return nil, err
+	}
+	if data.Artifacts, err = c.store.ListArtifacts(); err != nil {
+		return nil, err
+	}
+	if data.Releases, err = c.store.ListReleases(""); err != nil {
+		return nil, err
+	}
+	if data.Formations, err = c.store.ListFormations(""); err != nil {
+		return nil, err
+	}
+	if data.Providers, err = c.store.ListProviders(); err != nil {
+		return nil, err
+	}
+	if data.Resources, err = c.store.ListResources(); err != nil {
+		return nil, err
+	}
+	if data.Routes, err = c.store.ListRoutes(); err != nil {
+		return nil, err
+	}
+	if data.Volumes, err = c.store.ListVolumes(); err != nil {
+		return nil, err
+	}
+	if data.Sinks, err = c.store.ListSinks(); err != nil {
+		return nil, err
+	}
+	for _, app := range data.Apps {
+		deployments, err := c.store.ListDeployments(app.ID)
+		if err != nil {
+			return nil, err
+		}
+		data.Deployments = append(data.Deployments, deployments...)
+	}
+	return &data, nil
+}
@@ -0,0 +1,389 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/random"
+)
+
+func (c *controllerAPI) ListDeployments(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListDeployments(c.getApp(ctx).ID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) GetDeployment(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	deployment, err := c.store.GetDeployment(params(ctx).ByName("deployments_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, deployment)
+}
+
+// CreateDeployment deploys the given release to the app. If the app has no
+// running processes the release is set immediately and the returned
+// deployment is already finished, otherwise the deployment runs in the
+// background and its progress is reported via deployment events. The strategy
+// and deploy timeout default to those of the app. Only one deployment of an
+// app may run at a time.
+func (c *controllerAPI) CreateDeployment(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	var data deployRequest
+	if err := httphelper.DecodeJSON(req, &data); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := data.Validate(); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	release, err := c.store.GetRelease(data.ID)
+	if err != nil {
+		if err == ErrNotFound {
+			err = ct.ValidationError{Field: "id", Message: "release does not exist"}
+		}
+		respondWithError(w, err)
+		return
+	}
+	if release.AppID != "" && release.AppID != app.ID {
+		respondWithError(w, ct.ValidationError{Field: "id", Message: "release does not belong to app"})
+		return
+	}
+	if app.ReleaseID == release.ID {
+		respondWithError(w, ct.ValidationError{Field: "id", Message: "release is already deployed"})
+		return
+	}
+
+	c.deployMtx.Lock()
+	defer c.deployMtx.Unlock()
+	deployments, err := c.store.ListDeployments(app.ID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	for _, d := range deployments {
+		if d.FinishedAt == nil {
+			httphelper.ConflictError(w, fmt.Sprintf("deployment %s of app %s is still %s", d.ID, app.ID, d.Status))
+			return
+		}
+	}
+
+	now := time.Now()
+	deployment := &ct.Deployment{
+		ID:              random.UUID(),
+		AppID:           app.ID,
+		OldReleaseID:    app.ReleaseID,
+		NewReleaseID:    release.ID,
+		Strategy:        app.Strategy,
+		DeployTimeout:   app.DeployTimeout,
+		DeployBatchSize: app.DeployBatchSize(),
+		CreatedAt:       &now,
+	}
+	if data.Strategy != "" {
+		deployment.Strategy = data.Strategy
+	} else if deployment.Strategy == "" {
+		deployment.Strategy = defaultStrategy
+	}
+	if data.DeployTimeout > 0 {
+		deployment.DeployTimeout = data.DeployTimeout
+	} else if deployment.DeployTimeout == 0 {
+		deployment.DeployTimeout = ct.DefaultDeployTimeout
+	}
+
+	var oldFormation *ct.Formation
+	if app.ReleaseID != "" {
+		oldFormation, err = c.store.GetFormation(app.ID, app.ReleaseID)
+		if err != nil && err != ErrNotFound {
+			respondWithError(w, err)
+			return
+		}
+	}
+	if oldFormation == nil || processCount(oldFormation.Processes) == 0 {
+		// initial deploy, so there is nothing to scale
+		formation := &ct.Formation{AppID: app.ID, ReleaseID: release.ID}
+		if oldFormation != nil {
+			formation.Processes = oldFormation.Processes
+			formation.Tags = oldFormation.Tags
+		}
+		if err := c.putFormation(app, formation, ""); err != nil {
+			respondWithError(w, err)
+			return
+		}
+		if err := c.setAppRelease(app, release); err != nil {
+			respondWithError(w, err)
+			return
+		}
+		deployment.Status = "complete"
+		deployment.Processes = formation.Processes
+		deployment.Tags = formation.Tags
+		deployment.FinishedAt = &now
+		if err := c.store.PutDeployment(deployment); err != nil {
+			respondWithError(w, err)
+			return
+		}
+		httphelper.JSON(w, 200, deployment)
+		return
+	}
+
+	deployment.Status = "pending"
+	deployment.Processes = oldFormation.Processes
+	deployment.Tags = oldFormation.Tags
+	if err := c.store.PutDeployment(deployment); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.emitDeploymentEvent(deployment, &ct.DeploymentEvent{Status: "pending"}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, deployment)
+
+	go func() {
+		event := &ct.DeploymentEvent{Status: "complete"}
+		if err := c.runDeployment(app, release, deployment); err != nil {
+			event.Status = "failed"
+			event.Error = err.Error()
+		}
+		finished := time.Now()
+		deployment.Status = event.Status
+		deployment.FinishedAt = &finished
+		if err := c.store.PutDeployment(deployment); err != nil {
+			log.Printf("error saving deployment %s: %s", deployment.ID, err)
+		}
+		if err := c.emitDeploymentEvent(deployment, event); err != nil {
+			log.Printf("error emitting %s event for deployment %s: %s", event.Status, deployment.ID, err)
+		}
+	}()
+}
+
+// runDeployment replaces the jobs of the old release with jobs of the new
+// release using the deployment strategy, then sets the app release.
+func (c *controllerAPI) runDeployment(app *ct.App, release *ct.Release, deployment *ct.Deployment) error {
+	deployment.Status = "running"
+	if err := c.store.PutDeployment(deployment); err != nil {
+		return err
+	}
+	if err := c.emitDeploymentEvent(deployment, &ct.DeploymentEvent{Status: "running"}); err != nil {
+		return err
+	}
+
+	for typ := range deployment.Processes {
+		if _, ok := release.Processes[typ]; !ok {
+			return fmt.Errorf("new release is missing process type %q", typ)
+		}
+	}
+
+	d := &deployer{
+		api:        c,
+		app:        app,
+		release:    release,
+		deployment: deployment,
+		timeout:    time.Duration(deployment.DeployTimeout) * time.Second,
+	}
+	switch deployment.Strategy {
+	case ct.DeployStrategyOneByOne:
+		return d.deployOneByOne()
+	case ct.DeployStrategyBlueGreen:
+		return d.deployBlueGreen()
+	default:
+		return d.deployAllAtOnce()
+	}
+}
+
+// deployRequest is the body of a create deployment request
+type deployRequest struct {
+	ID string `json:"id"`
+	ct.DeployOptions
+}
+
+// deployer performs a single deployment
+type deployer struct {
+	api        *controllerAPI
+	app        *ct.App
+	release    *ct.Release
+	deployment *ct.Deployment
+	timeout    time.Duration
+}
+
+// deployAllAtOnce scales the new release up to the old release's process
+// counts, then scales the old release down and finally sets the app release.
+func (d *deployer) deployAllAtOnce() error {
+	if err := d.scale(d.release.ID, d.deployment.Processes); err != nil {
+		return err
+	}
+	if err := d.scale(d.deployment.OldReleaseID, nil); err != nil {
+		return err
+	}
+	return d.api.setAppRelease(d.app, d.release)
+}
+
+// deployOneByOne starts the jobs of the new release one at a time, stopping
+// a job of the old release after each one starts, and finally sets the app
+// release.
+func (d *deployer) deployOneByOne() error {
+	newProcs := make(map[string]int, len(d.deployment.Processes))
+	oldProcs := make(map[string]int, len(d.deployment.Processes))
+	types := make([]string, 0, len(d.deployment.Processes))
+	for typ, count := range d.deployment.Processes {
+		oldProcs[typ] = count
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+
+	for _, typ := range types {
+		for i := 0; i < d.deployment.Processes[typ]; i++ {
+			newProcs[typ]++
+			if err := d.scale(d.release.ID, newProcs); err != nil {
+				return err
+			}
+			oldProcs[typ]--
+			if err := d.scale(d.deployment.OldReleaseID, oldProcs); err != nil {
+				return err
+			}
+		}
+	}
+	return d.api.setAppRelease(d.app, d.release)
+}
+
+// deployBlueGreen starts all the jobs of the new release alongside the old
+// ones, switches the app routes and release over to the new release and only
+// then stops the jobs of the old release.
+func (d *deployer) deployBlueGreen() error {
+	if err := d.scale(d.release.ID, d.deployment.Processes); err != nil {
+		return err
+	}
+	if err := d.switchRoutes(); err != nil {
+		return err
+	}
+	return d.scale(d.deployment.OldReleaseID, nil)
+}
+
+// switchRoutes sets the app release so traffic is sent to the new release,
+// and points each app route served by a process of the old release at the
+// service of the same process type in the new release. A "routes-switched"
+// deployment event is emitted once done.
+func (d *deployer) switchRoutes() error {
+	oldRelease, err := d.api.store.GetRelease(d.deployment.OldReleaseID)
+	if err != nil {
+		return err
+	}
+	if err := d.api.setAppRelease(d.app, d.release); err != nil {
+		return err
+	}
+	services := releaseServices(d.app, oldRelease, d.release)
+	routes, err := d.api.appRoutes(d.app.ID)
+	if err != nil {
+		return err
+	}
+	for _, route := range routes {
+		service, ok := services[route.Service]
+		if !ok {
+			continue
+		}
+		route.Service = service
+		route.UpdatedAt = time.Now()
+		if err := d.api.store.PutRoute(route); err != nil {
+			return err
+		}
+		if err := d.api.emit(d.app.ID, ct.EventTypeRoute, route.FormattedID(), route); err != nil {
+			return err
+		}
+	}
+	return d.api.emitDeploymentEvent(d.deployment, &ct.DeploymentEvent{Status: "routes-switched"})
+}
+
+// releaseServices maps the service of each process type of the old release
+// to the service of the same process type in the new release
+func releaseServices(app *ct.App, oldRelease, newRelease *ct.Release) map[string]string {
+	services := make(map[string]string, len(newRelease.Processes))
+	for typ, proc := range newRelease.Processes {
+		oldProc, ok := oldRelease.Processes[typ]
+		if !ok {
+			continue
+		}
+		services[processService(app, typ, oldProc)] = processService(app, typ, proc)
+	}
+	return services
+}
+
+// processService returns the service jobs of the process type register as,
+// defaulting to <app>-<type>
+func processService(app *ct.App, typ string, proc ct.ProcessType) string {
+	if proc.Service != "" {
+		return proc.Service
+	}
+	for _, port := range proc.Ports {
+		if port.Service != nil && port.Service.Name != "" {
+			return port.Service.Name
+		}
+	}
+	return app.Name + "-" + typ
+}
+
+// scale sets the formation of the given release to processes and converges
+// its jobs, failing if they take longer than the deploy timeout.
+func (d *deployer) scale(releaseID string, processes map[string]int) error {
+	formation := &ct.Formation{
+		AppID:     d.app.ID,
+		ReleaseID: releaseID,
+		Processes: copyProcesses(processes),
+	}
+	if releaseID == d.release.ID {
+		formation.Tags = d.deployment.Tags
+	}
+	if err := d.api.putFormation(d.app, formation, ""); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	err := d.api.scheduler.Reconcile(ctx, d.app, releaseID, processes, d.jobCallback)
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("timed out waiting for jobs of release %s (deploy timeout is %s)", releaseID, d.timeout)
+	}
+	return err
+}
+
+func (d *deployer) jobCallback(job *ct.Job) {
+	// pending jobs don't have a cluster ID yet
+	jobID := job.ID
+	if jobID == "" {
+		jobID = job.UUID
+	}
+	d.api.emitDeploymentEvent(d.deployment, &ct.DeploymentEvent{
+		ReleaseID: job.ReleaseID,
+		Status:    "running",
+		JobID:     jobID,
+		JobType:   job.Type,
+		JobState:  job.State,
+	})
+}
+
+func copyProcesses(processes map[string]int) map[string]int {
+	if processes == nil {
+		return nil
+	}
+	procs := make(map[string]int, len(processes))
+	for typ, count := range processes {
+		procs[typ] = count
+	}
+	return procs
+}
+
+func (c *controllerAPI) emitDeploymentEvent(d *ct.Deployment, event *ct.DeploymentEvent) error {
+	event.AppID = d.AppID
+	event.DeploymentID = d.ID
+	if event.ReleaseID == "" {
+		event.ReleaseID = d.NewReleaseID
+	}
+	return c.emit(d.AppID, ct.EventTypeDeployment, d.ID, event)
+}
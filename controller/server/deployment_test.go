@@ -0,0 +1,271 @@
+package server
+
+import (
+	"fmt"
+	router "github.com/flynn/flynn/router/types"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+)
+
+func newTestAPI() *controllerAPI {
+	api := &controllerAPI{
+		store:  NewMemoryStore(),
+		events: newEventBroker(),
+		logs:   newLogBuffer(),
+	}
+	api.scheduler = newScheduler(api)
+	return api
+}
+
+// deploy runs a deployment of the release and returns its events, with job
+// events shortened to "<old|new> <type> <state>" and only up and down job
+// states included
+func deploy(t *testing.T, client controller.Client, app *ct.App, release *ct.Release, strategy string) []string {
+	d, err := client.CreateDeployment(app.ID, release.ID, ct.DeployOptions{Strategy: strategy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan *ct.DeploymentEvent)
+	stream, err := client.StreamDeployment(d, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var list []string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("unexpected close of deployment event stream: %s", stream.Err())
+			}
+			if e.JobType == "" {
+				list = append(list, e.Status)
+			} else if e.JobState == ct.JobStateUp || e.JobState == ct.JobStateDown {
+				if e.JobID == "" {
+					t.Errorf("missing job ID in event %+v", e)
+				}
+				rel := "new"
+				if e.ReleaseID != release.ID {
+					rel = "old"
+				}
+				list = append(list, fmt.Sprintf("%s %s %s", rel, e.JobType, e.JobState))
+			}
+			switch e.Status {
+			case "complete":
+				return list
+			case "failed":
+				t.Fatalf("deployment failed: %s", e.Error)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for deployment, got events %v", list)
+		}
+	}
+}
+
+func TestDeploymentStrategies(t *testing.T) {
+	for _, test := range []struct {
+		strategy string
+		events   []string
+	}{
+		{
+			strategy: ct.DeployStrategyAllAtOnce,
+			events: []string{
+				"pending", "running",
+				"new web up", "new web up", "new worker up",
+				"old web down", "old web down", "old worker down",
+				"complete",
+			},
+		},
+		{
+			strategy: ct.DeployStrategyOneByOne,
+			events: []string{
+				"pending", "running",
+				"new web up", "old web down",
+				"new web up", "old web down",
+				"new worker up", "old worker down",
+				"complete",
+			},
+		},
+		{
+			strategy: ct.DeployStrategyBlueGreen,
+			events: []string{
+				"pending", "running",
+				"new web up", "new web up", "new worker up",
+				"routes-switched",
+				"old web down", "old web down", "old worker down",
+				"complete",
+			},
+		},
+	} {
+		t.Run(test.strategy, func(t *testing.T) {
+			client, cleanup := newTestClient(t)
+			defer cleanup()
+			app, _ := createDeployedApp(t, client)
+			release := createRelease(t, client, app, "")
+
+			events := deploy(t, client, app, release, test.strategy)
+			if !reflect.DeepEqual(events, test.events) {
+				t.Fatalf("unexpected events:\n got: %s\nwant: %s", strings.Join(events, ", "), strings.Join(test.events, ", "))
+			}
+			app, err := client.GetApp(app.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if app.ReleaseID != release.ID {
+				t.Fatalf("expected app release %s, got %s", release.ID, app.ReleaseID)
+			}
+			waitForJobs(t, client, app.ID, release.ID, 3)
+		})
+	}
+}
+
+func TestDeploymentBlueGreenSwitchesRoutes(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app, _ := createDeployedApp(t, client)
+	route := (&router.HTTPRoute{Domain: "deploy-test.example.com", Service: app.Name + "-web"}).ToRoute()
+	if err := client.CreateRoute(app.ID, route); err != nil {
+		t.Fatal(err)
+	}
+	other := (&router.HTTPRoute{Domain: "other.example.com", Service: "other-web"}).ToRoute()
+	if err := client.CreateRoute(app.ID, other); err != nil {
+		t.Fatal(err)
+	}
+	release := createRelease(t, client, app, app.Name+"-web-green")
+
+	deploy(t, client, app, release, ct.DeployStrategyBlueGreen)
+
+	route, err := client.GetRoute(app.ID, route.FormattedID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.Service != app.Name+"-web-green" {
+		t.Fatalf("expected route service %s-web-green, got %s", app.Name, route.Service)
+	}
+	other, err = client.GetRoute(app.ID, other.FormattedID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Service != "other-web" {
+		t.Fatalf("expected unrelated route service to be unchanged, got %s", other.Service)
+	}
+}
+
+func TestSetAppReleaseStaleApp(t *testing.T) {
+	api := newTestAPI()
+	app := &ct.App{ID: "app1", Name: "stale"}
+	if err := api.store.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	release := &ct.Release{ID: "release1", AppID: app.ID}
+	if err := api.store.CreateRelease(release); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := *app
+	updated := *app
+	now := time.Now()
+	updated.Meta = map[string]string{"team": "payments"}
+	updated.UpdatedAt = &now
+	if err := api.store.UpdateApp(&updated, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := api.setAppRelease(&stale, release); err != nil {
+		t.Fatal(err)
+	}
+	got, err := api.store.GetApp(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ReleaseID != release.ID {
+		t.Fatalf("expected app release %s, got %s", release.ID, got.ReleaseID)
+	}
+	if got.Meta["team"] != "payments" {
+		t.Fatalf("expected meta to be kept, got %v", got.Meta)
+	}
+	if stale.ReleaseID != release.ID {
+		t.Fatalf("expected the given app to be updated, got release %q", stale.ReleaseID)
+	}
+}
+
+func TestDeployerScaleTimeout(t *testing.T) {
+	api := newTestAPI()
+	app := &ct.App{ID: "app1", Name: "timeout"}
+	if err := api.store.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	release := &ct.Release{
+		ID:        "release1",
+		AppID:     app.ID,
+		Processes: map[string]ct.ProcessType{"web": {Args: []string{"web"}}},
+	}
+	if err := api.store.CreateRelease(release); err != nil {
+		t.Fatal(err)
+	}
+	d := &deployer{
+		api:        api,
+		app:        app,
+		release:    release,
+		deployment: &ct.Deployment{ID: "deployment1", AppID: app.ID, NewReleaseID: release.ID},
+		timeout:    time.Nanosecond,
+	}
+
+	err := d.scale(release.ID, map[string]int{"web": 1})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	jobs, err := api.store.ListJobs(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("expected no jobs to be started after the timeout, got %d", len(jobs))
+	}
+}
+
+func TestCreateDeploymentConflict(t *testing.T) {
+	store := NewMemoryStore()
+	srv := httptest.NewServer(NewHandler(Config{Store: store}))
+	defer srv.Close()
+	client, err := controller.NewClient(srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, _ := createDeployedApp(t, client)
+	release := createRelease(t, client, app, "")
+
+	// an unfinished deployment blocks new deployments of the app
+	now := time.Now()
+	running := &ct.Deployment{
+		ID:           "deployment1",
+		AppID:        app.ID,
+		NewReleaseID: release.ID,
+		Status:       "running",
+		CreatedAt:    &now,
+	}
+	if err := store.PutDeployment(running); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.CreateDeployment(app.ID, release.ID, ct.DeployOptions{})
+	if e, ok := err.(httphelper.JSONError); !ok || e.Code != httphelper.ConflictErrorCode {
+		t.Fatalf("expected conflict error, got %#v", err)
+	}
+
+	running.Status = "failed"
+	running.FinishedAt = &now
+	if err := store.PutDeployment(running); err != nil {
+		t.Fatal(err)
+	}
+	if events := deploy(t, client, app, release, ""); len(events) == 0 {
+		t.Fatal("expected deployment events")
+	}
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/ctxhelper"
+	"weo/pkg/httphelper"
+	"weo/pkg/sse"
+)
+
+// eventBufferSize is the number of events buffered for each subscriber
+// before it is considered too slow and closed
+const eventBufferSize = 1000
+
+type eventBroker struct {
+	mtx  sync.Mutex
+	subs map[*eventSubscriber]struct{}
+}
+
+type eventSubscriber struct {
+	Events chan *ct.Event
+	opts   ct.ListEventsOptions
+	broker *eventBroker
+	once   sync.Once
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subs: make(map[*eventSubscriber]struct{})}
+}
+
+func (b *eventBroker) Subscribe(appID string, objectTypes []ct.EventType, objectID string) *eventSubscriber {
+	sub := &eventSubscriber{
+		Events: make(chan *ct.Event, eventBufferSize),
+		opts: ct.ListEventsOptions{
+			AppID:       appID,
+			ObjectTypes: objectTypes,
+			ObjectID:    objectID,
+		},
+		broker: b,
+	}
+	b.mtx.Lock()
+	b.subs[sub] = struct{}{}
+	b.mtx.Unlock()
+	return sub
+}
+
+func (b *eventBroker) Publish(event *ct.Event) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for sub := range b.subs {
+		if !eventMatches(event, sub.opts) {
+			continue
+		}
+		select {
+		case sub.Events <- event:
+		default:
+			// the subscriber isn't keeping up, so close it and let the
+			// client reconnect using Last-Event-Id
+			delete(b.subs, sub)
+			sub.once.Do(func() { close(sub.Events) })
+		}
+	}
+}
+
+func (s *eventSubscriber) Close() {
+	s.broker.mtx.Lock()
+	delete(s.broker.subs, s)
+	s.broker.mtx.Unlock()
+	s.once.Do(func() { close(s.Events) })
+}
+
+// emit persists an event for the given object and publishes it to any
+// subscribers.
+func (c *controllerAPI) emit(appID string, typ ct.EventType, objectID string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	event := &ct.Event{
+		AppID:      appID,
+		ObjectType: typ,
+		ObjectID:   objectID,
+		Data:       raw,
+		CreatedAt:  &now,
+	}
+	if err := c.store.AddEvent(event); err != nil {
+		return err
+	}
+	c.events.Publish(event)
+	return nil
+}
+
+func (c *controllerAPI) GetEvent(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(params(ctx).ByName("id"), 10, 64)
+	if err != nil {
+		respondWithError(w, ct.ValidationError{Field: "id", Message: "is invalid"})
+		return
+	}
+	event, err := c.store.GetEvent(id)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, event)
+}
+
+func (c *controllerAPI) Events(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	opts, err := parseEventOptions(req)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if opts.AppID != "" {
+		app, err := c.store.GetApp(opts.AppID)
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		opts.AppID = app.ID
+	}
+
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		list, err := c.store.ListEvents(*opts)
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		httphelper.JSON(w, 200, list)
+		return
+	}
+
+	var lastID int64
+	if id := req.Header.Get("Last-Event-Id"); id != "" {
+		lastID, err = strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			respondWithError(w, ct.ValidationError{Field: "Last-Event-Id", Message: "is invalid"})
+			return
+		}
+	}
+
+	l, _ := ctxhelper.LoggerFromContext(ctx)
+	ch := make(chan *ct.Event)
+	s := sse.NewStream(w, ch, l)
+	s.Serve()
+	defer s.Close()
+
+	sub := c.events.Subscribe(opts.AppID, opts.ObjectTypes, opts.ObjectID)
+	defer sub.Close()
+
+	var currID int64
+	if req.FormValue("past") == "true" || lastID > 0 {
+		opts.SinceID = &lastID
+		list, err := c.store.ListEvents(*opts)
+		if err != nil {
+			s.CloseWithError(err)
+			return
+		}
+		// events are in ID DESC order, so iterate in reverse
+		for i := len(list) - 1; i >= 0; i-- {
+			select {
+			case ch <- list[i]:
+			case <-s.Done:
+				return
+			}
+			currID = list[i].ID
+		}
+	}
+
+	for {
+		select {
+		case <-s.Done:
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if event.ID <= currID {
+				continue
+			}
+			select {
+			case ch <- event:
+			case <-s.Done:
+				return
+			}
+		}
+	}
+}
+
+func parseEventOptions(req *http.Request) (*ct.ListEventsOptions, error) {
+	opts := &ct.ListEventsOptions{
+		AppID:    req.FormValue("app_id"),
+		ObjectID: req.FormValue("object_id"),
+	}
+	if types := req.FormValue("object_types"); types != "" {
+		for _, typ := range strings.Split(types, ",") {
+			opts.ObjectTypes = append(opts.ObjectTypes, ct.EventType(typ))
+		}
+	}
+	if s := req.FormValue("before_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, ct.ValidationError{Field: "before_id", Message: "is invalid"}
+		}
+		opts.BeforeID = &id
+	}
+	if s := req.FormValue("since_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, ct.ValidationError{Field: "since_id", Message: "is invalid"}
+		}
+		opts.SinceID = &id
+	}
+	if s := req.FormValue("count"); s != "" {
+		count, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, ct.ValidationError{Field: "count", Message: "is invalid"}
+		}
+		opts.Count = count
+	}
+	return opts, nil
+}
+
+// streamObjects serves an SSE stream of the objects returned by list which
+// were updated since the time given in the since query parameter, followed by
+// marker (an empty object signalling the end of the initial list), and then
+// the objects returned by convert for each subsequent event of the given
+// types. Events are skipped when convert returns a nil object.
+func (c *controllerAPI) streamObjects(ctx context.Context, w http.ResponseWriter, req *http.Request, types []ct.EventType, list func(since time.Time) ([]interface{}, error), convert func(*ct.Event) (interface{}, error), marker interface{}) {
+	var since time.Time
+	if s := req.FormValue("since"); s != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			respondWithError(w, ct.ValidationError{Field: "since", Message: "is invalid"})
+			return
+		}
+	}
+
+	// subscribe before listing so that no updates are missed
+	sub := c.events.Subscribe("", types, "")
+	defer sub.Close()
+
+	initial, err := list(since)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	l, _ := ctxhelper.LoggerFromContext(ctx)
+	ch := make(chan interface{})
+	s := sse.NewStream(w, ch, l)
+	s.Serve()
+	defer s.Close()
+
+	send := func(v interface{}) bool {
+		select {
+		case ch <- v:
+			return true
+		case <-s.Done:
+			return false
+		}
+	}
+	for _, v := range initial {
+		if !send(v) {
+			return
+		}
+	}
+	if !send(marker) {
+		return
+	}
+
+	for {
+		select {
+		case <-s.Done:
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				s.CloseWithError(errors.New("event stream closed unexpectedly"))
+				return
+			}
+			v, err := convert(event)
+			if err != nil {
+				s.CloseWithError(err)
+				return
+			}
+			if v != nil && !send(v) {
+				return
+			}
+		}
+	}
+}
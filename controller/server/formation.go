@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/random"
+)
+
+func (c *controllerAPI) PutFormation(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	var formation ct.Formation
+	if err := httphelper.DecodeJSON(req, &formation); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	release, err := c.store.GetRelease(params(ctx).ByName("releases_id"))
+	if err != nil {
+		if err == ErrNotFound {
+			err = ct.ValidationError{Field: "release", Message: "release does not exist"}
+		}
+		respondWithError(w, err)
+		return
+	}
+	if release.AppID != "" && release.AppID != app.ID {
+		respondWithError(w, ct.ValidationError{Field: "release", Message: "release does not belong to app"})
+		return
+	}
+	for typ, count := range formation.Processes {
+		if count < 0 {
+			respondWithError(w, ct.ValidationError{Field: "processes", Message: "count for " + typ + " must be positive"})
+			return
+		}
+		if _, ok := release.Processes[typ]; !ok && count > 0 {
+			respondWithError(w, ct.ValidationError{Field: "processes", Message: "release has no process type " + typ})
+			return
+		}
+	}
+	formation.AppID = app.ID
+	formation.ReleaseID = release.ID
+
+	if err := c.putFormation(app, &formation, ifMatch(req)); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	go c.scheduler.Reconcile(context.Background(), app, release.ID, formation.Processes, nil)
+	w.Header().Set("ETag", formation.ETag())
+	httphelper.JSON(w, 200, &formation)
+}
+
+// putFormation saves the formation and emits a deprecated scale event so
+// that formation streams pick up the change. The update is conditional on
+// ifMatch when not empty.
+func (c *controllerAPI) putFormation(app *ct.App, formation *ct.Formation, ifMatch string) error {
+	var prev map[string]int
+	now := time.Now()
+	if existing, err := c.store.GetFormation(formation.AppID, formation.ReleaseID); err == nil {
+		prev = existing.Processes
+		formation.CreatedAt = existing.CreatedAt
+	} else if err != ErrNotFound {
+		return err
+	} else {
+		formation.CreatedAt = &now
+	}
+	formation.UpdatedAt = &now
+	if err := c.store.PutFormation(formation, ifMatch); err != nil {
+		return err
+	}
+	return c.emit(app.ID, ct.EventTypeDeprecatedScale, app.ID+":"+formation.ReleaseID, &ct.DeprecatedScale{
+		PrevProcesses: prev,
+		Processes:     formation.Processes,
+		ReleaseID:     formation.ReleaseID,
+	})
+}
+
+func (c *controllerAPI) GetFormation(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	formation, err := c.store.GetFormation(app.ID, params(ctx).ByName("releases_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	w.Header().Set("ETag", formation.ETag())
+	if req.URL.Query().Get("expand") == "true" {
+		ef, err := c.expandFormation(formation)
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		httphelper.JSON(w, 200, ef)
+		return
+	}
+	httphelper.JSON(w, 200, formation)
+}
+
+func (c *controllerAPI) DeleteFormation(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	formation, err := c.store.GetFormation(app.ID, params(ctx).ByName("releases_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.scheduler.Reconcile(context.Background(), app, formation.ReleaseID, nil, nil); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.store.DeleteFormation(app.ID, formation.ReleaseID); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit(app.ID, ct.EventTypeDeprecatedScale, app.ID+":"+formation.ReleaseID, &ct.DeprecatedScale{
+		PrevProcesses: formation.Processes,
+		ReleaseID:     formation.ReleaseID,
+	})
+	w.WriteHeader(200)
+}
+
+func (c *controllerAPI) ListFormations(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListFormations(c.getApp(ctx).ID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+// GetFormations returns the expanded formations with running processes when
+// requested as JSON with active=true, and otherwise streams expanded
+// formations as they are updated.
+func (c *controllerAPI) GetFormations(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		if req.URL.Query().Get("active") != "true" {
+			respondWithError(w, ct.ValidationError{Field: "active", Message: "only active formations can be listed"})
+			return
+		}
+		formations, err := c.activeFormations()
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		httphelper.JSON(w, 200, formations)
+		return
+	}
+
+	list := func(since time.Time) ([]interface{}, error) {
+		formations, err := c.store.ListFormations("")
+		if err != nil {
+			return nil, err
+		}
+		res := make([]interface{}, 0, len(formations))
+		for _, f := range formations {
+			if f.UpdatedAt != nil && f.UpdatedAt.Before(since) {
+				continue
+			}
+			ef, err := c.expandFormation(f)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, ef)
+		}
+		return res, nil
+	}
+	convert := func(event *ct.Event) (interface{}, error) {
+		var scale ct.DeprecatedScale
+		if err := json.Unmarshal(event.Data, &scale); err != nil {
+			return nil, err
+		}
+		f, err := c.store.GetFormation(event.AppID, scale.ReleaseID)
+		if err == ErrNotFound {
+			// the formation was deleted (or its app is gone)
+			app, err := c.store.GetApp(event.AppID)
+			if err != nil {
+				return nil, nil
+			}
+			release, err := c.store.GetRelease(scale.ReleaseID)
+			if err != nil {
+				return nil, nil
+			}
+			return &ct.ExpandedFormation{
+				App:       app,
+				Release:   release,
+				UpdatedAt: time.Now(),
+				Deleted:   true,
+			}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return c.expandFormation(f)
+	}
+	c.streamObjects(ctx, w, req, []ct.EventType{ct.EventTypeDeprecatedScale}, list, convert, &ct.ExpandedFormation{})
+}
+
+func (c *controllerAPI) activeFormations() ([]*ct.ExpandedFormation, error) {
+	formations, err := c.store.ListFormations("")
+	if err != nil {
+		return nil, err
+	}
+	var res []*ct.ExpandedFormation
+	for _, f := range formations {
+		if processCount(f.Processes) == 0 {
+			continue
+		}
+		ef, err := c.expandFormation(f)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ef)
+	}
+	return res, nil
+}
+
+func (c *controllerAPI) expandFormation(f *ct.Formation) (*ct.ExpandedFormation, error) {
+	app, err := c.store.GetApp(f.AppID)
+	if err != nil {
+		return nil, err
+	}
+	release, err := c.store.GetRelease(f.ReleaseID)
+	if err != nil {
+		return nil, err
+	}
+	ef := &ct.ExpandedFormation{
+		App:       app,
+		Release:   release,
+		Processes: f.Processes,
+		Tags:      f.Tags,
+	}
+	if f.UpdatedAt != nil {
+		ef.UpdatedAt = *f.UpdatedAt
+	}
+	for _, id := range release.ArtifactIDs {
+		artifact, err := c.store.GetArtifact(id)
+		if err != nil {
+			return nil, err
+		}
+		ef.Artifacts = append(ef.Artifacts, artifact)
+	}
+	return ef, nil
+}
+
+// PutScaleRequest updates the formation with the requested processes and
+// tags, emitting scale request events as the jobs are reconciled.
+func (c *controllerAPI) PutScaleRequest(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	var sr ct.ScaleRequest
+	if err := httphelper.DecodeJSON(req, &sr); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	release, err := c.store.GetRelease(params(ctx).ByName("releases_id"))
+	if err != nil {
+		if err == ErrNotFound {
+			err = ct.ValidationError{Field: "release", Message: "release does not exist"}
+		}
+		respondWithError(w, err)
+		return
+	}
+
+	formation, err := c.store.GetFormation(app.ID, release.ID)
+	if err == ErrNotFound {
+		formation = &ct.Formation{AppID: app.ID, ReleaseID: release.ID}
+	} else if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if sr.NewProcesses != nil {
+		for typ, count := range *sr.NewProcesses {
+			if count < 0 {
+				respondWithError(w, ct.ValidationError{Field: "new_processes", Message: "count for " + typ + " must be positive"})
+				return
+			}
+			if _, ok := release.Processes[typ]; !ok && count > 0 {
+				respondWithError(w, ct.ValidationError{Field: "new_processes", Message: "release has no process type " + typ})
+				return
+			}
+		}
+	}
+
+	now := time.Now()
+	sr.ID = random.UUID()
+	sr.AppID = app.ID
+	sr.ReleaseID = release.ID
+	sr.State = ct.ScaleRequestStatePending
+	sr.OldProcesses = formation.Processes
+	sr.OldTags = formation.Tags
+	sr.CreatedAt = &now
+	sr.UpdatedAt = &now
+
+	if sr.NewProcesses != nil {
+		formation.Processes = *sr.NewProcesses
+	}
+	if sr.NewTags != nil {
+		formation.Tags = *sr.NewTags
+	}
+	if err := c.putFormation(app, formation, ""); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.emit(app.ID, ct.EventTypeScaleRequest, sr.ID, &sr); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, &sr)
+
+	complete := sr
+	go func() {
+		if err := c.scheduler.Reconcile(context.Background(), app, release.ID, formation.Processes, nil); err != nil {
+			complete.State = ct.ScaleRequestStateCancelled
+		} else {
+			complete.State = ct.ScaleRequestStateComplete
+		}
+		now := time.Now()
+		complete.UpdatedAt = &now
+		c.emit(app.ID, ct.EventTypeScaleRequest, complete.ID, &complete)
+	}()
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	host "github.com/flynn/flynn/host/types"
+	"net/http"
+	"strings"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/random"
+)
+
+func (c *controllerAPI) ListJobs(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListJobs(c.getApp(ctx).ID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) ListActiveJobs(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	jobs, err := c.store.ListJobs("")
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	list := make([]*ct.Job, 0, len(jobs))
+	for _, job := range jobs {
+		if !job.IsDown() {
+			list = append(list, job)
+		}
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) GetJob(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	job, err := c.lookupJob(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, job)
+}
+
+// lookupJob returns the job identified by the jobs_id parameter, which may
+// be either the job's UUID or its full cluster ID.
+func (c *controllerAPI) lookupJob(ctx context.Context) (*ct.Job, error) {
+	id := params(ctx).ByName("jobs_id")
+	job, err := c.store.GetJob(strings.TrimPrefix(id, localHostID+"-"))
+	if err != nil {
+		return nil, err
+	}
+	if job.AppID != c.getApp(ctx).ID {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+func (c *controllerAPI) PutJob(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	var job ct.Job
+	if err := httphelper.DecodeJSON(req, &job); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	job.UUID = params(ctx).ByName("jobs_id")
+	job.AppID = app.ID
+	if job.State == "" {
+		respondWithError(w, ct.ValidationError{Field: "state", Message: "must not be empty"})
+		return
+	}
+	if existing, err := c.store.GetJob(job.UUID); err == nil {
+		job.CreatedAt = existing.CreatedAt
+	} else if err != ErrNotFound {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.putJob(&job, nil); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, &job)
+}
+
+// attachProtocol is the Upgrade protocol requested to attach to a new job
+const attachProtocol = "weo-attach/0"
+
+// RunJob starts a one-off job. Jobs do not run any processes, so the job
+// immediately starts and then exits with status zero. Attached jobs produce
+// no output, the attach stream just reports the exit status.
+func (c *controllerAPI) RunJob(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	var newJob ct.NewJob
+	if err := httphelper.DecodeJSON(req, &newJob); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	attach := req.Header.Get("Upgrade") == attachProtocol
+	if upgrade := req.Header.Get("Upgrade"); upgrade != "" && !attach {
+		respondWithError(w, ct.ValidationError{Message: "unsupported upgrade protocol " + upgrade})
+		return
+	}
+	if newJob.ReleaseID == "" && len(newJob.ArtifactIDs) == 0 {
+		respondWithError(w, ct.ValidationError{Field: "release", Message: "must be set when no artifacts are given"})
+		return
+	}
+	if newJob.ReleaseID != "" {
+		if _, err := c.store.GetRelease(newJob.ReleaseID); err != nil {
+			if err == ErrNotFound {
+				err = ct.ValidationError{Field: "release", Message: "release does not exist"}
+			}
+			respondWithError(w, err)
+			return
+		}
+	}
+	if len(newJob.Args) == 0 {
+		newJob.Args = append(newJob.DeprecatedEntrypoint, newJob.DeprecatedCmd...)
+	}
+
+	now := time.Now()
+	uuid := random.UUID()
+	job := &ct.Job{
+		ID:        localHostID + "-" + uuid,
+		UUID:      uuid,
+		HostID:    localHostID,
+		AppID:     app.ID,
+		ReleaseID: newJob.ReleaseID,
+		Type:      "run",
+		State:     ct.JobStateStarting,
+		Args:      newJob.Args,
+		Meta:      newJob.Meta,
+		CreatedAt: &now,
+	}
+	if err := c.putJob(job, nil); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if !newJob.DisableLog {
+		c.logs.Add(app.ID, job, "Starting process with command \""+strings.Join(job.Args, " ")+"\"")
+	}
+	job.State = ct.JobStateUp
+	if err := c.putJob(job, nil); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if attach {
+		c.attachJob(w, job)
+		return
+	}
+	httphelper.JSON(w, 200, job)
+
+	// one-off jobs have nothing to run, so they exit straight away
+	stopped := *job
+	go c.scheduler.StopJob(&stopped, nil)
+}
+
+// attachJob switches the connection to the attach protocol, stops the job
+// and sends its exit status as the only frame.
+func (c *controllerAPI) attachJob(w http.ResponseWriter, job *ct.Job) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		respondWithError(w, ct.ValidationError{Message: "attaching is not supported by the connection"})
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: upgrade\r\nUpgrade: " + attachProtocol + "\r\n\r\n")
+
+	var exitStatus int32 = -1
+	if err := c.scheduler.StopJob(job, nil); err == nil && job.ExitStatus != nil {
+		exitStatus = *job.ExitStatus
+	}
+	frame := make([]byte, 5)
+	frame[0] = host.AttachExit
+	binary.BigEndian.PutUint32(frame[1:], uint32(exitStatus))
+	rw.Write(frame)
+	rw.Flush()
+}
+
+func (c *controllerAPI) KillJob(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	job, err := c.lookupJob(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.scheduler.StopJob(job, nil); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	w.WriteHeader(200)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"github.com/flynn/flynn/pkg/cluster"
+	"testing"
+	ct "weo/controller/types"
+)
+
+func TestRunJobAttached(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app, release := createDeployedApp(t, client)
+
+	rwc, err := client.RunJobAttached(app.ID, &ct.NewJob{ReleaseID: release.ID, Args: []string{"echo", "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+	var stdout, stderr bytes.Buffer
+	exitStatus, err := cluster.NewAttachClient(rwc).Receive(&stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitStatus != 0 {
+		t.Fatalf("expected exit status 0, got %d", exitStatus)
+	}
+
+	jobs, err := client.JobList(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, job := range jobs {
+		if job.Type != "run" {
+			continue
+		}
+		found = true
+		if job.State != ct.JobStateDown || job.ExitStatus == nil || *job.ExitStatus != 0 {
+			t.Fatalf("expected the attached job to have exited, got %+v", job)
+		}
+	}
+	if !found {
+		t.Fatal("attached job not found")
+	}
+}
+
+func TestRunJobDetached(t *testing.T) {
+	client, cleanup := newTestClient(t)
+	defer cleanup()
+	app, release := createDeployedApp(t, client)
+
+	job, err := client.RunJobDetached(app.ID, &ct.NewJob{ReleaseID: release.ID, Args: []string{"sleep", "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != ct.JobStateUp || job.Type != "run" {
+		t.Fatalf("expected a running one-off job, got %+v", job)
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	logaggc "github.com/flynn/flynn/logaggregator/client"
+	logagg "github.com/flynn/flynn/logaggregator/types"
+	"net/http"
+	"strconv"
+	"strings"
+	ct "weo/controller/types"
+	"weo/pkg/ctxhelper"
+	"weo/pkg/httphelper"
+	"weo/pkg/sse"
+)
+
+// AppLog serves the buffered log of the app as newline delimited JSON
+// messages, or as an SSE stream of log chunks when requested, optionally
+// following new messages.
+func (c *controllerAPI) AppLog(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	opts := logagg.LogOpts{
+		Follow: req.FormValue("follow") == "true",
+		JobID:  req.FormValue("job_id"),
+	}
+	if vals, ok := req.Form["process_type"]; ok && len(vals) > 0 {
+		opts.ProcessType = &vals[len(vals)-1]
+	}
+	if strTypes := req.FormValue("stream_types"); strTypes != "" {
+		for _, typ := range strings.Split(strTypes, ",") {
+			opts.StreamTypes = append(opts.StreamTypes, logagg.StreamType(typ))
+		}
+	}
+	if strLines := req.FormValue("lines"); strLines != "" {
+		lines, err := strconv.Atoi(strLines)
+		if err != nil {
+			respondWithError(w, ct.ValidationError{Field: "lines", Message: "is invalid"})
+			return
+		}
+		opts.Lines = &lines
+	}
+
+	appID := c.getApp(ctx).ID
+	var follow chan *logaggc.Message
+	if opts.Follow {
+		follow = make(chan *logaggc.Message, logBufferSize)
+		defer c.logs.Unfollow(appID, follow)
+	}
+	var msgs []*logaggc.Message
+	for _, msg := range c.logs.Read(appID, follow) {
+		if logMatches(msg, &opts) {
+			msgs = append(msgs, msg)
+		}
+	}
+	if opts.Lines != nil && *opts.Lines >= 0 && len(msgs) > *opts.Lines {
+		msgs = msgs[len(msgs)-*opts.Lines:]
+	}
+
+	if !strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(200)
+		fw := httphelper.FlushWriter{Writer: w, Enabled: opts.Follow}
+		enc := json.NewEncoder(fw)
+		for _, msg := range msgs {
+			if err := enc.Encode(msg); err != nil {
+				return
+			}
+		}
+		if !opts.Follow {
+			return
+		}
+		if wf, ok := w.(http.Flusher); ok {
+			wf.Flush()
+		}
+		for {
+			select {
+			case msg := <-follow:
+				if !logMatches(msg, &opts) {
+					continue
+				}
+				if err := enc.Encode(msg); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	ch := make(chan *ct.SSELogChunk)
+	l, _ := ctxhelper.LoggerFromContext(ctx)
+	s := sse.NewStream(w, ch, l)
+	defer s.Close()
+	s.Serve()
+
+	send := func(msg *logaggc.Message) bool {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return false
+		}
+		select {
+		case ch <- &ct.SSELogChunk{Event: "message", Data: data}:
+			return true
+		case <-s.Done:
+			return false
+		}
+	}
+	for _, msg := range msgs {
+		if !send(msg) {
+			return
+		}
+	}
+	if !opts.Follow {
+		select {
+		case ch <- &ct.SSELogChunk{Event: "eof"}:
+		case <-s.Done:
+		}
+		return
+	}
+	for {
+		select {
+		case msg := <-follow:
+			if logMatches(msg, &opts) && !send(msg) {
+				return
+			}
+		case <-s.Done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func logMatches(msg *logaggc.Message, opts *logagg.LogOpts) bool {
+	if opts.JobID != "" && msg.JobID != opts.JobID {
+		return false
+	}
+	if opts.ProcessType != nil && msg.ProcessType != *opts.ProcessType {
+		return false
+	}
+	if len(opts.StreamTypes) == 0 {
+		return true
+	}
+	for _, typ := range opts.StreamTypes {
+		if msg.Stream == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,648 @@
+package server
+
+import (
+	"encoding/json"
+	router "github.com/flynn/flynn/router/types"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	ct "weo/controller/types"
+)
+
+// MemoryStore is a Store which keeps everything in memory, it is used for
+// local development and tests.
+type MemoryStore struct {
+	mtx         sync.RWMutex
+	apps        map[string]*ct.App
+	artifacts   map[string]*ct.Artifact
+	releases    map[string]*ct.Release
+	formations  map[string]*ct.Formation
+	jobs        map[string]*ct.Job
+	deployments map[string]*ct.Deployment
+	providers   map[string]*ct.Provider
+	resources   map[string]*ct.Resource
+	routes      map[string]*router.Route
+	volumes     map[string]*ct.Volume
+	sinks       map[string]*ct.Sink
+	events      []*ct.Event
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		apps:        make(map[string]*ct.App),
+		artifacts:   make(map[string]*ct.Artifact),
+		releases:    make(map[string]*ct.Release),
+		formations:  make(map[string]*ct.Formation),
+		jobs:        make(map[string]*ct.Job),
+		deployments: make(map[string]*ct.Deployment),
+		providers:   make(map[string]*ct.Provider),
+		resources:   make(map[string]*ct.Resource),
+		routes:      make(map[string]*router.Route),
+		volumes:     make(map[string]*ct.Volume),
+		sinks:       make(map[string]*ct.Sink),
+	}
+}
+
+// clone deep copies src into dst by encoding it as JSON, as the data store
+// does, so that callers never share maps, slices or pointers with the stored
+// objects
+func clone(src, dst interface{}) {
+	data, err := json.Marshal(src)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		panic(err)
+	}
+}
+
+// copyEvent copies an event without going through JSON, which would drop
+// the fields which are not encoded
+func copyEvent(event *ct.Event) *ct.Event {
+	e := *event
+	e.Data = append(json.RawMessage(nil), event.Data...)
+	return &e
+}
+
+// newer is used to sort lists with the most recently created objects first
+func newer(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return b == nil && a != nil
+	}
+	return a.After(*b)
+}
+
+func (s *MemoryStore) CreateApp(app *ct.App) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, a := range s.apps {
+		if a.Name == app.Name {
+			return ct.ValidationError{Field: "name", Message: "is already taken"}
+		}
+	}
+	a := &ct.App{}
+	clone(app, a)
+	s.apps[app.ID] = a
+	return nil
+}
+
+func (s *MemoryStore) GetApp(id string) (*ct.App, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if app, ok := s.apps[id]; ok {
+		a := &ct.App{}
+		clone(app, a)
+		return a, nil
+	}
+	for _, app := range s.apps {
+		if app.Name == id {
+			a := &ct.App{}
+			clone(app, a)
+			return a, nil
+		}
+	}
+	return nil, ct.ErrNotFound
+}
+
+func (s *MemoryStore) ListApps() ([]*ct.App, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.App, 0, len(s.apps))
+	for _, app := range s.apps {
+		a := &ct.App{}
+		clone(app, a)
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) UpdateApp(app *ct.App, ifMatch string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	existing, ok := s.apps[app.ID]
+	if !ok {
+		return ct.ErrNotFound
+	}
+	if ifMatch != "" && existing.ETag() != ifMatch {
+		return ct.ErrPreconditionFailed
+	}
+	a := &ct.App{}
+	clone(app, a)
+	s.apps[app.ID] = a
+	return nil
+}
+
+func (s *MemoryStore) DeleteApp(id string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.apps[id]; !ok {
+		return ct.ErrNotFound
+	}
+	delete(s.apps, id)
+	return nil
+}
+
+func (s *MemoryStore) CreateArtifact(artifact *ct.Artifact) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	a := &ct.Artifact{}
+	clone(artifact, a)
+	s.artifacts[artifact.ID] = a
+	return nil
+}
+
+func (s *MemoryStore) GetArtifact(id string) (*ct.Artifact, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	artifact, ok := s.artifacts[id]
+	if !ok {
+		return nil, ct.ErrNotFound
+	}
+	a := &ct.Artifact{}
+	clone(artifact, a)
+	return a, nil
+}
+
+func (s *MemoryStore) ListArtifacts() ([]*ct.Artifact, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.Artifact, 0, len(s.artifacts))
+	for _, artifact := range s.artifacts {
+		a := &ct.Artifact{}
+		clone(artifact, a)
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) CreateRelease(release *ct.Release) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r := &ct.Release{}
+	clone(release, r)
+	s.releases[release.ID] = r
+	return nil
+}
+
+func (s *MemoryStore) GetRelease(id string) (*ct.Release, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	release, ok := s.releases[id]
+	if !ok {
+		return nil, ct.ErrNotFound
+	}
+	r := &ct.Release{}
+	clone(release, r)
+	return r, nil
+}
+
+func (s *MemoryStore) ListReleases(appID string) ([]*ct.Release, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.Release, 0, len(s.releases))
+	for _, release := range s.releases {
+		if appID != "" && release.AppID != appID {
+			continue
+		}
+		r := &ct.Release{}
+		clone(release, r)
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) DeleteRelease(id string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.releases[id]; !ok {
+		return ct.ErrNotFound
+	}
+	delete(s.releases, id)
+	return nil
+}
+
+func formationKey(appID, releaseID string) string {
+	return appID + ":" + releaseID
+}
+
+func (s *MemoryStore) PutFormation(formation *ct.Formation, ifMatch string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	key := formationKey(formation.AppID, formation.ReleaseID)
+	if ifMatch != "" {
+		existing, ok := s.formations[key]
+		if !ok {
+			return ct.ErrNotFound
+		}
+		if existing.ETag() != ifMatch {
+			return ct.ErrPreconditionFailed
+		}
+	}
+	f := &ct.Formation{}
+	clone(formation, f)
+	s.formations[key] = f
+	return nil
+}
+
+func (s *MemoryStore) GetFormation(appID, releaseID string) (*ct.Formation, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	formation, ok := s.formations[formationKey(appID, releaseID)]
+	if !ok {
+		return nil, ct.ErrNotFound
+	}
+	f := &ct.Formation{}
+	clone(formation, f)
+	return f, nil
+}
+
+func (s *MemoryStore) ListFormations(appID string) ([]*ct.Formation, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.Formation, 0, len(s.formations))
+	for _, formation := range s.formations {
+		if appID != "" && formation.AppID != appID {
+			continue
+		}
+		f := &ct.Formation{}
+		clone(formation, f)
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) DeleteFormation(appID, releaseID string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	key := formationKey(appID, releaseID)
+	if _, ok := s.formations[key]; !ok {
+		return ct.ErrNotFound
+	}
+	delete(s.formations, key)
+	return nil
+}
+
+func (s *MemoryStore) PutJob(job *ct.Job) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	j := &ct.Job{}
+	clone(job, j)
+	s.jobs[job.UUID] = j
+	return nil
+}
+
+func (s *MemoryStore) GetJob(uuid string) (*ct.Job, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	job, ok := s.jobs[uuid]
+	if !ok {
+		for _, j := range s.jobs {
+			if j.ID == uuid {
+				job = j
+				break
+			}
+		}
+		if job == nil {
+			return nil, ct.ErrNotFound
+		}
+	}
+	j := &ct.Job{}
+	clone(job, j)
+	return j, nil
+}
+
+func (s *MemoryStore) ListJobs(appID string) ([]*ct.Job, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if appID != "" && job.AppID != appID {
+			continue
+		}
+		j := &ct.Job{}
+		clone(job, j)
+		list = append(list, j)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) PutDeployment(deployment *ct.Deployment) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	d := &ct.Deployment{}
+	clone(deployment, d)
+	s.deployments[deployment.ID] = d
+	return nil
+}
+
+func (s *MemoryStore) GetDeployment(id string) (*ct.Deployment, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	deployment, ok := s.deployments[id]
+	if !ok {
+		return nil, ct.ErrNotFound
+	}
+	d := &ct.Deployment{}
+	clone(deployment, d)
+	return d, nil
+}
+
+func (s *MemoryStore) ListDeployments(appID string) ([]*ct.Deployment, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.Deployment, 0, len(s.deployments))
+	for _, deployment := range s.deployments {
+		if appID != "" && deployment.AppID != appID {
+			continue
+		}
+		d := &ct.Deployment{}
+		clone(deployment, d)
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) CreateProvider(provider *ct.Provider) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, p := range s.providers {
+		if p.Name == provider.Name {
+			return ct.ValidationError{Field: "name", Message: "is already taken"}
+		}
+	}
+	p := &ct.Provider{}
+	clone(provider, p)
+	s.providers[provider.ID] = p
+	return nil
+}
+
+func (s *MemoryStore) GetProvider(id string) (*ct.Provider, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for _, provider := range s.providers {
+		if provider.ID == id || provider.Name == id {
+			p := &ct.Provider{}
+			clone(provider, p)
+			return p, nil
+		}
+	}
+	return nil, ct.ErrNotFound
+}
+
+func (s *MemoryStore) ListProviders() ([]*ct.Provider, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.Provider, 0, len(s.providers))
+	for _, provider := range s.providers {
+		p := &ct.Provider{}
+		clone(provider, p)
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) PutResource(resource *ct.Resource) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r := &ct.Resource{}
+	clone(resource, r)
+	s.resources[resource.ID] = r
+	return nil
+}
+
+func (s *MemoryStore) GetResource(id string) (*ct.Resource, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	resource, ok := s.resources[id]
+	if !ok {
+		return nil, ct.ErrNotFound
+	}
+	r := &ct.Resource{}
+	clone(resource, r)
+	return r, nil
+}
+
+func (s *MemoryStore) ListResources() ([]*ct.Resource, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.Resource, 0, len(s.resources))
+	for _, resource := range s.resources {
+		r := &ct.Resource{}
+		clone(resource, r)
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) DeleteResource(id string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.resources[id]; !ok {
+		return ct.ErrNotFound
+	}
+	delete(s.resources, id)
+	return nil
+}
+
+func (s *MemoryStore) PutRoute(route *router.Route) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, r := range s.routes {
+		if r.ID == route.ID || r.Type != route.Type {
+			continue
+		}
+		if r.Type == "http" && r.Domain == route.Domain && r.Path == route.Path ||
+			r.Type == "tcp" && r.Port == route.Port {
+			return ct.ValidationError{Message: "conflicting route already exists"}
+		}
+	}
+	r := &router.Route{}
+	clone(route, r)
+	s.routes[route.FormattedID()] = r
+	return nil
+}
+
+func (s *MemoryStore) GetRoute(typ, id string) (*router.Route, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	route, ok := s.routes[typ+"/"+id]
+	if !ok {
+		return nil, ct.ErrNotFound
+	}
+	r := &router.Route{}
+	clone(route, r)
+	return r, nil
+}
+
+func (s *MemoryStore) ListRoutes() ([]*router.Route, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*router.Route, 0, len(s.routes))
+	for _, route := range s.routes {
+		r := &router.Route{}
+		clone(route, r)
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) DeleteRoute(typ, id string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	key := typ + "/" + id
+	if _, ok := s.routes[key]; !ok {
+		return ct.ErrNotFound
+	}
+	delete(s.routes, key)
+	return nil
+}
+
+func (s *MemoryStore) PutVolume(volume *ct.Volume) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	v := &ct.Volume{}
+	clone(volume, v)
+	s.volumes[volume.ID] = v
+	return nil
+}
+
+func (s *MemoryStore) GetVolume(id string) (*ct.Volume, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	volume, ok := s.volumes[id]
+	if !ok {
+		return nil, ct.ErrNotFound
+	}
+	v := &ct.Volume{}
+	clone(volume, v)
+	return v, nil
+}
+
+func (s *MemoryStore) ListVolumes() ([]*ct.Volume, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.Volume, 0, len(s.volumes))
+	for _, volume := range s.volumes {
+		v := &ct.Volume{}
+		clone(volume, v)
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) CreateSink(sink *ct.Sink) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	k := &ct.Sink{}
+	clone(sink, k)
+	s.sinks[sink.ID] = k
+	return nil
+}
+
+func (s *MemoryStore) GetSink(id string) (*ct.Sink, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	sink, ok := s.sinks[id]
+	if !ok {
+		return nil, ct.ErrNotFound
+	}
+	k := &ct.Sink{}
+	clone(sink, k)
+	return k, nil
+}
+
+func (s *MemoryStore) ListSinks() ([]*ct.Sink, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	list := make([]*ct.Sink, 0, len(s.sinks))
+	for _, sink := range s.sinks {
+		k := &ct.Sink{}
+		clone(sink, k)
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i].CreatedAt, list[j].CreatedAt) })
+	return list, nil
+}
+
+func (s *MemoryStore) DeleteSink(id string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.sinks[id]; !ok {
+		return ct.ErrNotFound
+	}
+	delete(s.sinks, id)
+	return nil
+}
+
+func (s *MemoryStore) AddEvent(event *ct.Event) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	event.ID = int64(len(s.events) + 1)
+	s.events = append(s.events, copyEvent(event))
+	return nil
+}
+
+func (s *MemoryStore) GetEvent(id int64) (*ct.Event, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if id < 1 || id > int64(len(s.events)) {
+		return nil, ct.ErrNotFound
+	}
+	return copyEvent(s.events[id-1]), nil
+}
+
+func (s *MemoryStore) ListEvents(opts ct.ListEventsOptions) ([]*ct.Event, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	var list []*ct.Event
+	for i := len(s.events) - 1; i >= 0; i-- {
+		event := s.events[i]
+		if !eventMatches(event, opts) {
+			continue
+		}
+		list = append(list, copyEvent(event))
+		if opts.Count > 0 && len(list) == opts.Count {
+			break
+		}
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) Ping() error {
+	return nil
+}
+
+func eventMatches(event *ct.Event, opts ct.ListEventsOptions) bool {
+	if opts.AppID != "" && event.AppID != opts.AppID {
+		return false
+	}
+	if opts.ObjectID != "" && event.ObjectID != opts.ObjectID {
+		return false
+	}
+	if opts.BeforeID != nil && event.ID >= *opts.BeforeID {
+		return false
+	}
+	if opts.SinceID != nil && event.ID <= *opts.SinceID {
+		return false
+	}
+	if len(opts.ObjectTypes) == 0 {
+		return true
+	}
+	for _, typ := range opts.ObjectTypes {
+		if strings.EqualFold(string(typ), string(event.ObjectType)) {
+			return true
+		}
+	}
+	return false
+}
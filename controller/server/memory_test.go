@@ -0,0 +1,86 @@
+package server
+
+import (
+	"testing"
+	ct "weo/controller/types"
+)
+
+func TestMemoryStoreCopies(t *testing.T) {
+	s := NewMemoryStore()
+
+	app := &ct.App{ID: "app1", Name: "copies", Meta: map[string]string{"team": "payments"}}
+	if err := s.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	app.Meta["team"] = "changed"
+	got, err := s.GetApp(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Meta["team"] = "changed"
+	if got, _ = s.GetApp(app.ID); got.Meta["team"] != "payments" {
+		t.Fatalf("expected stored app meta to be unchanged, got %v", got.Meta)
+	}
+
+	release := &ct.Release{
+		ID:        "release1",
+		Env:       map[string]string{"PORT": "8080"},
+		Processes: map[string]ct.ProcessType{"web": {Args: []string{"web"}, Env: map[string]string{"A": "1"}}},
+	}
+	if err := s.CreateRelease(release); err != nil {
+		t.Fatal(err)
+	}
+	release.Env["PORT"] = "changed"
+	release.Processes["web"].Env["A"] = "changed"
+	list, err := s.ListReleases("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list[0].Processes["web"].Args[0] = "changed"
+	r, err := s.GetRelease(release.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Env["PORT"] != "8080" || r.Processes["web"].Env["A"] != "1" || r.Processes["web"].Args[0] != "web" {
+		t.Fatalf("expected stored release to be unchanged, got %+v", r)
+	}
+
+	artifact := &ct.Artifact{ID: "artifact1", URI: "https://example.com/image.json", Meta: map[string]string{"k": "v"}}
+	if err := s.CreateArtifact(artifact); err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.GetArtifact(artifact.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == artifact {
+		t.Fatal("expected a copy of the stored artifact")
+	}
+	a.URI = "changed"
+	a.Meta["k"] = "changed"
+	if a, _ = s.GetArtifact(artifact.ID); a.URI != artifact.URI || a.Meta["k"] != "v" {
+		t.Fatalf("expected stored artifact to be unchanged, got %+v", a)
+	}
+
+	job := &ct.Job{UUID: "job1", AppID: app.ID, Meta: map[string]string{"k": "v"}}
+	if err := s.PutJob(job); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := s.ListJobs(app.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs[0].Meta["k"] = "changed"
+	if j, _ := s.GetJob(job.UUID); j.Meta["k"] != "v" {
+		t.Fatalf("expected stored job meta to be unchanged, got %v", j.Meta)
+	}
+
+	event := &ct.Event{AppID: app.ID, ObjectType: ct.EventTypeApp, Data: []byte(`{"a":1}`)}
+	if err := s.AddEvent(event); err != nil {
+		t.Fatal(err)
+	}
+	event.Data[2] = 'b'
+	if e, _ := s.GetEvent(event.ID); string(e.Data) != `{"a":1}` {
+		t.Fatalf("expected stored event data to be unchanged, got %s", e.Data)
+	}
+}
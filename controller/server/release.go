@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/random"
+)
+
+func (c *controllerAPI) CreateArtifact(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var artifact ct.Artifact
+	if err := httphelper.DecodeJSON(req, &artifact); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if artifact.Type == "" {
+		respondWithError(w, ct.ValidationError{Field: "type", Message: "must not be empty"})
+		return
+	}
+	if artifact.URI == "" {
+		respondWithError(w, ct.ValidationError{Field: "uri", Message: "must not be empty"})
+		return
+	}
+	if artifact.ID == "" {
+		artifact.ID = random.UUID()
+	}
+	now := time.Now()
+	artifact.CreatedAt = &now
+
+	if err := c.store.CreateArtifact(&artifact); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit("", ct.EventTypeArtifact, artifact.ID, &artifact)
+	httphelper.JSON(w, 200, &artifact)
+}
+
+func (c *controllerAPI) ListArtifacts(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListArtifacts()
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) GetArtifact(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	artifact, err := c.store.GetArtifact(params(ctx).ByName("artifacts_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, artifact)
+}
+
+func (c *controllerAPI) CreateRelease(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var release ct.Release
+	if err := httphelper.DecodeJSON(req, &release); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if release.LegacyArtifactID != "" && len(release.ArtifactIDs) == 0 {
+		release.ArtifactIDs = []string{release.LegacyArtifactID}
+	}
+	if err := release.Validate(); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if release.AppID != "" {
+		app, err := c.store.GetApp(release.AppID)
+		if err != nil {
+			if err == ErrNotFound {
+				err = ct.ValidationError{Field: "app", Message: "app does not exist"}
+			}
+			respondWithError(w, err)
+			return
+		}
+		release.AppID = app.ID
+	}
+	for _, id := range release.ArtifactIDs {
+		if _, err := c.store.GetArtifact(id); err != nil {
+			if err == ErrNotFound {
+				err = ct.ValidationError{Field: "artifacts", Message: "artifact " + id + " does not exist"}
+			}
+			respondWithError(w, err)
+			return
+		}
+	}
+	if release.ID == "" {
+		release.ID = random.UUID()
+	}
+	now := time.Now()
+	release.CreatedAt = &now
+
+	if err := c.store.CreateRelease(&release); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit(release.AppID, ct.EventTypeRelease, release.ID, &release)
+	httphelper.JSON(w, 200, &release)
+}
+
+func (c *controllerAPI) ListReleases(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListReleases("")
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) GetRelease(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	release, err := c.store.GetRelease(params(ctx).ByName("releases_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, release)
+}
+
+func (c *controllerAPI) GetAppReleases(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListReleases(c.getApp(ctx).ID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) SetAppRelease(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var rid releaseID
+	if err := httphelper.DecodeJSON(req, &rid); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	app := c.getApp(ctx)
+	release, err := c.store.GetRelease(rid.ID)
+	if err != nil {
+		if err == ErrNotFound {
+			err = ct.ValidationError{Field: "id", Message: "release does not exist"}
+		}
+		respondWithError(w, err)
+		return
+	}
+	if err := c.setAppRelease(app, release); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, release)
+}
+
+// setAppRelease sets the current release of the app. The app is re-read and
+// updated conditionally on its ETag so that changes made since app was read,
+// for example while a deployment was running, are not overwritten.
+func (c *controllerAPI) setAppRelease(app *ct.App, release *ct.Release) error {
+	for {
+		current, err := c.store.GetApp(app.ID)
+		if err != nil {
+			return err
+		}
+		var prev *ct.Release
+		if current.ReleaseID != "" {
+			prev, _ = c.store.GetRelease(current.ReleaseID)
+		}
+		etag := current.ETag()
+		current.ReleaseID = release.ID
+		now := time.Now()
+		current.UpdatedAt = &now
+		if err := c.store.UpdateApp(current, etag); err == ct.ErrPreconditionFailed {
+			continue
+		} else if err != nil {
+			return err
+		}
+		*app = *current
+		return c.emit(app.ID, ct.EventTypeAppRelease, release.ID, &ct.AppRelease{
+			PrevRelease: prev,
+			Release:     release,
+		})
+	}
+}
+
+func (c *controllerAPI) GetAppRelease(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	if app.ReleaseID == "" {
+		respondWithError(w, ErrNotFound)
+		return
+	}
+	release, err := c.store.GetRelease(app.ReleaseID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, release)
+}
+
+func (c *controllerAPI) DeleteRelease(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	release, err := c.store.GetRelease(params(ctx).ByName("releases_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if release.AppID != app.ID {
+		respondWithError(w, ErrNotFound)
+		return
+	}
+	if app.ReleaseID == release.ID {
+		respondWithError(w, ct.ValidationError{Message: "cannot delete current app release"})
+		return
+	}
+	if f, err := c.store.GetFormation(app.ID, release.ID); err == nil && processCount(f.Processes) > 0 {
+		respondWithError(w, ct.ValidationError{Message: "cannot delete release with running processes"})
+		return
+	}
+
+	go func() {
+		event := &ct.ReleaseDeletionEvent{}
+		deletion, err := c.deleteRelease(release)
+		if err != nil {
+			event.Error = err.Error()
+		}
+		event.ReleaseDeletion = deletion
+		c.emit(app.ID, ct.EventTypeReleaseDeletion, release.ID, event)
+	}()
+	w.WriteHeader(200)
+}
+
+func (c *controllerAPI) deleteRelease(release *ct.Release) (*ct.ReleaseDeletion, error) {
+	deletion := &ct.ReleaseDeletion{
+		AppID:     release.AppID,
+		ReleaseID: release.ID,
+	}
+	c.store.DeleteFormation(release.AppID, release.ID)
+	if err := c.store.DeleteRelease(release.ID); err != nil {
+		return nil, err
+	}
+
+	// report any artifacts no longer referenced by a release
+	releases, err := c.store.ListReleases("")
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool)
+	for _, r := range releases {
+		for _, id := range r.ArtifactIDs {
+			referenced[id] = true
+		}
+	}
+	for _, id := range release.ArtifactIDs {
+		if referenced[id] {
+			continue
+		}
+		if artifact, err := c.store.GetArtifact(id); err == nil {
+			deletion.DeletedFiles = append(deletion.DeletedFiles, artifact.URI)
+		}
+	}
+	return deletion, nil
+}
+
+type releaseID struct {
+	ID string `json:"id"`
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/random"
+	"weo/pkg/resource"
+)
+
+func (c *controllerAPI) CreateProvider(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var provider ct.Provider
+	if err := httphelper.DecodeJSON(req, &provider); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if provider.Name == "" {
+		respondWithError(w, ct.ValidationError{Field: "name", Message: "must not be blank"})
+		return
+	}
+	if u, err := url.Parse(provider.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		respondWithError(w, ct.ValidationError{Field: "url", Message: "must be a valid URL"})
+		return
+	}
+	if provider.ID == "" {
+		provider.ID = random.UUID()
+	}
+	now := time.Now()
+	provider.CreatedAt = &now
+	provider.UpdatedAt = &now
+
+	if err := c.store.CreateProvider(&provider); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit("", ct.EventTypeProvider, provider.ID, &provider)
+	httphelper.JSON(w, 200, &provider)
+}
+
+func (c *controllerAPI) ListProviders(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListProviders()
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) GetProvider(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	provider, err := c.store.GetProvider(params(ctx).ByName("providers_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, provider)
+}
+
+// ProvisionResource asks the provider to provision a new resource and records
+// it along with the apps it is attached to.
+func (c *controllerAPI) ProvisionResource(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	provider, err := c.store.GetProvider(params(ctx).ByName("providers_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	var rr ct.ResourceReq
+	if err := httphelper.DecodeJSON(req, &rr); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	apps, err := c.resolveApps(rr.Apps)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	config := []byte("{}")
+	if rr.Config != nil {
+		config = *rr.Config
+	}
+	res, err := resource.Provision(provider.URL, config)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	now := time.Now()
+	r := &ct.Resource{
+		ID:         random.UUID(),
+		ProviderID: provider.ID,
+		ExternalID: res.ID,
+		Env:        res.Env,
+		Apps:       apps,
+		CreatedAt:  &now,
+	}
+	if err := c.store.PutResource(r); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emitResource(r)
+	httphelper.JSON(w, 200, r)
+}
+
+// resolveApps converts a list of app IDs or names to app IDs.
+func (c *controllerAPI) resolveApps(ids []string) ([]string, error) {
+	apps := make([]string, 0, len(ids))
+	for _, id := range ids {
+		app, err := c.store.GetApp(id)
+		if err != nil {
+			if err == ErrNotFound {
+				err = ct.ValidationError{Field: "apps", Message: "app " + id + " does not exist"}
+			}
+			return nil, err
+		}
+		apps = append(apps, app.ID)
+	}
+	return apps, nil
+}
+
+func (c *controllerAPI) emitResource(r *ct.Resource) {
+	for _, appID := range r.Apps {
+		c.emit(appID, ct.EventTypeResource, r.ID, r)
+	}
+	if len(r.Apps) == 0 {
+		c.emit("", ct.EventTypeResource, r.ID, r)
+	}
+}
+
+func (c *controllerAPI) GetResources(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListResources()
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) GetProviderResources(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	provider, err := c.store.GetProvider(params(ctx).ByName("providers_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	resources, err := c.store.ListResources()
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	list := make([]*ct.Resource, 0, len(resources))
+	for _, r := range resources {
+		if r.ProviderID == provider.ID {
+			list = append(list, r)
+		}
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+// lookupResource returns the resource identified by the providers_id and
+// resources_id parameters.
+func (c *controllerAPI) lookupResource(ctx context.Context) (*ct.Resource, error) {
+	provider, err := c.store.GetProvider(params(ctx).ByName("providers_id"))
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.store.GetResource(params(ctx).ByName("resources_id"))
+	if err != nil {
+		return nil, err
+	}
+	if r.ProviderID != provider.ID {
+		return nil, ErrNotFound
+	}
+	return r, nil
+}
+
+func (c *controllerAPI) GetResource(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	r, err := c.lookupResource(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, r)
+}
+
+// PutResource records a resource which was provisioned outside of the
+// controller.
+func (c *controllerAPI) PutResource(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	provider, err := c.store.GetProvider(params(ctx).ByName("providers_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	var r ct.Resource
+	if err := httphelper.DecodeJSON(req, &r); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	r.ID = params(ctx).ByName("resources_id")
+	r.ProviderID = provider.ID
+	if r.Apps, err = c.resolveApps(r.Apps); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if existing, err := c.store.GetResource(r.ID); err == nil {
+		r.CreatedAt = existing.CreatedAt
+	} else if err != ErrNotFound {
+		respondWithError(w, err)
+		return
+	}
+	if r.CreatedAt == nil {
+		now := time.Now()
+		r.CreatedAt = &now
+	}
+	if err := c.store.PutResource(&r); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emitResource(&r)
+	httphelper.JSON(w, 200, &r)
+}
+
+func (c *controllerAPI) DeleteResource(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	r, err := c.lookupResource(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.deprovision(r); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, r)
+}
+
+// deprovision asks the provider to deprovision the resource before deleting
+// it.
+func (c *controllerAPI) deprovision(r *ct.Resource) error {
+	provider, err := c.store.GetProvider(r.ProviderID)
+	if err != nil {
+		return err
+	}
+	if err := resource.Deprovision(provider.URL, r.ExternalID); err != nil {
+		return err
+	}
+	if err := c.store.DeleteResource(r.ID); err != nil {
+		return err
+	}
+	for _, appID := range r.Apps {
+		c.emit(appID, ct.EventTypeResourceDeletion, r.ID, r)
+	}
+	if len(r.Apps) == 0 {
+		c.emit("", ct.EventTypeResourceDeletion, r.ID, r)
+	}
+	return nil
+}
+
+func (c *controllerAPI) AddResourceApp(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	r, err := c.lookupResource(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	app, err := c.store.GetApp(params(ctx).ByName("app_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	r.Apps = append(removeString(r.Apps, app.ID), app.ID)
+	if err := c.store.PutResource(r); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit(app.ID, ct.EventTypeResource, r.ID, r)
+	httphelper.JSON(w, 200, r)
+}
+
+func (c *controllerAPI) DeleteResourceApp(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	r, err := c.lookupResource(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	app, err := c.store.GetApp(params(ctx).ByName("app_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	r.Apps = removeString(r.Apps, app.ID)
+	if err := c.store.PutResource(r); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit(app.ID, ct.EventTypeResourceAppDeletion, r.ID, r)
+	httphelper.JSON(w, 200, r)
+}
+
+func (c *controllerAPI) GetAppResources(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.appResources(c.getApp(ctx).ID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) appResources(appID string) ([]*ct.Resource, error) {
+	resources, err := c.store.ListResources()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*ct.Resource, 0, len(resources))
+	for _, r := range resources {
+		for _, id := range r.Apps {
+			if id == appID {
+				list = append(list, r)
+				break
+			}
+		}
+	}
+	return list, nil
+}
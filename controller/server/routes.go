@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"errors"
+	router "github.com/flynn/flynn/router/types"
+	"net/http"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/random"
+)
+
+// tcpPortRange is the range of ports allocated to TCP routes which are
+// created without a port
+var tcpPortRange = [2]int32{3000, 3500}
+
+var errNoPorts = errors.New("controller: no TCP route ports available")
+
+func (c *controllerAPI) GetRouteList(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.store.ListRoutes()
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) GetAppRouteList(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	list, err := c.appRoutes(c.getApp(ctx).ID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) appRoutes(appID string) ([]*router.Route, error) {
+	routes, err := c.store.ListRoutes()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*router.Route, 0, len(routes))
+	for _, route := range routes {
+		if route.ParentRef == ct.RouteParentRefPrefix+appID {
+			list = append(list, route)
+		}
+	}
+	return list, nil
+}
+
+func (c *controllerAPI) CreateRoute(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var route router.Route
+	if err := httphelper.DecodeJSON(req, &route); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.createRoute(c.getApp(ctx), &route); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, &route)
+}
+
+func (c *controllerAPI) createRoute(app *ct.App, route *router.Route) error {
+	route.ID = random.UUID()
+	route.ParentRef = ct.RouteParentRefPrefix + app.ID
+	if err := validateRoute(route); err != nil {
+		return err
+	}
+	if route.Type == "tcp" && route.Port == 0 {
+		port, err := c.allocatePort()
+		if err != nil {
+			return err
+		}
+		route.Port = port
+	}
+	now := time.Now()
+	route.CreatedAt = now
+	route.UpdatedAt = now
+	if err := c.store.PutRoute(route); err != nil {
+		return err
+	}
+	return c.emit(app.ID, ct.EventTypeRoute, route.FormattedID(), route)
+}
+
+func validateRoute(route *router.Route) error {
+	if route.Service == "" {
+		return ct.ValidationError{Field: "service", Message: "must not be blank"}
+	}
+	switch route.Type {
+	case "http":
+		if route.Domain == "" {
+			return ct.ValidationError{Field: "domain", Message: "must not be blank"}
+		}
+		if route.Path == "" {
+			route.Path = "/"
+		} else if route.Path[0] != '/' {
+			return ct.ValidationError{Field: "path", Message: "must start with a forward slash"}
+		}
+		if route.Port != 0 {
+			return ct.ValidationError{Field: "port", Message: "must be empty for HTTP routes"}
+		}
+	case "tcp":
+		if route.Domain != "" {
+			return ct.ValidationError{Field: "domain", Message: "must be empty for TCP routes"}
+		}
+		if route.Port < 0 || route.Port > 65535 {
+			return ct.ValidationError{Field: "port", Message: "must be between 0 and 65535"}
+		}
+	default:
+		return ct.ValidationError{Field: "type", Message: "must be either \"http\" or \"tcp\""}
+	}
+	return nil
+}
+
+func (c *controllerAPI) allocatePort() (int32, error) {
+	routes, err := c.store.ListRoutes()
+	if err != nil {
+		return 0, err
+	}
+	used := make(map[int32]bool)
+	for _, route := range routes {
+		if route.Type == "tcp" {
+			used[route.Port] = true
+		}
+	}
+	for port := tcpPortRange[0]; port <= tcpPortRange[1]; port++ {
+		if !used[port] {
+			return port, nil
+		}
+	}
+	return 0, errNoPorts
+}
+
+// lookupRoute returns the app route identified by the routes_type and
+// routes_id parameters.
+func (c *controllerAPI) lookupRoute(ctx context.Context) (*router.Route, error) {
+	route, err := c.store.GetRoute(params(ctx).ByName("routes_type"), params(ctx).ByName("routes_id"))
+	if err != nil {
+		return nil, err
+	}
+	if route.ParentRef != ct.RouteParentRefPrefix+c.getApp(ctx).ID {
+		return nil, ErrNotFound
+	}
+	return route, nil
+}
+
+func (c *controllerAPI) GetRoute(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	route, err := c.lookupRoute(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, route)
+}
+
+func (c *controllerAPI) UpdateRoute(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	existing, err := c.lookupRoute(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	var route router.Route
+	if err := httphelper.DecodeJSON(req, &route); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	route.Type = existing.Type
+	route.ID = existing.ID
+	route.ParentRef = existing.ParentRef
+	route.CreatedAt = existing.CreatedAt
+	route.UpdatedAt = time.Now()
+	if route.Type == "tcp" && route.Port == 0 {
+		route.Port = existing.Port
+	}
+	if err := validateRoute(&route); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.store.PutRoute(&route); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit(c.getApp(ctx).ID, ct.EventTypeRoute, route.FormattedID(), &route)
+	httphelper.JSON(w, 200, &route)
+}
+
+func (c *controllerAPI) DeleteRoute(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	route, err := c.lookupRoute(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.store.DeleteRoute(route.Type, route.ID); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit(c.getApp(ctx).ID, ct.EventTypeRouteDeletion, route.FormattedID(), route)
+	w.WriteHeader(200)
+}
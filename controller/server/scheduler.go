@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/flynn/flynn/host/volume"
+	logaggc "github.com/flynn/flynn/logaggregator/client"
+	logagg "github.com/flynn/flynn/logaggregator/types"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/random"
+)
+
+// localHostID is the host ID given to jobs started by the scheduler
+const localHostID = "local"
+
+// scheduler converges the jobs of each formation towards its process counts.
+// It does not run any processes, it only maintains the job and volume
+// records (and the events and log lines which go with them) so that the API
+// behaves like a real cluster.
+type scheduler struct {
+	api *controllerAPI
+	mtx sync.Mutex
+}
+
+func newScheduler(api *controllerAPI) *scheduler {
+	return &scheduler{api: api}
+}
+
+// Reconcile starts or stops jobs of the given app release until the number
+// of running jobs of each type matches processes, calling callback for every
+// job state change. It gives up with the context's error once ctx is done.
+func (s *scheduler) Reconcile(ctx context.Context, app *ct.App, releaseID string, processes map[string]int, callback func(*ct.Job)) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	release, err := s.api.store.GetRelease(releaseID)
+	if err != nil {
+		return err
+	}
+	jobs, err := s.api.store.ListJobs(app.ID)
+	if err != nil {
+		return err
+	}
+	running := make(map[string][]*ct.Job)
+	for _, job := range jobs {
+		if job.ReleaseID != releaseID || job.IsDown() || job.State == ct.JobStateStopping {
+			continue
+		}
+		running[job.Type] = append(running[job.Type], job)
+	}
+
+	types := make(map[string]struct{}, len(processes)+len(running))
+	for typ := range processes {
+		types[typ] = struct{}{}
+	}
+	for typ := range running {
+		types[typ] = struct{}{}
+	}
+	sorted := make([]string, 0, len(types))
+	for typ := range types {
+		sorted = append(sorted, typ)
+	}
+	sort.Strings(sorted)
+
+	for _, typ := range sorted {
+		diff := processes[typ] - len(running[typ])
+		for i := 0; i < diff; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.startJob(app, release, typ, callback); err != nil {
+				return err
+			}
+		}
+		// running jobs are sorted newest first, so stop the newest jobs
+		for i := 0; i < -diff; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.StopJob(running[typ][i], callback); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *scheduler) startJob(app *ct.App, release *ct.Release, typ string, callback func(*ct.Job)) error {
+	proc, ok := release.Processes[typ]
+	if !ok {
+		return ct.ValidationError{Field: "processes", Message: fmt.Sprintf("release has no process type %q", typ)}
+	}
+	now := time.Now()
+	uuid := random.UUID()
+	job := &ct.Job{
+		ID:        localHostID + "-" + uuid,
+		UUID:      uuid,
+		HostID:    localHostID,
+		AppID:     app.ID,
+		ReleaseID: release.ID,
+		Type:      typ,
+		State:     ct.JobStateStarting,
+		Args:      proc.Args,
+		Meta:      map[string]string{"weo-controller.app_name": app.Name},
+		CreatedAt: &now,
+	}
+	for _, req := range proc.Volumes {
+		vol, err := s.createVolume(job, req)
+		if err != nil {
+			return err
+		}
+		job.VolumeIDs = append(job.VolumeIDs, vol.ID)
+	}
+	if err := s.api.putJob(job, callback); err != nil {
+		return err
+	}
+	s.api.logs.Add(app.ID, job, fmt.Sprintf("Starting process with command %q", strings.Join(proc.Args, " ")))
+	job.State = ct.JobStateUp
+	return s.api.putJob(job, callback)
+}
+
+// StopJob moves the job through the stopping and down states, destroying any
+// volumes which should not outlive it.
+func (s *scheduler) StopJob(job *ct.Job, callback func(*ct.Job)) error {
+	if job.IsDown() {
+		return nil
+	}
+	job.State = ct.JobStateStopping
+	if err := s.api.putJob(job, callback); err != nil {
+		return err
+	}
+	s.api.logs.Add(job.AppID, job, "Stopping process")
+	exitStatus := int32(0)
+	job.State = ct.JobStateDown
+	job.ExitStatus = &exitStatus
+	if err := s.api.putJob(job, callback); err != nil {
+		return err
+	}
+	for _, id := range job.VolumeIDs {
+		vol, err := s.api.store.GetVolume(id)
+		if err != nil || !vol.DeleteOnStop {
+			continue
+		}
+		now := time.Now()
+		vol.State = ct.VolumeStateDestroyed
+		vol.UpdatedAt = &now
+		if err := s.api.putVolume(vol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *scheduler) createVolume(job *ct.Job, req ct.VolumeReq) (*ct.Volume, error) {
+	now := time.Now()
+	jobID := job.ID
+	vol := &ct.Volume{
+		VolumeReq: req,
+		ID:        random.UUID(),
+		HostID:    localHostID,
+		Type:      volume.VolumeTypeData,
+		State:     ct.VolumeStateCreated,
+		AppID:     job.AppID,
+		ReleaseID: job.ReleaseID,
+		JobID:     &jobID,
+		JobType:   job.Type,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	return vol, s.api.putVolume(vol)
+}
+
+// putJob saves the job and emits a job event.
+func (c *controllerAPI) putJob(job *ct.Job, callback func(*ct.Job)) error {
+	now := time.Now()
+	if job.CreatedAt == nil {
+		job.CreatedAt = &now
+	}
+	job.UpdatedAt = &now
+	if err := c.store.PutJob(job); err != nil {
+		return err
+	}
+	if err := c.emit(job.AppID, ct.EventTypeJob, job.UUID, job); err != nil {
+		return err
+	}
+	if callback != nil {
+		callback(job)
+	}
+	return nil
+}
+
+// logBuffer keeps the most recent log lines of each app in memory and fans
+// them out to any followers.
+type logBuffer struct {
+	mtx       sync.Mutex
+	messages  map[string][]*logaggc.Message
+	followers map[string]map[chan *logaggc.Message]struct{}
+}
+
+// logBufferSize is the number of lines retained for each app
+const logBufferSize = 10000
+
+func newLogBuffer() *logBuffer {
+	return &logBuffer{
+		messages:  make(map[string][]*logaggc.Message),
+		followers: make(map[string]map[chan *logaggc.Message]struct{}),
+	}
+}
+
+func (b *logBuffer) Add(appID string, job *ct.Job, line string) {
+	msg := &logaggc.Message{
+		HostID:      job.HostID,
+		JobID:       job.ID,
+		Msg:         line,
+		ProcessType: job.Type,
+		Source:      "app",
+		Stream:      logagg.StreamTypeStdout,
+		Timestamp:   time.Now().UTC(),
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	msgs := append(b.messages[appID], msg)
+	if len(msgs) > logBufferSize {
+		msgs = msgs[len(msgs)-logBufferSize:]
+	}
+	b.messages[appID] = msgs
+	for ch := range b.followers[appID] {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// Read returns the buffered messages of the app, subscribing ch to new
+// messages when it is not nil.
+func (b *logBuffer) Read(appID string, ch chan *logaggc.Message) []*logaggc.Message {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	msgs := make([]*logaggc.Message, len(b.messages[appID]))
+	copy(msgs, b.messages[appID])
+	if ch != nil {
+		if _, ok := b.followers[appID]; !ok {
+			b.followers[appID] = make(map[chan *logaggc.Message]struct{})
+		}
+		b.followers[appID][ch] = struct{}{}
+	}
+	return msgs
+}
+
+func (b *logBuffer) Unfollow(appID string, ch chan *logaggc.Message) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	delete(b.followers[appID], ch)
+}
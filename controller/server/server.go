@@ -0,0 +1,249 @@
+// Package server implements the controller HTTP API consumed by
+// controller/client on top of a pluggable Store.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+	"sync"
+	ct "weo/controller/types"
+	"weo/pkg/ctxhelper"
+	"weo/pkg/httphelper"
+	"weo/pkg/shutdown"
+	"weo/pkg/status"
+)
+
+var ErrNotFound = ct.ErrNotFound
+var ErrShutdown = errors.New("controller: shutting down")
+
+type Config struct {
+	Store Store
+
+	// Keys are the auth keys accepted by the API, if empty then requests
+	// are not authenticated.
+	Keys []string
+
+	// CACert is served from /ca-cert so clients can pin the controller's
+	// TLS certificate.
+	CACert []byte
+
+	// DefaultRouteDomain is used to add a default HTTP route of
+	// <app>.<domain> to newly created apps when set.
+	DefaultRouteDomain string
+}
+
+type controllerAPI struct {
+	store     Store
+	config    Config
+	events    *eventBroker
+	logs      *logBuffer
+	scheduler *scheduler
+
+	backupMtx  sync.Mutex
+	lastBackup *ct.ClusterBackup
+
+	// deployMtx serializes creating deployments so that only one deployment
+	// per app runs at a time
+	deployMtx sync.Mutex
+}
+
+type ctxKey int
+
+const ctxKeyApp ctxKey = iota
+
+// NewHandler returns a http.Handler serving the controller API.
+func NewHandler(c Config) http.Handler {
+	api := &controllerAPI{
+		store:  c.Store,
+		config: c,
+		events: newEventBroker(),
+		logs:   newLogBuffer(),
+	}
+	api.scheduler = newScheduler(api)
+
+	r := httprouter.New()
+
+	r.Handler("GET", status.Path, status.Handler(func() status.Status {
+		if err := api.store.Ping(); err != nil {
+			return status.Unhealthy
+		}
+		return status.Healthy
+	}))
+
+	r.GET("/ca-cert", httphelper.WrapHandler(api.GetCACert))
+	r.GET("/backup", httphelper.WrapHandler(api.GetBackup))
+	r.PUT("/domain", httphelper.WrapHandler(api.MigrateDomain))
+
+	r.POST("/apps", httphelper.WrapHandler(api.CreateApp))
+	r.GET("/apps", httphelper.WrapHandler(api.ListApps))
+	r.GET("/apps/:apps_id", httphelper.WrapHandler(api.appLookup(api.GetApp)))
+	r.POST("/apps/:apps_id", httphelper.WrapHandler(api.appLookup(api.UpdateApp)))
+	r.POST("/apps/:apps_id/meta", httphelper.WrapHandler(api.appLookup(api.UpdateAppMeta)))
+	r.DELETE("/apps/:apps_id", httphelper.WrapHandler(api.appLookup(api.DeleteApp)))
+	r.GET("/apps/:apps_id/log", httphelper.WrapHandler(api.appLookup(api.AppLog)))
+	r.POST("/apps/:apps_id/gc", httphelper.WrapHandler(api.appLookup(api.ScheduleAppGarbageCollection)))
+
+	r.POST("/artifacts", httphelper.WrapHandler(api.CreateArtifact))
+	r.GET("/artifacts", httphelper.WrapHandler(api.ListArtifacts))
+	r.GET("/artifacts/:artifacts_id", httphelper.WrapHandler(api.GetArtifact))
+
+	r.POST("/releases", httphelper.WrapHandler(api.CreateRelease))
+	r.GET("/releases", httphelper.WrapHandler(api.ListReleases))
+	r.GET("/releases/:releases_id", httphelper.WrapHandler(api.GetRelease))
+	r.PUT("/apps/:apps_id/release", httphelper.WrapHandler(api.appLookup(api.SetAppRelease)))
+	r.GET("/apps/:apps_id/release", httphelper.WrapHandler(api.appLookup(api.GetAppRelease)))
+	r.GET("/apps/:apps_id/releases", httphelper.WrapHandler(api.appLookup(api.GetAppReleases)))
+	r.DELETE("/apps/:apps_id/releases/:releases_id", httphelper.WrapHandler(api.appLookup(api.DeleteRelease)))
+
+	r.PUT("/apps/:apps_id/formations/:releases_id", httphelper.WrapHandler(api.appLookup(api.PutFormation)))
+	r.GET("/apps/:apps_id/formations/:releases_id", httphelper.WrapHandler(api.appLookup(api.GetFormation)))
+	r.DELETE("/apps/:apps_id/formations/:releases_id", httphelper.WrapHandler(api.appLookup(api.DeleteFormation)))
+	r.GET("/apps/:apps_id/formations", httphelper.WrapHandler(api.appLookup(api.ListFormations)))
+	r.GET("/formations", httphelper.WrapHandler(api.GetFormations))
+	r.PUT("/apps/:apps_id/scale/:releases_id", httphelper.WrapHandler(api.appLookup(api.PutScaleRequest)))
+
+	r.POST("/apps/:apps_id/jobs", httphelper.WrapHandler(api.appLookup(api.RunJob)))
+	r.GET("/apps/:apps_id/jobs", httphelper.WrapHandler(api.appLookup(api.ListJobs)))
+	r.GET("/apps/:apps_id/jobs/:jobs_id", httphelper.WrapHandler(api.appLookup(api.GetJob)))
+	r.PUT("/apps/:apps_id/jobs/:jobs_id", httphelper.WrapHandler(api.appLookup(api.PutJob)))
+	r.DELETE("/apps/:apps_id/jobs/:jobs_id", httphelper.WrapHandler(api.appLookup(api.KillJob)))
+	r.GET("/active-jobs", httphelper.WrapHandler(api.ListActiveJobs))
+
+	r.POST("/apps/:apps_id/deploy", httphelper.WrapHandler(api.appLookup(api.CreateDeployment)))
+	r.GET("/apps/:apps_id/deployments", httphelper.WrapHandler(api.appLookup(api.ListDeployments)))
+	r.GET("/deployments/:deployments_id", httphelper.WrapHandler(api.GetDeployment))
+
+	r.POST("/providers", httphelper.WrapHandler(api.CreateProvider))
+	r.GET("/providers", httphelper.WrapHandler(api.ListProviders))
+	r.GET("/providers/:providers_id", httphelper.WrapHandler(api.GetProvider))
+	r.GET("/resources", httphelper.WrapHandler(api.GetResources))
+	r.POST("/providers/:providers_id/resources", httphelper.WrapHandler(api.ProvisionResource))
+	r.GET("/providers/:providers_id/resources", httphelper.WrapHandler(api.GetProviderResources))
+	r.GET("/providers/:providers_id/resources/:resources_id", httphelper.WrapHandler(api.GetResource))
+	r.PUT("/providers/:providers_id/resources/:resources_id", httphelper.WrapHandler(api.PutResource))
+	r.DELETE("/providers/:providers_id/resources/:resources_id", httphelper.WrapHandler(api.DeleteResource))
+	r.PUT("/providers/:providers_id/resources/:resources_id/apps/:app_id", httphelper.WrapHandler(api.AddResourceApp))
+	r.DELETE("/providers/:providers_id/resources/:resources_id/apps/:app_id", httphelper.WrapHandler(api.DeleteResourceApp))
+	r.GET("/apps/:apps_id/resources", httphelper.WrapHandler(api.appLookup(api.GetAppResources)))
+
+	r.GET("/routes", httphelper.WrapHandler(api.GetRouteList))
+	r.POST("/apps/:apps_id/routes", httphelper.WrapHandler(api.appLookup(api.CreateRoute)))
+	r.GET("/apps/:apps_id/routes", httphelper.WrapHandler(api.appLookup(api.GetAppRouteList)))
+	r.GET("/apps/:apps_id/routes/:routes_type/:routes_id", httphelper.WrapHandler(api.appLookup(api.GetRoute)))
+	r.PUT("/apps/:apps_id/routes/:routes_type/:routes_id", httphelper.WrapHandler(api.appLookup(api.UpdateRoute)))
+	r.DELETE("/apps/:apps_id/routes/:routes_type/:routes_id", httphelper.WrapHandler(api.appLookup(api.DeleteRoute)))
+
+	r.GET("/events", httphelper.WrapHandler(api.Events))
+	r.GET("/events/:id", httphelper.WrapHandler(api.GetEvent))
+
+	r.GET("/volumes", httphelper.WrapHandler(api.GetVolumes))
+	r.PUT("/volumes/:volume_id", httphelper.WrapHandler(api.PutVolume))
+	r.GET("/apps/:apps_id/volumes", httphelper.WrapHandler(api.appLookup(api.GetAppVolumes)))
+	r.GET("/apps/:apps_id/volumes/:volume_id", httphelper.WrapHandler(api.appLookup(api.GetVolume)))
+	r.PUT("/apps/:apps_id/volumes/:volume_id/decommission", httphelper.WrapHandler(api.appLookup(api.DecommissionVolume)))
+
+	r.POST("/sinks", httphelper.WrapHandler(api.CreateSink))
+	r.GET("/sinks", httphelper.WrapHandler(api.GetSinks))
+	r.GET("/sinks/:sink_id", httphelper.WrapHandler(api.GetSink))
+	r.DELETE("/sinks/:sink_id", httphelper.WrapHandler(api.DeleteSink))
+
+	handler := httphelper.NewRequestLogger(api.authHandler(r))
+	return httphelper.ContextInjector("controller", httphelper.CORSAllowAll.Handler(handler))
+}
+
+func (c *controllerAPI) authHandler(main http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shutdown.IsActive() {
+			httphelper.ServiceUnavailableError(w, ErrShutdown.Error())
+			return
+		}
+		if r.URL.Path == "/ping" {
+			w.WriteHeader(200)
+			return
+		}
+		_, password, _ := r.BasicAuth()
+		if password == "" && (r.URL.Path == "/ca-cert" || r.URL.Path == status.Path) {
+			main.ServeHTTP(w, r)
+			return
+		}
+		if password == "" && (strings.Contains(r.Header.Get("Accept"), "text/event-stream") || r.URL.Path == "/backup") {
+			password = r.URL.Query().Get("key")
+		}
+		if !c.authorized(password) {
+			w.WriteHeader(401)
+			return
+		}
+		main.ServeHTTP(w, r)
+	})
+}
+
+func (c *controllerAPI) authorized(key string) bool {
+	if len(c.config.Keys) == 0 {
+		return true
+	}
+	for _, k := range c.config.Keys {
+		if k != "" && subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func respondWithError(w http.ResponseWriter, err error) {
+	switch v := err.(type) {
+	case ct.ValidationError:
+		httphelper.ValidationError(w, v.Field, v.Message)
+	default:
+		switch err {
+		case ErrNotFound:
+			w.WriteHeader(404)
+			return
+		case ct.ErrPreconditionFailed:
+			err = httphelper.PreconditionFailedErr(err.Error())
+		}
+		httphelper.Error(w, err)
+	}
+}
+
+// ifMatch returns the ETag from the If-Match header of the request, or an
+// empty string if the request is unconditional.
+func ifMatch(req *http.Request) string {
+	if etag := req.Header.Get("If-Match"); etag != "*" {
+		return etag
+	}
+	return ""
+}
+
+func params(ctx context.Context) httprouter.Params {
+	params, _ := ctxhelper.ParamsFromContext(ctx)
+	return params
+}
+
+func (c *controllerAPI) getApp(ctx context.Context) *ct.App {
+	return ctx.Value(ctxKeyApp).(*ct.App)
+}
+
+func (c *controllerAPI) appLookup(handler httphelper.HandlerFunc) httphelper.HandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+		app, err := c.store.GetApp(params(ctx).ByName("apps_id"))
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		ctx = context.WithValue(ctx, ctxKeyApp, app)
+		handler(ctx, w, req)
+	}
+}
+
+func (c *controllerAPI) GetCACert(_ context.Context, w http.ResponseWriter, _ *http.Request) {
+	if len(c.config.CACert) == 0 {
+		respondWithError(w, ErrNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+	w.Write(c.config.CACert)
+}
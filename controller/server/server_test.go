@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+	controller "weo/controller/client"
+	ct "weo/controller/types"
+)
+
+func newTestClient(t *testing.T) (controller.Client, func()) {
+	srv := httptest.NewServer(NewHandler(Config{Store: NewMemoryStore()}))
+	client, err := controller.NewClient(srv.URL, "")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return client, srv.Close
+}
+
+// createDeployedApp creates an app running a release with two web jobs and
+// one worker job
+func createDeployedApp(t *testing.T, client controller.Client) (*ct.App, *ct.Release) {
+	app := &ct.App{Name: "deploy-test"}
+	if err := client.CreateApp(app); err != nil {
+		t.Fatal(err)
+	}
+	release := createRelease(t, client, app, "")
+	if err := client.SetAppRelease(app.ID, release.ID); err != nil {
+		t.Fatal(err)
+	}
+	formation := &ct.Formation{
+		AppID:     app.ID,
+		ReleaseID: release.ID,
+		Processes: map[string]int{"web": 2, "worker": 1},
+	}
+	if err := client.PutFormation(formation); err != nil {
+		t.Fatal(err)
+	}
+	waitForJobs(t, client, app.ID, release.ID, 3)
+	return app, release
+}
+
+func createRelease(t *testing.T, client controller.Client, app *ct.App, webService string) *ct.Release {
+	release := &ct.Release{
+		AppID: app.ID,
+		Processes: map[string]ct.ProcessType{
+			"web":    {Args: []string{"web"}, Service: webService},
+			"worker": {Args: []string{"worker"}},
+		},
+	}
+	if err := client.CreateRelease(app.ID, release); err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+func waitForJobs(t *testing.T, client controller.Client, appID, releaseID string, count int) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		jobs, err := client.JobList(appID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up := 0
+		for _, job := range jobs {
+			if job.ReleaseID == releaseID && job.State == ct.JobStateUp {
+				up++
+			}
+		}
+		if up == count {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d jobs of release %s", count, releaseID)
+}
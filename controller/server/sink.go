@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+	"weo/pkg/random"
+)
+
+func (c *controllerAPI) CreateSink(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var sink ct.Sink
+	if err := httphelper.DecodeJSON(req, &sink); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	switch sink.Kind {
+	case ct.SinkKindSyslog, ct.SinkKindLogaggregator:
+	default:
+		respondWithError(w, ct.ValidationError{Field: "kind", Message: "must be either \"syslog\" or \"logaggregator\""})
+		return
+	}
+	if sink.Config == nil {
+		respondWithError(w, ct.ValidationError{Field: "config", Message: "must not be empty"})
+		return
+	}
+	if sink.ID == "" {
+		sink.ID = random.UUID()
+	}
+	now := time.Now()
+	sink.CreatedAt = &now
+	sink.UpdatedAt = &now
+
+	if err := c.store.CreateSink(&sink); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit("", ct.EventTypeSink, sink.ID, &sink)
+	httphelper.JSON(w, 200, &sink)
+}
+
+// GetSinks returns all sinks when requested as JSON, and otherwise streams
+// sinks as they are created and deleted.
+func (c *controllerAPI) GetSinks(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		list, err := c.store.ListSinks()
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		httphelper.JSON(w, 200, list)
+		return
+	}
+
+	list := func(since time.Time) ([]interface{}, error) {
+		sinks, err := c.store.ListSinks()
+		if err != nil {
+			return nil, err
+		}
+		res := make([]interface{}, 0, len(sinks))
+		for _, sink := range sinks {
+			if sink.UpdatedAt != nil && sink.UpdatedAt.Before(since) {
+				continue
+			}
+			res = append(res, sink)
+		}
+		return res, nil
+	}
+	convert := func(event *ct.Event) (interface{}, error) {
+		var sink ct.Sink
+		if err := json.Unmarshal(event.Data, &sink); err != nil {
+			return nil, err
+		}
+		return &sink, nil
+	}
+	types := []ct.EventType{ct.EventTypeSink, ct.EventTypeSinkDeletion}
+	c.streamObjects(ctx, w, req, types, list, convert, &ct.Sink{})
+}
+
+func (c *controllerAPI) GetSink(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	sink, err := c.store.GetSink(params(ctx).ByName("sink_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, sink)
+}
+
+func (c *controllerAPI) DeleteSink(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	sink, err := c.store.GetSink(params(ctx).ByName("sink_id"))
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.store.DeleteSink(sink.ID); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.emit("", ct.EventTypeSinkDeletion, sink.ID, sink)
+	httphelper.JSON(w, 200, sink)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	router "github.com/flynn/flynn/router/types"
+	ct "weo/controller/types"
+)
+
+// Store is the storage backend of the controller API. Lookups of missing
+// objects must return ct.ErrNotFound.
+//
+// Updates which take an ifMatch argument are conditional: when ifMatch is
+// not empty and differs from the ETag of the stored object, the update must
+// fail with ct.ErrPreconditionFailed.
+type Store interface {
+	CreateApp(app *ct.App) error
+	// GetApp looks up an app by either its ID or its name.
+	GetApp(id string) (*ct.App, error)
+	ListApps() ([]*ct.App, error)
+	UpdateApp(app *ct.App, ifMatch string) error
+	DeleteApp(id string) error
+
+	CreateArtifact(artifact *ct.Artifact) error
+	GetArtifact(id string) (*ct.Artifact, error)
+	ListArtifacts() ([]*ct.Artifact, error)
+
+	CreateRelease(release *ct.Release) error
+	GetRelease(id string) (*ct.Release, error)
+	// ListReleases returns all releases when appID is empty.
+	ListReleases(appID string) ([]*ct.Release, error)
+	DeleteRelease(id string) error
+
+	PutFormation(formation *ct.Formation, ifMatch string) error
+	GetFormation(appID, releaseID string) (*ct.Formation, error)
+	// ListFormations returns all formations when appID is empty.
+	ListFormations(appID string) ([]*ct.Formation, error)
+	DeleteFormation(appID, releaseID string) error
+
+	PutJob(job *ct.Job) error
+	GetJob(uuid string) (*ct.Job, error)
+	// ListJobs returns all jobs when appID is empty.
+	ListJobs(appID string) ([]*ct.Job, error)
+
+	PutDeployment(deployment *ct.Deployment) error
+	GetDeployment(id string) (*ct.Deployment, error)
+	ListDeployments(appID string) ([]*ct.Deployment, error)
+
+	CreateProvider(provider *ct.Provider) error
+	// GetProvider looks up a provider by either its ID or its name.
+	GetProvider(id string) (*ct.Provider, error)
+	ListProviders() ([]*ct.Provider, error)
+
+	PutResource(resource *ct.Resource) error
+	GetResource(id string) (*ct.Resource, error)
+	ListResources() ([]*ct.Resource, error)
+	DeleteResource(id string) error
+
+	PutRoute(route *router.Route) error
+	GetRoute(typ, id string) (*router.Route, error)
+	ListRoutes() ([]*router.Route, error)
+	DeleteRoute(typ, id string) error
+
+	PutVolume(volume *ct.Volume) error
+	GetVolume(id string) (*ct.Volume, error)
+	ListVolumes() ([]*ct.Volume, error)
+
+	CreateSink(sink *ct.Sink) error
+	GetSink(id string) (*ct.Sink, error)
+	ListSinks() ([]*ct.Sink, error)
+	DeleteSink(id string) error
+
+	// AddEvent assigns the next sequential ID to the event.
+	AddEvent(event *ct.Event) error
+	GetEvent(id int64) (*ct.Event, error)
+	// ListEvents returns matching events in descending ID order.
+	ListEvents(opts ct.ListEventsOptions) ([]*ct.Event, error)
+
+	Ping() error
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	ct "weo/controller/types"
+	"weo/pkg/httphelper"
+)
+
+// GetVolumes returns all volumes when requested as JSON, and otherwise
+// streams volumes as they are updated.
+func (c *controllerAPI) GetVolumes(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		list, err := c.store.ListVolumes()
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		httphelper.JSON(w, 200, list)
+		return
+	}
+
+	list := func(since time.Time) ([]interface{}, error) {
+		volumes, err := c.store.ListVolumes()
+		if err != nil {
+			return nil, err
+		}
+		res := make([]interface{}, 0, len(volumes))
+		for _, vol := range volumes {
+			if vol.UpdatedAt != nil && vol.UpdatedAt.Before(since) {
+				continue
+			}
+			res = append(res, vol)
+		}
+		return res, nil
+	}
+	convert := func(event *ct.Event) (interface{}, error) {
+		var vol ct.Volume
+		if err := json.Unmarshal(event.Data, &vol); err != nil {
+			return nil, err
+		}
+		return &vol, nil
+	}
+	c.streamObjects(ctx, w, req, []ct.EventType{ct.EventTypeVolume}, list, convert, &ct.Volume{})
+}
+
+func (c *controllerAPI) GetAppVolumes(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	app := c.getApp(ctx)
+	volumes, err := c.store.ListVolumes()
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	list := make([]*ct.Volume, 0, len(volumes))
+	for _, vol := range volumes {
+		if vol.AppID == app.ID {
+			list = append(list, vol)
+		}
+	}
+	httphelper.JSON(w, 200, list)
+}
+
+func (c *controllerAPI) GetVolume(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	vol, err := c.lookupVolume(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, vol)
+}
+
+func (c *controllerAPI) lookupVolume(ctx context.Context) (*ct.Volume, error) {
+	vol, err := c.store.GetVolume(params(ctx).ByName("volume_id"))
+	if err != nil {
+		return nil, err
+	}
+	if vol.AppID != c.getApp(ctx).ID {
+		return nil, ErrNotFound
+	}
+	return vol, nil
+}
+
+func (c *controllerAPI) PutVolume(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var vol ct.Volume
+	if err := httphelper.DecodeJSON(req, &vol); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	vol.ID = params(ctx).ByName("volume_id")
+	if vol.AppID == "" {
+		respondWithError(w, ct.ValidationError{Field: "app", Message: "must not be blank"})
+		return
+	}
+	if existing, err := c.store.GetVolume(vol.ID); err == nil {
+		vol.CreatedAt = existing.CreatedAt
+	} else if err != ErrNotFound {
+		respondWithError(w, err)
+		return
+	}
+	now := time.Now()
+	if vol.CreatedAt == nil {
+		vol.CreatedAt = &now
+	}
+	vol.UpdatedAt = &now
+	if err := c.putVolume(&vol); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, &vol)
+}
+
+// DecommissionVolume marks the volume as decommissioned so that it is not
+// used by any new jobs.
+func (c *controllerAPI) DecommissionVolume(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	vol, err := c.lookupVolume(ctx)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	now := time.Now()
+	if vol.DecommissionedAt == nil {
+		vol.DecommissionedAt = &now
+	}
+	vol.UpdatedAt = &now
+	if err := c.putVolume(vol); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	httphelper.JSON(w, 200, vol)
+}
+
+// putVolume saves the volume and emits a volume event.
+func (c *controllerAPI) putVolume(vol *ct.Volume) error {
+	if err := c.store.PutVolume(vol); err != nil {
+		return err
+	}
+	return c.emit(vol.AppID, ct.EventTypeVolume, vol.ID, vol)
+}
@@ -0,0 +1,211 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// roundTrip encodes v as JSON and decodes it into out
+func roundTrip(t *testing.T, v, out interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Date(2020, 3, 4, 5, 6, 7, 8000, time.UTC)
+	exitStatus := int32(1)
+	batchSize := 2
+
+	for _, test := range []struct {
+		name string
+		v    interface{}
+		out  interface{}
+	}{
+		{
+			name: "app",
+			v: &App{
+				ID:            "a1",
+				Name:          "myapp",
+				Meta:          map[string]string{"team": "payments"},
+				Strategy:      DeployStrategyOneByOne,
+				ReleaseID:     "r1",
+				DeployTimeout: 60,
+				CreatedAt:     &now,
+				UpdatedAt:     &now,
+			},
+			out: &App{},
+		},
+		{
+			name: "release",
+			v: &Release{
+				ID:          "r1",
+				AppID:       "a1",
+				ArtifactIDs: []string{"art1", "art2"},
+				Env:         map[string]string{"PORT": "8080"},
+				Meta:        map[string]string{"git": "true"},
+				Processes: map[string]ProcessType{
+					"web": {
+						Args:    []string{"bin/web"},
+						Env:     map[string]string{"WEB": "1"},
+						Ports:   []Port{{Port: 8080, Proto: "tcp"}},
+						Volumes: []VolumeReq{{Path: "/data", DeleteOnStop: true}},
+						Service: "myapp-web",
+					},
+				},
+				CreatedAt: &now,
+			},
+			out: &Release{},
+		},
+		{
+			name: "formation",
+			v: &Formation{
+				AppID:     "a1",
+				ReleaseID: "r1",
+				Processes: map[string]int{"web": 2},
+				Tags:      map[string]map[string]string{"web": {"disk": "ssd"}},
+				CreatedAt: &now,
+				UpdatedAt: &now,
+			},
+			out: &Formation{},
+		},
+		{
+			name: "job",
+			v: &Job{
+				ID:         "host0-u1",
+				UUID:       "u1",
+				HostID:     "host0",
+				AppID:      "a1",
+				ReleaseID:  "r1",
+				Type:       "web",
+				State:      JobStateDown,
+				Args:       []string{"bin/web"},
+				VolumeIDs:  []string{"v1"},
+				Meta:       map[string]string{"weo-controller.app_name": "myapp"},
+				ExitStatus: &exitStatus,
+				CreatedAt:  &now,
+				UpdatedAt:  &now,
+			},
+			out: &Job{},
+		},
+		{
+			name: "deployment",
+			v: &Deployment{
+				ID:              "d1",
+				AppID:           "a1",
+				OldReleaseID:    "r1",
+				NewReleaseID:    "r2",
+				Strategy:        DeployStrategyBlueGreen,
+				Status:          "complete",
+				Processes:       map[string]int{"web": 2},
+				DeployTimeout:   30,
+				DeployBatchSize: &batchSize,
+				CreatedAt:       &now,
+				FinishedAt:      &now,
+			},
+			out: &Deployment{},
+		},
+		{
+			name: "deployment event",
+			v: &DeploymentEvent{
+				AppID:        "a1",
+				DeploymentID: "d1",
+				ReleaseID:    "r2",
+				Status:       "running",
+				JobID:        "host0-u1",
+				JobType:      "web",
+				JobState:     JobStateUp,
+			},
+			out: &DeploymentEvent{},
+		},
+		{
+			name: "resource",
+			v: &Resource{
+				ID:         "res1",
+				ProviderID: "p1",
+				ExternalID: "/databases/1",
+				Env:        map[string]string{"DATABASE_URL": "postgres://db"},
+				Apps:       []string{"a1"},
+				CreatedAt:  &now,
+			},
+			out: &Resource{},
+		},
+		{
+			name: "event",
+			v: &Event{
+				ID:         1,
+				AppID:      "a1",
+				ObjectType: EventTypeDeployment,
+				ObjectID:   "d1",
+				Data:       json.RawMessage(`{"status":"complete"}`),
+				CreatedAt:  &now,
+			},
+			out: &Event{},
+		},
+	} {
+		roundTrip(t, test.v, test.out)
+		if !reflect.DeepEqual(test.v, test.out) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.v, test.out)
+		}
+	}
+}
+
+func TestArtifactRoundTrip(t *testing.T) {
+	manifest := &ImageManifest{
+		Type: ImageManifestTypeV1,
+		Rootfs: []*ImageRootfs{{
+			Platform: DefaultImagePlatform,
+			Layers:   []*ImageLayer{{ID: "l1", Type: ImageLayerTypeTar, Length: 10}},
+		}},
+	}
+	artifact := &Artifact{
+		Type:             ArtifactTypeWeo,
+		URI:              "https://images.example.com/images/" + manifest.ID() + ".json",
+		RawManifest:      manifest.RawManifest(),
+		Hashes:           manifest.Hashes(),
+		Size:             10,
+		LayerURLTemplate: "https://images.example.com/layer/{id}",
+	}
+
+	out := &Artifact{}
+	roundTrip(t, artifact, out)
+	if out.URI != artifact.URI || out.LayerURLTemplate != artifact.LayerURLTemplate || out.Size != artifact.Size {
+		t.Fatalf("expected %+v, got %+v", artifact, out)
+	}
+	if !reflect.DeepEqual(out.Hashes, artifact.Hashes) {
+		t.Fatalf("expected hashes %v, got %v", artifact.Hashes, out.Hashes)
+	}
+	if id := out.Manifest().ID(); id != manifest.ID() {
+		t.Fatalf("expected manifest ID %s, got %s", manifest.ID(), id)
+	}
+	layer := out.Manifest().Rootfs[0].Layers[0]
+	if url := out.LayerURL(layer); url != "https://images.example.com/layer/l1" {
+		t.Fatalf("unexpected layer URL %s", url)
+	}
+}
+
+func TestETag(t *testing.T) {
+	now := time.Date(2020, 3, 4, 5, 6, 7, 8000, time.UTC)
+	app := &App{UpdatedAt: &now}
+	if etag := (&App{}).ETag(); etag != `"0"` {
+		t.Fatalf(`expected "0" for an app which has not been updated, got %s`, etag)
+	}
+
+	// the ETag survives a round trip through JSON and changes with
+	// the update time
+	out := &App{}
+	roundTrip(t, app, out)
+	if out.ETag() != app.ETag() {
+		t.Fatalf("expected ETag %s after a round trip, got %s", app.ETag(), out.ETag())
+	}
+	later := now.Add(time.Microsecond)
+	if (&App{UpdatedAt: &later}).ETag() == app.ETag() {
+		t.Fatal("expected ETag to change with the update time")
+	}
+}
@@ -0,0 +1,103 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const MaxAppNameLength = 100
+
+var (
+	AppNamePattern     = regexp.MustCompile(`^[a-z\d]+(-[a-z\d]+)*$`)
+	ProcessTypePattern = regexp.MustCompile(`^[a-z\d]+([-_][a-z\d]+)*$`)
+	EnvKeyPattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// ValidateAppName checks that name is a valid app name, which must consist of
+// lowercase alphanumeric characters separated by single dashes.
+func ValidateAppName(name string) error {
+	if name == "" {
+		return ValidationError{Field: "name", Message: "must not be blank"}
+	}
+	if len(name) > MaxAppNameLength {
+		return ValidationError{Field: "name", Message: fmt.Sprintf("must not be longer than %d characters", MaxAppNameLength)}
+	}
+	if !AppNamePattern.MatchString(name) {
+		return ValidationError{Field: "name", Message: "is invalid"}
+	}
+	return nil
+}
+
+// ValidateProcessType checks that typ is a valid process type name.
+func ValidateProcessType(typ string) error {
+	if !ProcessTypePattern.MatchString(typ) {
+		return ValidationError{Field: "processes", Message: fmt.Sprintf("%q is not a valid process type", typ)}
+	}
+	return nil
+}
+
+// ValidateEnvKey checks that key can be used as an environment variable name.
+func ValidateEnvKey(key string) error {
+	if !EnvKeyPattern.MatchString(key) {
+		return ValidationError{Field: "env", Message: fmt.Sprintf("%q is not a valid environment variable name", key)}
+	}
+	return nil
+}
+
+// Validate checks the app name and any deployment settings.
+func (a *App) Validate() error {
+	if err := ValidateAppName(a.Name); err != nil {
+		return err
+	}
+	if a.DeployTimeout < 0 {
+		return ValidationError{Field: "deploy_timeout", Message: "must be positive"}
+	}
+	if a.Strategy != "" {
+		return ValidateDeployStrategy(a.Strategy)
+	}
+	return nil
+}
+
+// ValidateDeployStrategy checks that strategy is a supported deployment
+// strategy.
+func ValidateDeployStrategy(strategy string) error {
+	for _, s := range DeployStrategies {
+		if s == strategy {
+			return nil
+		}
+	}
+	return ValidationError{Field: "strategy", Message: fmt.Sprintf("must be one of %s", strings.Join(DeployStrategies, ", "))}
+}
+
+// Validate checks the strategy and deploy timeout of the options, either of
+// which may be unset to use the app default.
+func (o *DeployOptions) Validate() error {
+	if o.DeployTimeout < 0 {
+		return ValidationError{Field: "deploy_timeout", Message: "must be positive"}
+	}
+	if o.Strategy != "" {
+		return ValidateDeployStrategy(o.Strategy)
+	}
+	return nil
+}
+
+// Validate checks the env keys and process types of the release.
+func (r *Release) Validate() error {
+	for k := range r.Env {
+		if err := ValidateEnvKey(k); err != nil {
+			return err
+		}
+	}
+	for typ, proc := range r.Processes {
+		if err := ValidateProcessType(typ); err != nil {
+			return err
+		}
+		for k := range proc.Env {
+			if err := ValidateEnvKey(k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
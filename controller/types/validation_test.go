@@ -0,0 +1,113 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAppName(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		valid bool
+	}{
+		{name: "myapp", valid: true},
+		{name: "my-app-2", valid: true},
+		{name: "0app", valid: true},
+		{name: strings.Repeat("a", MaxAppNameLength), valid: true},
+		{name: ""},
+		{name: strings.Repeat("a", MaxAppNameLength+1)},
+		{name: "MyApp"},
+		{name: "my_app"},
+		{name: "my--app"},
+		{name: "-myapp"},
+		{name: "myapp-"},
+		{name: "my app"},
+	} {
+		err := ValidateAppName(test.name)
+		if test.valid && err != nil {
+			t.Errorf("expected %q to be valid, got %s", test.name, err)
+		} else if !test.valid {
+			if e, ok := err.(ValidationError); !ok || e.Field != "name" {
+				t.Errorf("expected a name validation error for %q, got %v", test.name, err)
+			}
+		}
+	}
+}
+
+func TestValidateEnvKey(t *testing.T) {
+	for _, test := range []struct {
+		key   string
+		valid bool
+	}{
+		{key: "PORT", valid: true},
+		{key: "database_url", valid: true},
+		{key: "_PRIVATE", valid: true},
+		{key: "V2", valid: true},
+		{key: ""},
+		{key: "2V"},
+		{key: "MY-VAR"},
+		{key: "MY VAR"},
+		{key: "A=B"},
+	} {
+		err := ValidateEnvKey(test.key)
+		if test.valid && err != nil {
+			t.Errorf("expected %q to be valid, got %s", test.key, err)
+		} else if !test.valid {
+			if e, ok := err.(ValidationError); !ok || e.Field != "env" {
+				t.Errorf("expected an env validation error for %q, got %v", test.key, err)
+			}
+		}
+	}
+}
+
+func TestReleaseValidate(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		release *Release
+		field   string
+	}{
+		{
+			name:    "empty",
+			release: &Release{},
+		},
+		{
+			name: "valid",
+			release: &Release{
+				Env: map[string]string{"PORT": "8080"},
+				Processes: map[string]ProcessType{
+					"web":         {Env: map[string]string{"WEB_CONCURRENCY": "2"}},
+					"worker_slow": {},
+					"clock-2":     {},
+				},
+			},
+		},
+		{
+			name:    "invalid env key",
+			release: &Release{Env: map[string]string{"MY-VAR": "x"}},
+			field:   "env",
+		},
+		{
+			name:    "invalid process type",
+			release: &Release{Processes: map[string]ProcessType{"Web": {}}},
+			field:   "processes",
+		},
+		{
+			name: "invalid process env key",
+			release: &Release{Processes: map[string]ProcessType{
+				"web": {Env: map[string]string{"1X": "x"}},
+			}},
+			field: "env",
+		},
+	} {
+		err := test.release.Validate()
+		if test.field == "" {
+			if err != nil {
+				t.Errorf("%s: expected release to be valid, got %s", test.name, err)
+			}
+			continue
+		}
+		if e, ok := err.(ValidationError); !ok || e.Field != test.field {
+			t.Errorf("%s: expected a %s validation error, got %v", test.name, test.field, err)
+		}
+	}
+}
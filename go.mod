@@ -4,10 +4,17 @@ go 1.13
 
 require (
 	github.com/BurntSushi/toml v0.3.1
+	github.com/docker/go-units v0.3.0
 	github.com/flynn/flynn v0.0.0-20200328202441-755c95684ffe
 	github.com/flynn/go-docopt v0.0.0-20140912013429-f6dd2ebbb31e
 	github.com/flynn/go-tuf v0.0.0-20190425212541-cf1ac7de1ebf
+	github.com/inconshreveable/log15 v0.0.0-20171019012758-0decfc6c20d9
+	github.com/jackc/pgx v0.0.0-20160715195140-558d5550cf5c
+	github.com/jtacoma/uritemplates v1.0.0
+	github.com/julienschmidt/httprouter v0.0.0-20140925104356-46807412fe50
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/tent/canonical-json-go v0.0.0-20130607151641-96e4ba3a7613
 	gopkg.in/inconshreveable/go-update.v0 v0.0.0-20150814200126-d8b0b1d421aa
 )
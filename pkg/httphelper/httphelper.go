@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/flynn/flynn/pkg/cors"
 	"github.com/jackc/pgx"
 	"github.com/julienschmidt/httprouter"
 	"log"
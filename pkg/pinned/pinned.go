@@ -19,9 +19,9 @@ type Config struct {
 var ErrPinFailure = errors.New("pinned: the peer leaf certificate did not match the provided pin")
 
 func (c *Config) Dial(network, addr string) (net.Conn, error) {
-	var conf tls.Config
+	conf := &tls.Config{}
 	if c.Config != nil {
-		conf = *c.Config
+		conf = c.Config.Clone()
 	}
 	conf.InsecureSkipVerify = true
 
@@ -31,7 +31,7 @@ func (c *Config) Dial(network, addr string) (net.Conn, error) {
 	}
 
 	conn := Conn{
-		Conn: tls.Client(cn, &conf),
+		Conn: tls.Client(cn, conf),
 		Wire: cn,
 	}
 
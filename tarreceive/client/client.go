@@ -1,16 +1,41 @@
 package client
 
 import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/flynn/flynn/pkg/httphelper"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"weo/pkg/attempt"
 	"weo/pkg/httpclient"
+	"weo/pkg/pinned"
 )
 
 var ErrNotFound = errors.New("layer not found")
 
+var ErrInvalidID = errors.New("invalid layer ID, must be a hex encoded SHA-256 digest")
+
+// ProgressFunc is called as a layer is transferred with the number of bytes
+// transferred so far and the size of the layer, or -1 if it is unknown
+type ProgressFunc func(n, total int64)
+
+// transferAttempts is the strategy used to resume interrupted transfers
+var transferAttempts = attempt.Strategy{
+	Total: time.Minute,
+	Delay: time.Second,
+	Min:   5,
+}
 
 type Config struct {
-	Pin   []byte
+	Pin    []byte
 	Domain string
 }
 
@@ -22,3 +47,351 @@ func NewClient(url, key string) *Client {
 	return newClient(url, key, httphelper.RetryClient)
 }
 
+func NewClientWithConfig(url, key string, config Config) *Client {
+	if config.Pin == nil {
+		return NewClient(url, key)
+	}
+	d := &pinned.Config{Pin: config.Pin}
+	if config.Domain != "" {
+		d.Config = &tls.Config{ServerName: config.Domain}
+	}
+	httpClient := &http.Client{Transport: &http.Transport{DialTLS: d.Dial}}
+	c := newClient(url, key, httpClient)
+	c.Host = config.Domain
+	return c
+}
+
+func newClient(url, key string, httpClient *http.Client) *Client {
+	return &Client{
+		Client: &httpclient.Client{
+			ErrNotFound: ErrNotFound,
+			URL:         url,
+			Key:         key,
+			HTTP:        httpClient,
+		},
+	}
+}
+
+// LayerID returns the ID of the layer with the given data, which is the hex
+// encoded SHA-256 digest of the data, along with its size
+func LayerID(data io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, data)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// ValidateID returns ErrInvalidID unless id is a valid layer ID
+func ValidateID(id string) error {
+	if len(id) != sha256.Size*2 || strings.ToLower(id) != id {
+		return ErrInvalidID
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return ErrInvalidID
+	}
+	return nil
+}
+
+// ChecksumError is returned when the data of a layer does not match its ID
+type ChecksumError struct {
+	ID     string
+	Actual string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("layer checksum mismatch: expected %s, got %s", e.ID, e.Actual)
+}
+
+// StatusError is returned when the receiver responds with an unexpected
+// status code
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("tarreceive: unexpected status %d", e.Code)
+	}
+	return fmt.Sprintf("tarreceive: unexpected status %d: %s", e.Code, e.Message)
+}
+
+// temporary returns whether a transfer which failed with err may succeed if
+// it is resumed
+func temporary(err error) bool {
+	switch e := err.(type) {
+	case *StatusError:
+		return e.Code >= 500 || e.Code == http.StatusConflict
+	case *ChecksumError:
+		return false
+	}
+	return err != ErrNotFound && err != ErrInvalidID
+}
+
+// do performs a request, treating any non-2xx response as an error
+func (c *Client) do(method, path string, header http.Header, body io.Reader, length int64) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		req.Header = header
+	}
+	if body != nil {
+		req.ContentLength = length
+	}
+	if c.Key != "" {
+		req.SetBasicAuth("", c.Key)
+	}
+	if c.Host != "" {
+		req.Host = c.Host
+	}
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	return nil, &StatusError{Code: res.StatusCode, Message: strings.TrimSpace(string(msg))}
+}
+
+// HasLayer returns whether the receiver has the complete data of the layer
+func (c *Client) HasLayer(id string) (bool, error) {
+	if err := ValidateID(id); err != nil {
+		return false, err
+	}
+	res, err := c.do("HEAD", "/layer/"+id, nil, nil, 0)
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	return true, nil
+}
+
+// GetLayer returns a reader for the data of the layer with the given ID.
+//
+// If the connection is interrupted, the download is resumed from where it
+// left off using a Range request. The data is verified against the layer ID
+// once it has all been read, with a *ChecksumError returned from Read if it
+// does not match.
+func (c *Client) GetLayer(id string, progress ProgressFunc) (io.ReadCloser, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	r := &layerReader{client: c, id: id, hash: sha256.New(), size: -1, progress: progress}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type layerReader struct {
+	client   *Client
+	id       string
+	body     io.ReadCloser
+	hash     hash.Hash
+	offset   int64
+	size     int64
+	progress ProgressFunc
+	err      error
+}
+
+// open requests the layer data from the current offset
+func (r *layerReader) open() error {
+	var header http.Header
+	if r.offset > 0 {
+		header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", r.offset)}}
+	}
+	res, err := r.client.do("GET", "/layer/"+r.id, header, nil, 0)
+	if err != nil {
+		return err
+	}
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+		size, err := contentRangeSize(res.Header.Get("Content-Range"))
+		if err != nil {
+			res.Body.Close()
+			return err
+		}
+		r.size = size
+	case r.offset > 0:
+		// the receiver ignored the range, so skip the data which has
+		// already been read
+		if _, err := io.CopyN(ioutil.Discard, res.Body, r.offset); err != nil {
+			res.Body.Close()
+			return err
+		}
+		fallthrough
+	default:
+		r.size = res.ContentLength
+	}
+	r.body = res.Body
+	return nil
+}
+
+func (r *layerReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	r.offset += int64(n)
+	if n > 0 && r.progress != nil {
+		r.progress(r.offset, r.size)
+	}
+	switch {
+	case err == io.EOF:
+		if r.size >= 0 && r.offset < r.size {
+			err = r.resume(io.ErrUnexpectedEOF)
+		} else if sum := hex.EncodeToString(r.hash.Sum(nil)); sum != r.id {
+			err = &ChecksumError{ID: r.id, Actual: sum}
+		}
+	case err != nil:
+		err = r.resume(err)
+	}
+	r.err = err
+	return n, err
+}
+
+// resume reopens the layer at the current offset following the given read
+// error, returning nil if it succeeds
+func (r *layerReader) resume(readErr error) error {
+	r.body.Close()
+	for a := transferAttempts.Start(); a.Next(); {
+		err := r.open()
+		if err == nil {
+			return nil
+		} else if !temporary(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("error reading layer %s: %s", r.id, readErr)
+}
+
+func (r *layerReader) Close() error {
+	return r.body.Close()
+}
+
+// PutLayer uploads the data of the layer with the given ID, verifying that
+// the data matches the ID before it is sent.
+//
+// Nothing is sent if the receiver already has the layer. If the upload is
+// interrupted, it is resumed from the number of bytes the receiver reports
+// having received.
+func (c *Client) PutLayer(id string, data io.ReadSeeker, progress ProgressFunc) error {
+	if err := ValidateID(id); err != nil {
+		return err
+	}
+	sum, size, err := LayerID(data)
+	if err != nil {
+		return err
+	} else if sum != id {
+		return &ChecksumError{ID: id, Actual: sum}
+	}
+
+	if exists, err := c.HasLayer(id); err != nil {
+		return err
+	} else if exists {
+		if progress != nil {
+			progress(size, size)
+		}
+		return nil
+	}
+
+	return transferAttempts.RunWithValidator(func() error {
+		offset, err := c.uploadOffset(id)
+		if err != nil {
+			return err
+		}
+		if _, err := data.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		header := http.Header{
+			"Content-Type":  {"application/octet-stream"},
+			"Content-Range": {contentRange(offset, size)},
+		}
+		body := &progressReader{r: data, n: offset, total: size, progress: progress}
+		res, err := c.do("PUT", "/layer/"+id, header, body, size-offset)
+		if err != nil {
+			return err
+		}
+		return res.Body.Close()
+	}, temporary)
+}
+
+// uploadOffset returns the number of bytes the receiver has of an
+// incomplete upload of the layer
+func (c *Client) uploadOffset(id string) (int64, error) {
+	res, err := c.do("HEAD", "/layer/"+id+"/upload", nil, nil, 0)
+	if err == ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	// the received range is reported as "Range: bytes=0-<last byte>"
+	r := res.Header.Get("Range")
+	if r == "" {
+		return 0, nil
+	}
+	i := strings.LastIndex(r, "-")
+	if !strings.HasPrefix(r, "bytes=0-") || i == -1 {
+		return 0, fmt.Errorf("tarreceive: invalid upload range %q", r)
+	}
+	last, err := strconv.ParseInt(r[i+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("tarreceive: invalid upload range %q", r)
+	}
+	return last + 1, nil
+}
+
+// contentRange returns the Content-Range header used to upload the data of
+// a layer of the given size starting at offset
+func contentRange(offset, size int64) string {
+	if offset >= size {
+		return fmt.Sprintf("bytes */%d", size)
+	}
+	return fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size)
+}
+
+// contentRangeSize returns the complete length from a Content-Range header
+func contentRangeSize(header string) (int64, error) {
+	i := strings.LastIndex(header, "/")
+	if i == -1 {
+		return 0, fmt.Errorf("tarreceive: invalid Content-Range %q", header)
+	}
+	if header[i+1:] == "*" {
+		return -1, nil
+	}
+	size, err := strconv.ParseInt(header[i+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("tarreceive: invalid Content-Range %q", header)
+	}
+	return size, nil
+}
+
+type progressReader struct {
+	r        io.Reader
+	n        int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	if n > 0 && p.progress != nil {
+		p.progress(p.n, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,246 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"weo/pkg/attempt"
+	"weo/tarreceive/fake"
+)
+
+const testKey = "test-key"
+
+func init() {
+	// resume interrupted transfers without waiting
+	transferAttempts = attempt.Strategy{
+		Total: 5 * time.Second,
+		Delay: 10 * time.Millisecond,
+		Min:   5,
+	}
+}
+
+// testServer is a fake receiver which counts the requests of each method
+type testServer struct {
+	*fake.Receiver
+
+	mtx      sync.Mutex
+	requests map[string]int
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mtx.Lock()
+	s.requests[req.Method]++
+	s.mtx.Unlock()
+	s.Receiver.ServeHTTP(w, req)
+}
+
+func (s *testServer) Requests(method string) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.requests[method]
+}
+
+func newTestClient() (*Client, *testServer, func()) {
+	receiver := &testServer{Receiver: fake.NewReceiver(testKey), requests: make(map[string]int)}
+	srv := httptest.NewServer(receiver)
+	return newClient(srv.URL, testKey, http.DefaultClient), receiver, srv.Close
+}
+
+func testLayer(size int) ([]byte, string) {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	id, _, _ := LayerID(bytes.NewReader(data))
+	return data, id
+}
+
+func TestPutLayer(t *testing.T) {
+	client, receiver, cleanup := newTestClient()
+	defer cleanup()
+	data, id := testLayer(1 << 20)
+
+	var progress int64
+	if err := client.PutLayer(id, bytes.NewReader(data), func(n, total int64) {
+		if total != int64(len(data)) {
+			t.Errorf("expected total %d, got %d", len(data), total)
+		}
+		progress = n
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if progress != int64(len(data)) {
+		t.Fatalf("expected final progress %d, got %d", len(data), progress)
+	}
+	if got, ok := receiver.Layer(id); !ok || !bytes.Equal(got, data) {
+		t.Fatal("receiver does not have the uploaded layer")
+	}
+}
+
+func TestPutLayerInterrupted(t *testing.T) {
+	client, receiver, cleanup := newTestClient()
+	defer cleanup()
+	data, id := testLayer(1 << 20)
+
+	receiver.Interrupt(100 << 10)
+	if err := client.PutLayer(id, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := receiver.Layer(id); !ok || !bytes.Equal(got, data) {
+		t.Fatal("receiver does not have the resumed layer")
+	}
+	if n := receiver.Requests("PUT"); n != 2 {
+		t.Fatalf("expected the upload to be resumed once, got %d PUT requests", n)
+	}
+}
+
+func TestPutLayerExisting(t *testing.T) {
+	client, receiver, cleanup := newTestClient()
+	defer cleanup()
+	data, _ := testLayer(1024)
+	id := receiver.AddLayer(data)
+
+	var progress int64
+	if err := client.PutLayer(id, bytes.NewReader(data), func(n, total int64) { progress = n }); err != nil {
+		t.Fatal(err)
+	}
+	if n := receiver.Requests("PUT"); n != 0 {
+		t.Fatalf("expected nothing to be uploaded for an existing layer, got %d PUT requests", n)
+	}
+	if progress != int64(len(data)) {
+		t.Fatalf("expected progress %d for an existing layer, got %d", len(data), progress)
+	}
+}
+
+func TestPutLayerChecksumMismatch(t *testing.T) {
+	client, receiver, cleanup := newTestClient()
+	defer cleanup()
+	data, _ := testLayer(1024)
+	_, id := testLayer(2048)
+
+	err := client.PutLayer(id, bytes.NewReader(data), nil)
+	if e, ok := err.(*ChecksumError); !ok || e.ID != id {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+	if _, ok := receiver.Layer(id); ok {
+		t.Fatal("expected the layer not to be uploaded")
+	}
+}
+
+func TestPutLayerInvalidID(t *testing.T) {
+	client, _, cleanup := newTestClient()
+	defer cleanup()
+	if err := client.PutLayer("not-a-digest", bytes.NewReader(nil), nil); err != ErrInvalidID {
+		t.Fatalf("expected ErrInvalidID, got %v", err)
+	}
+}
+
+func TestGetLayer(t *testing.T) {
+	client, receiver, cleanup := newTestClient()
+	defer cleanup()
+	data, _ := testLayer(1 << 20)
+	id := receiver.AddLayer(data)
+
+	var progress int64
+	rc, err := client.GetLayer(id, func(n, total int64) { progress = n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("downloaded data does not match the layer")
+	}
+	if progress != int64(len(data)) {
+		t.Fatalf("expected final progress %d, got %d", len(data), progress)
+	}
+}
+
+func TestGetLayerInterrupted(t *testing.T) {
+	client, receiver, cleanup := newTestClient()
+	defer cleanup()
+	data, _ := testLayer(1 << 20)
+	id := receiver.AddLayer(data)
+
+	receiver.Interrupt(100 << 10)
+	rc, err := client.GetLayer(id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("resumed download does not match the layer")
+	}
+	if n := receiver.Requests("GET"); n != 2 {
+		t.Fatalf("expected the download to be resumed once, got %d GET requests", n)
+	}
+}
+
+func TestGetLayerNotFound(t *testing.T) {
+	client, _, cleanup := newTestClient()
+	defer cleanup()
+	_, id := testLayer(1024)
+	if _, err := client.GetLayer(id, nil); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetLayerChecksumMismatch(t *testing.T) {
+	data, _ := testLayer(1024)
+	_, id := testLayer(2048)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+	client := newClient(srv.URL, "", http.DefaultClient)
+
+	rc, err := client.GetLayer(id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	_, err = ioutil.ReadAll(rc)
+	if e, ok := err.(*ChecksumError); !ok || e.ID != id {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+}
+
+func TestHasLayer(t *testing.T) {
+	client, receiver, cleanup := newTestClient()
+	defer cleanup()
+	data, id := testLayer(1024)
+
+	if exists, err := client.HasLayer(id); err != nil || exists {
+		t.Fatalf("expected missing layer, got %t, %v", exists, err)
+	}
+	receiver.AddLayer(data)
+	if exists, err := client.HasLayer(id); err != nil || !exists {
+		t.Fatalf("expected existing layer, got %t, %v", exists, err)
+	}
+	if _, err := client.HasLayer("ABC"); err != ErrInvalidID {
+		t.Fatalf("expected ErrInvalidID, got %v", err)
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+	receiver := fake.NewReceiver(testKey)
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+	client := newClient(srv.URL, "wrong-key", http.DefaultClient)
+
+	_, id := testLayer(1024)
+	_, err := client.HasLayer(id)
+	if e, ok := err.(*StatusError); !ok || e.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a 401 status error, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package fake
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Receiver is a tar receiver which keeps layers in memory, it is used for
+// local development and tests.
+//
+// It implements the layer API used by the tarreceive client, including
+// Range requests for resumed downloads and partial uploads which are resumed
+// using Content-Range.
+type Receiver struct {
+	key string
+
+	mtx     sync.Mutex
+	layers  map[string][]byte
+	uploads map[string][]byte
+
+	// interruptAfter is the number of bytes after which the next transfer
+	// is interrupted, or -1 if it should not be
+	interruptAfter int64
+}
+
+func NewReceiver(key string) *Receiver {
+	return &Receiver{
+		key:            key,
+		layers:         make(map[string][]byte),
+		uploads:        make(map[string][]byte),
+		interruptAfter: -1,
+	}
+}
+
+// AddLayer adds a layer with the given data, returning its ID
+func (r *Receiver) AddLayer(data []byte) string {
+	id := layerID(data)
+	r.mtx.Lock()
+	r.layers[id] = data
+	r.mtx.Unlock()
+	return id
+}
+
+// Layer returns the data of a complete layer
+func (r *Receiver) Layer(id string) ([]byte, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	data, ok := r.layers[id]
+	return data, ok
+}
+
+// Interrupt causes the next upload or download to have its connection
+// closed once n bytes have been transferred
+func (r *Receiver) Interrupt(n int64) {
+	r.mtx.Lock()
+	r.interruptAfter = n
+	r.mtx.Unlock()
+}
+
+// takeInterrupt returns the pending interruption, if any, and clears it
+func (r *Receiver) takeInterrupt() int64 {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	n := r.interruptAfter
+	r.interruptAfter = -1
+	return n
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.key != "" {
+		if _, pass, _ := req.BasicAuth(); pass != r.key {
+			http.Error(w, "invalid key", http.StatusUnauthorized)
+			return
+		}
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/layer/")
+	if path == req.URL.Path {
+		http.NotFound(w, req)
+		return
+	}
+	id := strings.TrimSuffix(path, "/upload")
+	switch {
+	case path != id && req.Method == "HEAD":
+		r.handleUploadStatus(w, id)
+	case path == id && (req.Method == "GET" || req.Method == "HEAD"):
+		r.handleGetLayer(w, req, id)
+	case path == id && req.Method == "PUT":
+		r.handlePutLayer(w, req, id)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *Receiver) handleGetLayer(w http.ResponseWriter, req *http.Request, id string) {
+	data, ok := r.Layer(id)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method == "GET" {
+		if n := r.takeInterrupt(); n >= 0 {
+			w = &interruptWriter{ResponseWriter: w, remaining: n}
+		}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(data))
+}
+
+func (r *Receiver) handleUploadStatus(w http.ResponseWriter, id string) {
+	r.mtx.Lock()
+	n := len(r.uploads[id])
+	r.mtx.Unlock()
+	if n == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", n-1))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *Receiver) handlePutLayer(w http.ResponseWriter, req *http.Request, id string) {
+	start, total, err := parseContentRange(req.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mtx.Lock()
+	if _, ok := r.layers[id]; ok {
+		r.mtx.Unlock()
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if offset := int64(len(r.uploads[id])); start != offset {
+		r.mtx.Unlock()
+		http.Error(w, fmt.Sprintf("upload offset is %d, not %d", offset, start), http.StatusConflict)
+		return
+	}
+	r.mtx.Unlock()
+
+	var body io.Reader = req.Body
+	limit := r.takeInterrupt()
+	if limit >= 0 {
+		body = io.LimitReader(body, limit)
+	}
+	var buf bytes.Buffer
+	_, readErr := io.Copy(&buf, body)
+
+	r.mtx.Lock()
+	data := append(r.uploads[id], buf.Bytes()...)
+	r.uploads[id] = data
+	r.mtx.Unlock()
+	if limit >= 0 && req.ContentLength > limit {
+		// keep what was received so the upload can be resumed, then
+		// drop the connection as if the network failed
+		panic(http.ErrAbortHandler)
+	}
+	if readErr != nil {
+		http.Error(w, readErr.Error(), http.StatusBadRequest)
+		return
+	}
+	if total >= 0 && int64(len(data)) < total {
+		http.Error(w, fmt.Sprintf("incomplete upload, received %d of %d bytes", len(data), total), http.StatusBadRequest)
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.uploads, id)
+	if sum := layerID(data); sum != id {
+		http.Error(w, fmt.Sprintf("layer checksum mismatch: expected %s, got %s", id, sum), http.StatusBadRequest)
+		return
+	}
+	r.layers[id] = data
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "bytes <start>-<end>/<total>" or "bytes */<total>", returning a total of
+// -1 if the header is empty
+func parseContentRange(header string) (start, total int64, err error) {
+	if header == "" {
+		return 0, -1, nil
+	}
+	invalid := fmt.Errorf("invalid Content-Range %q", header)
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, invalid
+	}
+	s := strings.SplitN(strings.TrimPrefix(header, "bytes "), "/", 2)
+	if len(s) != 2 {
+		return 0, 0, invalid
+	}
+	total, err = strconv.ParseInt(s[1], 10, 64)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	if s[0] == "*" {
+		return total, total, nil
+	}
+	i := strings.Index(s[0], "-")
+	if i == -1 {
+		return 0, 0, invalid
+	}
+	start, err = strconv.ParseInt(s[0][:i], 10, 64)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	return start, total, nil
+}
+
+func layerID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// interruptWriter drops the connection once a number of bytes of the
+// response body have been written
+type interruptWriter struct {
+	http.ResponseWriter
+	remaining int64
+}
+
+func (w *interruptWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.remaining {
+		p = p[:w.remaining]
+		w.ResponseWriter.Write(p)
+		if f, ok := w.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	}
+	w.remaining -= int64(len(p))
+	return w.ResponseWriter.Write(p)
+}